@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+)
+
+// Trailers of the batch geocoding stream.
+const (
+	trailerStreamError = "X-Stream-Error"
+	trailerStreamCount = "X-Stream-Count"
+)
+
+type Address struct {
+	City   string `json:"city"`
+	Street string `json:"street"`
+	House  string `json:"house"`
+	Lat    string `json:"lat"`
+	Lon    string `json:"lon"`
+}
+
+// Point is a pair of coordinates, as decimal strings.
+type Point struct {
+	Lat string `json:"lat"`
+	Lng string `json:"lng"`
+}
+
+// Page selects a page of a list. Zero values leave the server defaults:
+// the first page of 20 items.
+type Page struct {
+	Number int
+	Size   int
+}
+
+// Meta describes the page of a list response.
+type Meta struct {
+	Page       int `json:"page"`
+	PerPage    int `json:"per_page"`
+	Total      int `json:"total"`
+	TotalPages int `json:"total_pages"`
+}
+
+// AddressPage is a page of addresses with its position in the list.
+type AddressPage struct {
+	Addresses []Address `json:"addresses"`
+	Meta      Meta      `json:"meta"`
+}
+
+// GeocodeResult is the result of a point of a batch. Error is set instead
+// of Addresses when the point could not be geocoded.
+type GeocodeResult struct {
+	Lat       string    `json:"lat"`
+	Lng       string    `json:"lng"`
+	Addresses []Address `json:"addresses,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Search looks addresses up by street name.
+func (c *Client) Search(ctx context.Context, query string, page Page) (*AddressPage, error) {
+	return c.addresses(ctx, "/api/v2/address/search", struct {
+		Query string `json:"query"`
+	}{query}, page)
+}
+
+// Geocode finds the addresses at a point.
+func (c *Client) Geocode(ctx context.Context, point Point, page Page) (*AddressPage, error) {
+	return c.addresses(ctx, "/api/v2/address/geocode", point, page)
+}
+
+func (c *Client) addresses(ctx context.Context, path string, body any, page Page) (*AddressPage, error) {
+	query := url.Values{}
+	if page.Number > 0 {
+		query.Set("page", strconv.Itoa(page.Number))
+	}
+	if page.Size > 0 {
+		query.Set("per_page", strconv.Itoa(page.Size))
+	}
+
+	resp, err := call[[]Address](ctx, c, request{path: path, query: query, body: body, auth: true, idempotent: true})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AddressPage{Addresses: resp.Data}
+	if resp.Meta != nil {
+		result.Meta = *resp.Meta
+	}
+	return result, nil
+}
+
+// GeocodeBatch geocodes up to 100 points, in order. Points that fail have
+// their Error set. If the server stops the batch early, the results so far
+// are returned with a *StreamError.
+func (c *Client) GeocodeBatch(ctx context.Context, points []Point) ([]GeocodeResult, error) {
+	resp, err := c.do(ctx, request{
+		path: "/api/v2/address/geocode/batch",
+		body: struct {
+			Points []Point `json:"points"`
+		}{points},
+		auth: true,
+		// only retried until the stream starts
+		idempotent: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	results := make([]GeocodeResult, 0, len(points))
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var result GeocodeResult
+		err := decoder.Decode(&result)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return results, fmt.Errorf("read batch result %d: %w", len(results), err)
+		}
+		results = append(results, result)
+	}
+
+	// trailers are only known once the body is read
+	if message := resp.Trailer.Get(trailerStreamError); message != "" {
+		return results, &StreamError{Message: message, Count: len(results)}
+	}
+	if count, err := strconv.Atoi(resp.Trailer.Get(trailerStreamCount)); err == nil && count != len(results) {
+		return results, &StreamError{Message: fmt.Sprintf("got %d results, sent %d", len(results), count), Count: len(results)}
+	}
+	return results, nil
+}
@@ -0,0 +1,162 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sessionCookie carries the token when the server runs in session cookie
+// mode and leaves it out of the login response.
+const sessionCookie = "jwt"
+
+// expiryLeeway refreshes tokens a little before they expire, so that they
+// do not expire in flight.
+const expiryLeeway = 30 * time.Second
+
+// Account is a registered user.
+type Account struct {
+	Email string `json:"email"`
+}
+
+// Register creates a user. It does not log in.
+func (c *Client) Register(ctx context.Context, email, password string) (Account, error) {
+	resp, err := call[Account](ctx, c, request{
+		path: "/api/v2/register",
+		body: credentials{Email: email, Password: password},
+	})
+	return resp.Data, err
+}
+
+// Login authenticates the client with email and password, which are kept
+// to refresh the token. It returns the token, e.g. to store it for
+// WithToken.
+func (c *Client) Login(ctx context.Context, email, password string) (string, error) {
+	creds := &credentials{Email: email, Password: password}
+	token, err := c.login(ctx, creds)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	c.token, c.credentials = token, creds
+	c.mu.Unlock()
+	return token, nil
+}
+
+// Refresh logs in again with the credentials of Login or WithCredentials.
+// Requests call it on their own when the token expires or is rejected.
+func (c *Client) Refresh(ctx context.Context) error {
+	return c.refresh(ctx, c.Token())
+}
+
+// Token is the token the client authenticates with, empty before login.
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+func (c *Client) login(ctx context.Context, creds *credentials) (string, error) {
+	resp, err := c.do(ctx, request{path: "/api/v2/login", body: creds, idempotent: true})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var session envelope[struct {
+		Token string `json:"token"`
+	}]
+	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+		return "", fmt.Errorf("decode login response: %w", err)
+	}
+	if session.Data.Token != "" {
+		return session.Data.Token, nil
+	}
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == sessionCookie && cookie.Value != "" {
+			return cookie.Value, nil
+		}
+	}
+	return "", fmt.Errorf("login response has no token")
+}
+
+// validToken returns the token to send, logging in first when there is
+// none yet or it has expired.
+func (c *Client) validToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	token, canRefresh := c.token, c.credentials != nil
+	c.mu.Unlock()
+
+	if token != "" && (!expired(token) || !canRefresh) {
+		// without credentials the server is left to reject an expired token
+		return token, nil
+	}
+	if !canRefresh {
+		return "", ErrorNotLoggedIn
+	}
+
+	if err := c.refresh(ctx, token); err != nil {
+		return "", err
+	}
+	return c.Token(), nil
+}
+
+func (c *Client) canRefresh() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.credentials != nil
+}
+
+// refresh replaces the stale token by logging in again, unless another
+// request did it in the meantime.
+func (c *Client) refresh(ctx context.Context, stale string) error {
+	c.refreshing.Lock()
+	defer c.refreshing.Unlock()
+
+	c.mu.Lock()
+	current, creds := c.token, c.credentials
+	c.mu.Unlock()
+
+	if current != stale && current != "" && !expired(current) {
+		return nil
+	}
+	if creds == nil {
+		return ErrorNotLoggedIn
+	}
+
+	token, err := c.login(ctx, creds)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+	return nil
+}
+
+// expired reads the exp claim of a JWT without verifying it, which is the
+// server's job. Tokens without exp never expire.
+func expired(token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	var claims struct {
+		Exp float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return false
+	}
+	return time.Now().Add(expiryLeeway).After(time.Unix(int64(claims.Exp), 0))
+}
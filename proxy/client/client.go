@@ -0,0 +1,253 @@
+// Package client is a typed Go client of the geoservice API v2, for
+// services that would otherwise hand-write calls to /api/login and
+// /api/address. It only depends on the standard library.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRetries = 2
+	defaultBackoff = 100 * time.Millisecond
+	// maxBackoff caps both the exponential backoff and Retry-After.
+	maxBackoff = 10 * time.Second
+)
+
+type Option func(*Client)
+
+// WithHTTPClient sends the requests with httpClient instead of
+// http.DefaultClient, e.g. to set a timeout or a tracing transport.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetries retries requests that are safe to repeat up to retries
+// times when the connection fails or the server answers 429, 502, 503 or
+// 504, waiting backoff, then twice as long every time. Retry-After is
+// honored when the server sends it. Defaults to 2 retries from 100ms.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// WithToken authenticates requests with a token obtained earlier, e.g.
+// stored by a command-line tool.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithCredentials logs in on the first authenticated request, and again
+// whenever the token expires or is rejected.
+func WithCredentials(email, password string) Option {
+	return func(c *Client) {
+		c.credentials = &credentials{Email: email, Password: password}
+	}
+}
+
+// Client calls the API at a base URL such as https://geo.example.com. It
+// is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	retries    int
+	backoff    time.Duration
+
+	mu          sync.Mutex
+	token       string
+	credentials *credentials
+	// refreshing serializes logins, so that concurrent requests rejected
+	// with the same token refresh it once.
+	refreshing sync.Mutex
+}
+
+type credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func New(baseURL string, options ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("base url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("base url %q: want http(s)://host", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+		retries:    defaultRetries,
+		backoff:    defaultBackoff,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c, nil
+}
+
+// request describes a call to the API.
+type request struct {
+	path  string
+	query url.Values
+	body  any
+	// auth sends the token, logging in first if needed.
+	auth bool
+	// idempotent requests are retried.
+	idempotent bool
+}
+
+// envelope is the response body of API v2.
+type envelope[T any] struct {
+	Data T     `json:"data"`
+	Meta *Meta `json:"meta"`
+}
+
+// call sends req and decodes the envelope of the successful response.
+func call[T any](ctx context.Context, c *Client, req request) (envelope[T], error) {
+	var result envelope[T]
+
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return result, fmt.Errorf("decode %s response: %w", req.path, err)
+	}
+	return result, nil
+}
+
+// do sends req, retrying it and refreshing the token as configured, and
+// returns the first successful response. Failed responses are returned as
+// *APIError.
+func (c *Client) do(ctx context.Context, req request) (*http.Response, error) {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return nil, err
+		}
+	}
+
+	refreshed := false
+	for attempt := 0; ; attempt++ {
+		var token string
+		if req.auth {
+			var err error
+			if token, err = c.validToken(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := c.send(ctx, req, body, token)
+		if err == nil && resp.StatusCode < http.StatusBadRequest {
+			return resp, nil
+		}
+
+		var retryAfter time.Duration
+		if err == nil {
+			apiErr := readError(resp)
+			// an expired or revoked token gets a single fresh one
+			if req.auth && apiErr.StatusCode == http.StatusForbidden && !refreshed && c.canRefresh() {
+				refreshed = true
+				if err := c.refresh(ctx, token); err != nil {
+					return nil, err
+				}
+				attempt--
+				continue
+			}
+			if !retryable(apiErr.StatusCode) {
+				return nil, apiErr
+			}
+			retryAfter = apiErr.RetryAfter
+			err = apiErr
+		} else if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if !req.idempotent || attempt >= c.retries {
+			return nil, err
+		}
+		if err := sleep(ctx, c.delay(attempt, retryAfter)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, req request, body []byte, token string) (*http.Response, error) {
+	u := c.baseURL.JoinPath(req.path)
+	u.RawQuery = req.query.Encode()
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	r.Header.Set("Accept", "application/json")
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.httpClient.Do(r)
+}
+
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (c *Client) delay(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return min(retryAfter, maxBackoff)
+	}
+	return min(c.backoff<<attempt, maxBackoff)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// parseRetryAfter reads Retry-After as seconds or as a date.
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
@@ -0,0 +1,302 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"proxy/internal/app"
+	"proxy/internal/config"
+	"proxy/internal/modules"
+	"proxy/internal/modules/auth/repository/dbrepo"
+	aservice "proxy/internal/modules/auth/service"
+	gmock "proxy/internal/modules/geo/controller/mock_service"
+	gentities "proxy/internal/modules/geo/entities"
+	gservice "proxy/internal/modules/geo/service"
+	hservice "proxy/internal/modules/health/service"
+	pmock "proxy/internal/modules/proxy/controller/mock_service"
+	"proxy/internal/utils/logging"
+	"proxy/internal/utils/metrics"
+	"proxy/internal/utils/tracing"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var addresses = []*gentities.Address{
+	{City: "Москва", Street: "Ленина", House: "1"},
+	{City: "Москва", Street: "Ленина", House: "2"},
+	{City: "Москва", Street: "Ленина", House: "3"},
+}
+
+// newTestServer serves the real router of the app with the geo provider
+// mocked.
+func newTestServer(t *testing.T, geo *gmock.MockGeoServicer, authOptions ...aservice.UserAuthOption) *httptest.Server {
+	t.Helper()
+	ctrl := gomock.NewController(t)
+
+	proxy := pmock.NewMockProxyReverser(ctrl)
+	proxy.EXPECT().ProxyReverse(gomock.Any()).DoAndReturn(func(next http.Handler) http.Handler { return next })
+	proxy.EXPECT().Close()
+
+	tp, err := tracing.NewProvider(context.Background(), tracing.ExporterNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	services := &modules.Services{
+		Geo:     geo,
+		Auth:    aservice.NewUserAuth(cfg.Auth.JwtAlg, "secret", dbrepo.NewMapDBRepo(), authOptions...),
+		Proxy:   proxy,
+		Health:  hservice.NewHealthService(),
+		Metrics: metrics.NewRegistry(),
+		Tracing: tp,
+	}
+
+	a, err := app.NewApp(cfg, logging.Discard(), app.WithServices(services))
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+	server := httptest.NewServer(a.Handler())
+	t.Cleanup(func() {
+		server.Close()
+		a.Shutdown(context.Background())
+	})
+	return server
+}
+
+func newTestClient(t *testing.T, server *httptest.Server, options ...Option) *Client {
+	t.Helper()
+	c, err := New(server.URL, append([]Option{WithRetries(2, time.Millisecond)}, options...)...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return c
+}
+
+func TestClient_LoginAndSearch(t *testing.T) {
+	testCases := []struct {
+		name        string
+		authOptions []aservice.UserAuthOption
+	}{
+		{"token", nil},
+		{"session cookies", []aservice.UserAuthOption{aservice.WithSessionCookies()}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			geo := gmock.NewMockGeoServicer(gomock.NewController(t))
+			geo.EXPECT().AddressSearch(gomock.Any(), "Ленина").Return(addresses, nil)
+			server := newTestServer(t, geo, tc.authOptions...)
+			c := newTestClient(t, server)
+			ctx := context.Background()
+
+			if _, err := c.Search(ctx, "Ленина", Page{}); !errors.Is(err, ErrorNotLoggedIn) {
+				t.Errorf("got error %v before login, want %v", err, ErrorNotLoggedIn)
+			}
+
+			account, err := c.Register(ctx, "user@example.com", "password")
+			if err != nil {
+				t.Fatalf("Register() error = %v", err)
+			}
+			if account.Email != "user@example.com" {
+				t.Errorf("got account %v", account)
+			}
+
+			token, err := c.Login(ctx, "user@example.com", "password")
+			if err != nil {
+				t.Fatalf("Login() error = %v", err)
+			}
+			if token == "" || c.Token() != token {
+				t.Errorf("got token %q, client token %q", token, c.Token())
+			}
+
+			page, err := c.Search(ctx, "Ленина", Page{Number: 2, Size: 2})
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			want := &AddressPage{
+				Addresses: []Address{{City: "Москва", Street: "Ленина", House: "3"}},
+				Meta:      Meta{Page: 2, PerPage: 2, Total: 3, TotalPages: 2},
+			}
+			if !reflect.DeepEqual(page, want) {
+				t.Errorf("got page %+v, want %+v", page, want)
+			}
+		})
+	}
+}
+
+func TestClient_Refresh(t *testing.T) {
+	geo := gmock.NewMockGeoServicer(gomock.NewController(t))
+	geo.EXPECT().GeoCode(gomock.Any(), "55.75", "37.62").Return(addresses[:1], nil).Times(2)
+	server := newTestServer(t, geo)
+	ctx := context.Background()
+
+	if _, err := newTestClient(t, server).Register(ctx, "user@example.com", "password"); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	// the first request logs in
+	c := newTestClient(t, server, WithCredentials("user@example.com", "password"))
+	if _, err := c.Geocode(ctx, Point{Lat: "55.75", Lng: "37.62"}, Page{}); err != nil {
+		t.Fatalf("Geocode() error = %v", err)
+	}
+	if c.Token() == "" {
+		t.Fatal("got no token, want a login")
+	}
+
+	// a rejected token is replaced
+	c.token = "revoked"
+	page, err := c.Geocode(ctx, Point{Lat: "55.75", Lng: "37.62"}, Page{})
+	if err != nil {
+		t.Fatalf("Geocode() error = %v", err)
+	}
+	if len(page.Addresses) != 1 {
+		t.Errorf("got %d addresses, want 1", len(page.Addresses))
+	}
+	if c.Token() == "revoked" {
+		t.Error("got the revoked token, want a fresh one")
+	}
+
+	// without credentials it is the caller's problem
+	c = newTestClient(t, server, WithToken("revoked"))
+	var apiErr *APIError
+	if _, err := c.Geocode(ctx, Point{Lat: "55.75", Lng: "37.62"}, Page{}); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("got error %v, want a 403 *APIError", err)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		setup      func(geo *gmock.MockGeoServicer)
+		query      string
+		wantStatus int
+		wantErrors []FieldError
+	}{
+		{
+			name: "retried until available",
+			setup: func(geo *gmock.MockGeoServicer) {
+				gomock.InOrder(
+					geo.EXPECT().AddressSearch(gomock.Any(), "Ленина").Return(nil, gservice.ErrorUnavailable).Times(2),
+					geo.EXPECT().AddressSearch(gomock.Any(), "Ленина").Return(addresses, nil),
+				)
+			},
+			query: "Ленина",
+		},
+		{
+			name: "retries exhausted",
+			setup: func(geo *gmock.MockGeoServicer) {
+				geo.EXPECT().AddressSearch(gomock.Any(), "Ленина").Return(nil, gservice.ErrorUnavailable).Times(3)
+			},
+			query:      "Ленина",
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "invalid request",
+			setup:      func(geo *gmock.MockGeoServicer) {},
+			query:      "",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantErrors: []FieldError{{Field: "query", Message: "is required"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			geo := gmock.NewMockGeoServicer(gomock.NewController(t))
+			tc.setup(geo)
+			server := newTestServer(t, geo)
+			ctx := context.Background()
+
+			c := newTestClient(t, server, WithCredentials("user@example.com", "password"))
+			if _, err := c.Register(ctx, "user@example.com", "password"); err != nil {
+				t.Fatalf("Register() error = %v", err)
+			}
+
+			_, err := c.Search(ctx, tc.query, Page{})
+			if tc.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("Search() error = %v", err)
+				}
+				return
+			}
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("got error %v, want *APIError", err)
+			}
+			if apiErr.StatusCode != tc.wantStatus {
+				t.Errorf("got status %d, want %d", apiErr.StatusCode, tc.wantStatus)
+			}
+			if apiErr.RequestID == "" {
+				t.Error("got no request id")
+			}
+			if !reflect.DeepEqual(apiErr.Errors, tc.wantErrors) {
+				t.Errorf("got field errors %v, want %v", apiErr.Errors, tc.wantErrors)
+			}
+		})
+	}
+}
+
+func TestClient_GeocodeBatch(t *testing.T) {
+	geo := gmock.NewMockGeoServicer(gomock.NewController(t))
+	geo.EXPECT().GeoCode(gomock.Any(), "55.75", "37.62").Return(addresses[:1], nil)
+	geo.EXPECT().GeoCode(gomock.Any(), "-90", "0").Return(nil, errors.New("no addresses"))
+	geo.EXPECT().GeoCode(gomock.Any(), "90", "0").Return(nil, gservice.ErrorUnavailable)
+	server := newTestServer(t, geo)
+	ctx := context.Background()
+
+	c := newTestClient(t, server, WithCredentials("user@example.com", "password"))
+	if _, err := c.Register(ctx, "user@example.com", "password"); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	results, err := c.GeocodeBatch(ctx, []Point{{"55.75", "37.62"}, {"-90", "0"}, {"90", "0"}, {"0", "0"}})
+
+	var streamErr *StreamError
+	if !errors.As(err, &streamErr) || streamErr.Count != 2 {
+		t.Errorf("got error %v, want *StreamError after 2 results", err)
+	}
+	want := []GeocodeResult{
+		{Lat: "55.75", Lng: "37.62", Addresses: []Address{{City: "Москва", Street: "Ленина", House: "1"}}},
+		{Lat: "-90", Lng: "0", Error: "geocoding failed"},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("got results %+v, want %+v", results, want)
+	}
+}
+
+func TestClient_Context(t *testing.T) {
+	geo := gmock.NewMockGeoServicer(gomock.NewController(t))
+	geo.EXPECT().AddressSearch(gomock.Any(), "Ленина").Return(nil, gservice.ErrorUnavailable).AnyTimes()
+	server := newTestServer(t, geo)
+
+	c := newTestClient(t, server, WithCredentials("user@example.com", "password"), WithRetries(5, time.Second))
+	if _, err := c.Register(context.Background(), "user@example.com", "password"); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	// the backoff is cut short by the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	if _, err := c.Search(ctx, "Ленина", Page{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("got Search() returning after %v, want it to stop at the deadline", elapsed)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, baseURL := range []string{"", "geo.example.com", "ftp://geo.example.com", "http://"} {
+		if _, err := New(baseURL); err == nil {
+			t.Errorf("New(%q) got nil error, want an error", baseURL)
+		}
+	}
+	if _, err := New("https://geo.example.com/"); err != nil {
+		t.Errorf("New() error = %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var ErrorNotLoggedIn = errors.New("not logged in: call Login or create the client WithToken or WithCredentials")
+
+// APIError is a failed response. API v2 answers with RFC 9457 problem
+// details, whose members it holds; the plain text errors of the
+// authentication middleware only fill Detail.
+type APIError struct {
+	StatusCode int
+	Type       string
+	Title      string
+	Detail     string
+	Errors     []FieldError
+	RequestID  string
+	// RetryAfter is how long the server asked to wait before retrying.
+	RetryAfter time.Duration
+}
+
+// FieldError is a problem with a single field of the request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "geoservice: %d %s", e.StatusCode, e.Title)
+	if e.Detail != "" && e.Detail != e.Title {
+		b.WriteString(": " + e.Detail)
+	}
+	for i, field := range e.Errors {
+		if i == 0 {
+			b.WriteString(" (")
+		} else {
+			b.WriteString("; ")
+		}
+		b.WriteString(field.Field + ": " + field.Message)
+		if i == len(e.Errors)-1 {
+			b.WriteString(")")
+		}
+	}
+	return b.String()
+}
+
+// StreamError reports a batch that the server stopped early, after Count
+// results.
+type StreamError struct {
+	Message string
+	Count   int
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("geoservice: stream stopped after %d results: %s", e.Count, e.Message)
+}
+
+// readError reads the failed response resp and closes its body.
+func readError(resp *http.Response) *APIError {
+	defer resp.Body.Close()
+
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Title:      http.StatusText(resp.StatusCode),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "application/problem+json" {
+		var problem struct {
+			Type      string       `json:"type"`
+			Title     string       `json:"title"`
+			Detail    string       `json:"detail"`
+			Errors    []FieldError `json:"errors"`
+			RequestID string       `json:"request_id"`
+		}
+		if err := json.Unmarshal(body, &problem); err == nil {
+			apiErr.Type = problem.Type
+			apiErr.Title = problem.Title
+			apiErr.Detail = problem.Detail
+			apiErr.Errors = problem.Errors
+			apiErr.RequestID = problem.RequestID
+			return apiErr
+		}
+	}
+
+	apiErr.Detail = strings.TrimSpace(string(body))
+	return apiErr
+}
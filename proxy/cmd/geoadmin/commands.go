@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"proxy/internal/modules/auth/entities"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+func create(c *cli, args []string) error {
+	fs := c.commandFlags("create", "")
+	email := fs.String("email", "", "email of the user")
+	var roles []string
+	fs.Func("role", "role to assign, repeatable: "+strings.Join(entities.Roles, ", "), func(role string) error {
+		if !slices.Contains(entities.Roles, role) {
+			return fmt.Errorf("unknown role %q", role)
+		}
+		roles = append(roles, role)
+		return nil
+	})
+	if err := parseEmail(fs, email, args); err != nil {
+		return err
+	}
+
+	password, err := c.readPassword()
+	if err != nil {
+		return err
+	}
+	if err := c.auth.Register(entities.User{Email: *email, Password: password}); err != nil {
+		return err
+	}
+	if len(roles) > 0 {
+		if err := c.auth.SetRoles(*email, roles...); err != nil {
+			return fmt.Errorf("created %s without roles: %w", *email, err)
+		}
+	}
+	fmt.Fprintf(c.stderr, "created %s\n", *email)
+	return nil
+}
+
+func passwd(c *cli, args []string) error {
+	fs := c.commandFlags("passwd", "")
+	email := fs.String("email", "", "email of the user")
+	if err := parseEmail(fs, email, args); err != nil {
+		return err
+	}
+
+	password, err := c.readPassword()
+	if err != nil {
+		return err
+	}
+	if err := c.auth.ResetPassword(*email, password); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "reset the password of %s\n", *email)
+	return nil
+}
+
+func roles(c *cli, args []string) error {
+	fs := c.commandFlags("roles", "[ROLE...]")
+	email := fs.String("email", "", "email of the user")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *email == "" {
+		fs.Usage()
+		return fmt.Errorf("roles: --email is required")
+	}
+
+	if err := c.auth.SetRoles(*email, fs.Args()...); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "%s has roles [%s]\n", *email, strings.Join(fs.Args(), " "))
+	return nil
+}
+
+func list(c *cli, args []string) error {
+	if err := parseNoArgs(c.commandFlags("list", ""), args); err != nil {
+		return err
+	}
+
+	users, err := c.db.ListUsers()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "EMAIL\tROLES")
+	for _, user := range users {
+		fmt.Fprintf(w, "%s\t%s\n", user.Email, strings.Join(user.Roles, ","))
+	}
+	return w.Flush()
+}
+
+func export(c *cli, args []string) error {
+	fs := c.commandFlags("export", "[FILE]")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("export: want at most one file")
+	}
+
+	users, err := c.db.ListUsers()
+	if err != nil {
+		return err
+	}
+	records := make([]entities.UserRecord, 0, len(users))
+	for _, user := range users {
+		records = append(records, user.Record())
+	}
+
+	out := c.stdout
+	if fs.NArg() == 1 {
+		// the export holds password hashes
+		f, err := os.OpenFile(fs.Arg(0), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(records); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "exported %d users\n", len(records))
+	return nil
+}
+
+// importUsers adds the users of an export. Every record is checked before
+// any is written, so a bad file changes nothing.
+func importUsers(c *cli, args []string) error {
+	fs := c.commandFlags("import", "FILE")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("import: want one file")
+	}
+
+	in := c.stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	var records []entities.UserRecord
+	if err := json.NewDecoder(in).Decode(&records); err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+
+	seen := make(map[string]bool, len(records))
+	for i, record := range records {
+		if err := checkRecord(record); err != nil {
+			return fmt.Errorf("%s: user %d: %w", fs.Arg(0), i, err)
+		}
+		if seen[record.Email] {
+			return fmt.Errorf("%s: user %d: duplicate email %q", fs.Arg(0), i, record.Email)
+		}
+		seen[record.Email] = true
+	}
+
+	replaced := 0
+	for _, record := range records {
+		if _, err := c.db.GetUserByEmail(record.Email); err == nil {
+			replaced++
+		}
+		if err := c.db.InsertUser(record.User()); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(c.stderr, "imported %d users, %d replaced\n", len(records), replaced)
+	return nil
+}
+
+func checkRecord(record entities.UserRecord) error {
+	if record.Email == "" {
+		return fmt.Errorf("no email")
+	}
+	if _, err := bcrypt.Cost([]byte(record.PasswordHash)); err != nil {
+		return fmt.Errorf("%s: password_hash: %w", record.Email, err)
+	}
+	for _, role := range record.Roles {
+		if !slices.Contains(entities.Roles, role) {
+			return fmt.Errorf("%s: unknown role %q", record.Email, role)
+		}
+	}
+	for _, key := range record.APIKeys {
+		if _, err := hex.DecodeString(key.ID); err != nil || key.ID == "" {
+			return fmt.Errorf("%s: invalid api key id %q", record.Email, key.ID)
+		}
+		if hash, err := hex.DecodeString(key.SecretHash); err != nil || len(hash) != sha256.Size {
+			return fmt.Errorf("%s: api key %s: invalid secret_hash", record.Email, key.ID)
+		}
+	}
+	return nil
+}
+
+// readPassword prompts for a password on a terminal, and otherwise reads
+// the first line of stdin so that scripts can pipe it in.
+func (c *cli) readPassword() (string, error) {
+	if f, ok := c.stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fmt.Fprint(c.stderr, "Password: ")
+		password, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(c.stderr)
+		if err != nil {
+			return "", fmt.Errorf("read password: %w", err)
+		}
+		return string(password), nil
+	}
+
+	line, err := bufio.NewReader(c.stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("no password on stdin")
+	}
+	return password, nil
+}
+
+func parseEmail(fs *flag.FlagSet, email *string, args []string) error {
+	if err := parseNoArgs(fs, args); err != nil {
+		return err
+	}
+	if *email == "" {
+		fs.Usage()
+		return fmt.Errorf("%s: --email is required", fs.Name())
+	}
+	return nil
+}
+
+func parseNoArgs(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return fmt.Errorf("%s: unexpected argument %q", fs.Name(), fs.Arg(0))
+	}
+	return nil
+}
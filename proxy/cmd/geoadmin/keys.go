@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"proxy/internal/modules/auth/entities"
+	"text/tabwriter"
+	"time"
+)
+
+var keyCommands = map[string]command{
+	"create": createKey,
+	"list":   listKeys,
+	"revoke": revokeKey,
+}
+
+// keys runs the API key command named by the first argument.
+func keys(c *cli, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("keys: want create, list or revoke")
+	}
+	cmd, ok := keyCommands[args[0]]
+	if !ok {
+		return fmt.Errorf("keys: unknown command %q, want create, list or revoke", args[0])
+	}
+	return cmd(c, args[1:])
+}
+
+func createKey(c *cli, args []string) error {
+	fs := c.commandFlags("keys create", "")
+	email := fs.String("email", "", "email of the user the key authenticates as")
+	name := fs.String("name", "", "what the key is for, e.g. the script using it")
+	if err := parseEmail(fs, email, args); err != nil {
+		return err
+	}
+
+	key, apiKey, err := c.auth.CreateAPIKey(*email, *name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, key)
+	fmt.Fprintf(c.stderr, "created key %s for %s, store it now as it is not shown again\n", apiKey.ID, *email)
+	return nil
+}
+
+func listKeys(c *cli, args []string) error {
+	fs := c.commandFlags("keys list", "")
+	email := fs.String("email", "", "only list the keys of this user")
+	if err := parseNoArgs(fs, args); err != nil {
+		return err
+	}
+
+	var users []entities.User
+	if *email != "" {
+		user, err := c.db.GetUserByEmail(*email)
+		if err != nil {
+			return fmt.Errorf("%s: %w", *email, err)
+		}
+		users = []entities.User{user}
+	} else {
+		var err error
+		if users, err = c.db.ListUsers(); err != nil {
+			return err
+		}
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "EMAIL\tID\tNAME\tCREATED")
+	for _, user := range users {
+		for _, key := range user.APIKeys {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", user.Email, key.ID, key.Name, key.CreatedAt.Format(time.RFC3339))
+		}
+	}
+	return w.Flush()
+}
+
+func revokeKey(c *cli, args []string) error {
+	fs := c.commandFlags("keys revoke", "ID")
+	email := fs.String("email", "", "email of the user owning the key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *email == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("keys revoke: want --email and one key ID")
+	}
+
+	if err := c.auth.RevokeAPIKey(*email, fs.Arg(0)); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "revoked key %s of %s\n", fs.Arg(0), *email)
+	return nil
+}
@@ -0,0 +1,136 @@
+// Command geoadmin manages the users of the geoservice directly in the
+// configured users file: it creates users, resets passwords, assigns roles,
+// creates, lists and revokes API keys, and exports or imports the users as
+// JSON. The server picks the changes up without a restart.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"proxy/internal/config"
+	"proxy/internal/modules"
+	"proxy/internal/modules/auth/repository"
+	aservice "proxy/internal/modules/auth/service"
+)
+
+const usage = `Usage: geoadmin [flags] <command> [command flags] [arguments]
+
+Commands:
+  create --email EMAIL [--role ROLE]  create a user
+  passwd --email EMAIL                reset the password of a user
+  roles --email EMAIL [ROLE...]       replace the roles of a user, none to revoke them
+  list                                list the users and their roles
+  keys create --email EMAIL [--name NAME]
+                                      create an API key, printed only once
+  keys list [--email EMAIL]           list the API keys
+  keys revoke --email EMAIL ID        revoke an API key
+  export [FILE]                       write the users as JSON, to stdout by default
+  import FILE                         add the users of an export, - for stdin,
+                                      replacing those with the same email
+
+Passwords are prompted for, or read from the first line of stdin when it
+is not a terminal.
+
+Flags:
+`
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "geoadmin:", err)
+		os.Exit(1)
+	}
+}
+
+// cli holds the repository and the streams of a run.
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	db   repository.DatabaseRepo
+	auth *aservice.UserAuth
+}
+
+type command func(c *cli, args []string) error
+
+var commands = map[string]command{
+	"create": create,
+	"passwd": passwd,
+	"roles":  roles,
+	"list":   list,
+	"keys":   keys,
+	"export": export,
+	"import": importUsers,
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("geoadmin", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	configFile := fs.String("config", "", "config file of the server, as its --config (env CONFIG_FILE)")
+	usersFile := fs.String("users-file", "", "users file to manage (default: auth.users_file of the config)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unknown command %q, see geoadmin -h", fs.Arg(0))
+	}
+
+	var configArgs []string
+	if *configFile != "" {
+		configArgs = []string{"--config", *configFile}
+	}
+	// only the users file matters here, so the rest of the config may be
+	// incomplete, e.g. without the DaData keys
+	cfg, _, err := config.Load(configArgs)
+	if cfg == nil {
+		return err
+	}
+	if *usersFile != "" {
+		cfg.Auth.UsersFile = *usersFile
+	}
+	if cfg.Auth.UsersFile == "" {
+		return fmt.Errorf("no users file: set auth.users_file, AUTH_USERS_FILE or --users-file, the server keeps users in memory without one")
+	}
+
+	db, err := modules.NewDatabaseRepo(cfg)
+	if err != nil {
+		return err
+	}
+	c := &cli{
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+		db:     db,
+		auth:   aservice.NewUserAuth(cfg.Auth.JwtAlg, cfg.Auth.JwtSecret, db),
+	}
+	return cmd(c, fs.Args()[1:])
+}
+
+// commandFlags is the flag set of a command, printing its usage line on
+// errors.
+func (c *cli) commandFlags(name, arguments string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: geoadmin %s [flags] %s\n", name, arguments)
+		fs.PrintDefaults()
+	}
+	return fs
+}
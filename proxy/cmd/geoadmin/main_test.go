@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"proxy/internal/modules/auth/entities"
+	"proxy/internal/modules/auth/repository/dbrepo"
+	aservice "proxy/internal/modules/auth/service"
+	"strings"
+	"testing"
+)
+
+// geoadmin runs a command on the users file.
+func geoadmin(t *testing.T, usersFile, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-users-file", usersFile}, args...)
+	err := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), err
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	usersFile := filepath.Join(dir, "users.json")
+
+	// the server keeps the repository open while geoadmin changes the file
+	server, err := dbrepo.NewFileDBRepo(usersFile)
+	if err != nil {
+		t.Fatalf("NewFileDBRepo() error = %v", err)
+	}
+	auth := aservice.NewUserAuth("HS256", "secret", server)
+
+	steps := []struct {
+		name    string
+		stdin   string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{name: "create admin", stdin: "password\n", args: []string{"create", "-email", "admin@example.com", "-role", "admin"}},
+		{name: "create user", stdin: "password\n", args: []string{"create", "-email", "user@example.com"}},
+		{name: "create existing", stdin: "password\n", args: []string{"create", "-email", "user@example.com"}, wantErr: true},
+		{name: "create unknown role", stdin: "password\n", args: []string{"create", "-email", "root@example.com", "-role", "root"}, wantErr: true},
+		{name: "create without password", args: []string{"create", "-email", "root@example.com"}, wantErr: true},
+		{
+			name: "list",
+			args: []string{"list"},
+			want: "" +
+				"EMAIL              ROLES\n" +
+				"admin@example.com  admin\n" +
+				"user@example.com   \n",
+		},
+		{name: "reset password", stdin: "newpassword\n", args: []string{"passwd", "-email", "user@example.com"}},
+		{name: "reset password of unknown user", stdin: "newpassword\n", args: []string{"passwd", "-email", "nobody@example.com"}, wantErr: true},
+		{name: "revoke roles", args: []string{"roles", "-email", "admin@example.com"}},
+		{name: "assign roles", args: []string{"roles", "-email", "user@example.com", "admin"}},
+		{
+			name: "list after changes",
+			args: []string{"list"},
+			want: "" +
+				"EMAIL              ROLES\n" +
+				"admin@example.com  \n" +
+				"user@example.com   admin\n",
+		},
+	}
+	for _, step := range steps {
+		got, err := geoadmin(t, usersFile, step.stdin, step.args...)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: got error %v, want error %v", step.name, err, step.wantErr)
+		}
+		if got != step.want {
+			t.Errorf("%s: got output\n%s\nwant\n%s", step.name, got, step.want)
+		}
+	}
+
+	if _, err := auth.Authenticate(entities.User{Email: "user@example.com", Password: "newpassword"}); err != nil {
+		t.Errorf("server got login error %v, want the new password accepted", err)
+	}
+	user, err := server.GetUserByEmail("user@example.com")
+	if err != nil || len(user.Roles) != 1 || user.Roles[0] != entities.RoleAdmin {
+		t.Errorf("server got user %v, %v, want the admin role", user.Roles, err)
+	}
+
+	info, err := os.Stat(usersFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("got users file mode %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestRun_ExportImport(t *testing.T) {
+	dir := t.TempDir()
+	usersFile := filepath.Join(dir, "users.json")
+	exportFile := filepath.Join(dir, "export.json")
+
+	if _, err := geoadmin(t, usersFile, "password\n", "create", "-email", "admin@example.com", "-role", "admin"); err != nil {
+		t.Fatalf("create error = %v", err)
+	}
+	if _, err := geoadmin(t, usersFile, "", "export", exportFile); err != nil {
+		t.Fatalf("export error = %v", err)
+	}
+
+	restored := filepath.Join(dir, "restored.json")
+	if _, err := geoadmin(t, restored, "", "import", exportFile); err != nil {
+		t.Fatalf("import error = %v", err)
+	}
+	want, _ := geoadmin(t, usersFile, "", "export")
+	got, _ := geoadmin(t, restored, "", "export")
+	if got != want {
+		t.Errorf("got import\n%s\nwant\n%s", got, want)
+	}
+
+	auth := aservice.NewUserAuth("HS256", "secret", mustOpen(t, restored))
+	if _, err := auth.Authenticate(entities.User{Email: "admin@example.com", Password: "password"}); err != nil {
+		t.Errorf("got login error %v after import, want the password kept", err)
+	}
+
+	invalid := []struct {
+		name  string
+		input string
+	}{
+		{"not JSON", "users"},
+		{"no email", `[{"password_hash": "$2a$10$7EqJtq98hPqEX7fNZaFWoOhi5BWX4Z6p7d/uQCT9o7X6Zw9y1mb3u"}]`},
+		{"plain password", `[{"email": "user@example.com", "password_hash": "password"}]`},
+		{"unknown role", `[{"email": "user@example.com", "password_hash": "$2a$10$7EqJtq98hPqEX7fNZaFWoOhi5BWX4Z6p7d/uQCT9o7X6Zw9y1mb3u", "roles": ["root"]}]`},
+		{"plain api key", `[{"email": "user@example.com", "password_hash": "$2a$10$7EqJtq98hPqEX7fNZaFWoOhi5BWX4Z6p7d/uQCT9o7X6Zw9y1mb3u", "api_keys": [{"id": "00ff", "secret_hash": "secret"}]}]`},
+	}
+	for _, tc := range invalid {
+		if _, err := geoadmin(t, restored, tc.input, "import", "-"); err == nil {
+			t.Errorf("%s: got nil error, want an error", tc.name)
+		}
+	}
+	if got, _ := geoadmin(t, restored, "", "export"); got != want {
+		t.Errorf("got users\n%s\nafter invalid imports, want them unchanged", got)
+	}
+}
+
+func TestRun_Keys(t *testing.T) {
+	dir := t.TempDir()
+	usersFile := filepath.Join(dir, "users.json")
+	server := aservice.NewUserAuth("HS256", "secret", mustOpen(t, usersFile))
+	handler := server.RequireAuthentication(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	status := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/address/search", nil)
+		req.Header.Set(aservice.APIKeyHeader, key)
+		wr := httptest.NewRecorder()
+		handler.ServeHTTP(wr, req)
+		return wr.Code
+	}
+
+	if _, err := geoadmin(t, usersFile, "password\n", "create", "-email", "user@example.com"); err != nil {
+		t.Fatalf("create error = %v", err)
+	}
+	if _, err := geoadmin(t, usersFile, "", "keys", "create", "-email", "nobody@example.com"); err == nil {
+		t.Errorf("keys create of unknown user: got nil error, want an error")
+	}
+	out, err := geoadmin(t, usersFile, "", "keys", "create", "-email", "user@example.com", "-name", "deploy")
+	if err != nil {
+		t.Fatalf("keys create error = %v", err)
+	}
+	key := strings.TrimSpace(out)
+	if got := status(key); got != http.StatusOK {
+		t.Errorf("server got status code %d with the new key, want 200", got)
+	}
+
+	user, err := mustOpen(t, usersFile).GetUserByEmail("user@example.com")
+	if err != nil || len(user.APIKeys) != 1 {
+		t.Fatalf("got keys %v, %v, want one", user.APIKeys, err)
+	}
+	id := user.APIKeys[0].ID
+
+	out, err = geoadmin(t, usersFile, "", "keys", "list")
+	if err != nil || !strings.Contains(out, "user@example.com  "+id+"  deploy") || strings.Contains(out, key) {
+		t.Errorf("keys list got\n%s%v\nwant the key %s without its secret", out, err, id)
+	}
+
+	if _, err := geoadmin(t, usersFile, "", "keys", "revoke", "-email", "user@example.com", "unknown"); err == nil {
+		t.Errorf("keys revoke of unknown key: got nil error, want an error")
+	}
+	if _, err := geoadmin(t, usersFile, "", "keys", "revoke", "-email", "user@example.com", id); err != nil {
+		t.Fatalf("keys revoke error = %v", err)
+	}
+	if got := status(key); got != http.StatusForbidden {
+		t.Errorf("server got status code %d with the revoked key, want 403", got)
+	}
+	if out, _ := geoadmin(t, usersFile, "", "keys", "list", "-email", "user@example.com"); out != "EMAIL  ID  NAME  CREATED\n" {
+		t.Errorf("keys list got\n%s\nwant no keys", out)
+	}
+}
+
+func TestRun_NoUsersFile(t *testing.T) {
+	t.Setenv("AUTH_USERS_FILE", "")
+	t.Setenv("CONFIG_FILE", "")
+
+	var stdout, stderr bytes.Buffer
+	err := run([]string{"list"}, strings.NewReader(""), &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "no users file") {
+		t.Errorf("got error %v, want no users file", err)
+	}
+}
+
+func mustOpen(t *testing.T, path string) *dbrepo.FileDBRepo {
+	t.Helper()
+	db, err := dbrepo.NewFileDBRepo(path)
+	if err != nil {
+		t.Fatalf("NewFileDBRepo() error = %v", err)
+	}
+	return db
+}
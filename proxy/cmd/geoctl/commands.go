@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"proxy/client"
+	"strings"
+)
+
+// batchSize is the most points the API geocodes in one request.
+const batchSize = 100
+
+func register(ctx context.Context, c *cli, args []string) error {
+	fs := c.commandFlags("register", "")
+	email := fs.String("email", "", "email of the account")
+	if err := parseNoArgs(fs, args); err != nil {
+		return err
+	}
+	if *email == "" {
+		return fmt.Errorf("register: --email is required")
+	}
+
+	password, err := c.readPassword()
+	if err != nil {
+		return err
+	}
+	api, err := client.New(c.server)
+	if err != nil {
+		return err
+	}
+	account, err := api.Register(ctx, *email, password)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stderr, "registered %s, now run geoctl login --email %s\n", account.Email, account.Email)
+	return nil
+}
+
+func login(ctx context.Context, c *cli, args []string) error {
+	fs := c.commandFlags("login", "")
+	email := fs.String("email", "", "email of the account (default: the last one logged in)")
+	if err := parseNoArgs(fs, args); err != nil {
+		return err
+	}
+	if *email == "" {
+		*email = c.settings.Email
+	}
+	if *email == "" {
+		return fmt.Errorf("login: --email is required")
+	}
+
+	password, err := c.readPassword()
+	if err != nil {
+		return err
+	}
+	api, err := client.New(c.server)
+	if err != nil {
+		return err
+	}
+	token, err := api.Login(ctx, *email, password)
+	if err != nil {
+		return err
+	}
+
+	c.settings.Server, c.settings.Email, c.settings.Token = c.server, *email, token
+	if err := c.settings.save(c.configPath); err != nil {
+		return fmt.Errorf("save token: %w", err)
+	}
+	fmt.Fprintf(c.stderr, "logged in to %s as %s\n", c.server, *email)
+	return nil
+}
+
+func logout(_ context.Context, c *cli, args []string) error {
+	if err := parseNoArgs(c.commandFlags("logout", ""), args); err != nil {
+		return err
+	}
+	c.settings.Token = ""
+	return c.settings.save(c.configPath)
+}
+
+func search(ctx context.Context, c *cli, args []string) error {
+	fs := c.commandFlags("search", "QUERY")
+	page := pageFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("search: no query")
+	}
+
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	result, err := api.Search(ctx, strings.Join(fs.Args(), " "), *page)
+	if err != nil {
+		return err
+	}
+	return c.printPage(result)
+}
+
+func geocode(ctx context.Context, c *cli, args []string) error {
+	fs := c.commandFlags("geocode", "LAT LNG")
+	page := pageFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("geocode: want a latitude and a longitude")
+	}
+
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	result, err := api.Geocode(ctx, client.Point{Lat: fs.Arg(0), Lng: fs.Arg(1)}, *page)
+	if err != nil {
+		return err
+	}
+	return c.printPage(result)
+}
+
+func batch(ctx context.Context, c *cli, args []string) error {
+	fs := c.commandFlags("batch", "FILE")
+	latColumn := fs.String("lat-column", "lat", "CSV column of the latitudes")
+	lngColumn := fs.String("lng-column", "lng", "CSV column of the longitudes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("batch: want one CSV file")
+	}
+
+	in := c.stdin
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	points, err := readPoints(in, *latColumn, *lngColumn)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	results := make([]client.GeocodeResult, 0, len(points))
+	for start := 0; start < len(points); start += batchSize {
+		chunk, err := api.GeocodeBatch(ctx, points[start:min(start+batchSize, len(points))])
+		results = append(results, chunk...)
+		if err != nil {
+			// print what was geocoded before failing
+			if printErr := c.printResults(results); printErr != nil {
+				return printErr
+			}
+			return fmt.Errorf("geocoded %d of %d points: %w", len(results), len(points), err)
+		}
+	}
+	return c.printResults(results)
+}
+
+// client is an API client logged in with the stored token.
+func (c *cli) client() (*client.Client, error) {
+	if c.settings.Token == "" {
+		return nil, fmt.Errorf("not logged in, run geoctl login")
+	}
+	if c.settings.Server != "" && c.settings.Server != c.server {
+		return nil, fmt.Errorf("logged in to %s, not %s: run geoctl login", c.settings.Server, c.server)
+	}
+	return client.New(c.server, client.WithToken(c.settings.Token))
+}
+
+// readPassword prompts for a password on a terminal, and otherwise reads
+// the first line of stdin so that scripts can pipe it in.
+func (c *cli) readPassword() (string, error) {
+	if f, ok := c.stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fmt.Fprint(c.stderr, "Password: ")
+		password, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(c.stderr)
+		if err != nil {
+			return "", fmt.Errorf("read password: %w", err)
+		}
+		return string(password), nil
+	}
+
+	line, err := bufio.NewReader(c.stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("no password on stdin")
+	}
+	return password, nil
+}
+
+func pageFlags(fs *flag.FlagSet) *client.Page {
+	page := &client.Page{}
+	fs.IntVar(&page.Number, "page", 0, "page to show (default 1)")
+	fs.IntVar(&page.Size, "per-page", 0, "addresses per page (default 20)")
+	return page
+}
+
+func parseNoArgs(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return fmt.Errorf("%s: unexpected argument %q", fs.Name(), fs.Arg(0))
+	}
+	return nil
+}
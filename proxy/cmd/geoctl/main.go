@@ -0,0 +1,139 @@
+// Command geoctl calls the geoservice API from the terminal: it logs in,
+// searches and geocodes addresses, and geocodes CSV files in batches.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+const usage = `Usage: geoctl [flags] <command> [command flags] [arguments]
+
+Commands:
+  register --email EMAIL          create an account
+  login --email EMAIL             log in and store the token
+  logout                          forget the stored token
+  search [--page N] QUERY         search addresses by street name
+  geocode [--page N] LAT LNG      find the addresses at a point
+  batch [--lat-column NAME] FILE  geocode the points of a CSV file, - for stdin
+
+Passwords are prompted for, or read from the first line of stdin when it
+is not a terminal.
+
+Flags:
+`
+
+const defaultServer = "http://localhost:8080"
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "geoctl:", err)
+		os.Exit(1)
+	}
+}
+
+// cli holds the global flags and the streams of a run.
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	configPath string
+	settings   *settings
+	server     string
+	output     string
+	timeout    time.Duration
+}
+
+type command func(ctx context.Context, c *cli, args []string) error
+
+var commands = map[string]command{
+	"register": register,
+	"login":    login,
+	"logout":   logout,
+	"search":   search,
+	"geocode":  geocode,
+	"batch":    batch,
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	c := &cli{stdin: stdin, stdout: stdout, stderr: stderr}
+
+	defaultConfig, err := defaultSettingsPath()
+	if err != nil {
+		defaultConfig = ""
+	}
+	if path := os.Getenv("GEOCTL_CONFIG"); path != "" {
+		defaultConfig = path
+	}
+
+	fs := flag.NewFlagSet("geoctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&c.configPath, "config", defaultConfig, "file storing the server and token, or $GEOCTL_CONFIG")
+	fs.StringVar(&c.server, "server", os.Getenv("GEOCTL_SERVER"), "API base URL, or $GEOCTL_SERVER (default: the one logged in to, else "+defaultServer+")")
+	fs.StringVar(&c.output, "o", "table", "output format: table, json or geojson")
+	fs.DurationVar(&c.timeout, "timeout", 30*time.Second, "timeout of the whole command")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if c.output != "table" && c.output != "json" && c.output != "geojson" {
+		return fmt.Errorf("unknown output format %q", c.output)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unknown command %q, see geoctl -h", fs.Arg(0))
+	}
+
+	if c.configPath == "" {
+		return fmt.Errorf("no config file: set --config or $GEOCTL_CONFIG")
+	}
+	if c.settings, err = loadSettings(c.configPath); err != nil {
+		return err
+	}
+	if c.server == "" {
+		c.server = c.settings.Server
+	}
+	if c.server == "" {
+		c.server = defaultServer
+	}
+	c.server = strings.TrimSuffix(c.server, "/")
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return cmd(ctx, c, fs.Args()[1:])
+}
+
+// commandFlags is the flag set of a command, printing its usage line on
+// errors.
+func (c *cli) commandFlags(name, arguments string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: geoctl %s [flags] %s\n", name, arguments)
+		fs.PrintDefaults()
+	}
+	return fs
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/golang/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"proxy/client"
+	"proxy/internal/app"
+	"proxy/internal/config"
+	"proxy/internal/modules"
+	"proxy/internal/modules/auth/repository/dbrepo"
+	aservice "proxy/internal/modules/auth/service"
+	gmock "proxy/internal/modules/geo/controller/mock_service"
+	gentities "proxy/internal/modules/geo/entities"
+	hservice "proxy/internal/modules/health/service"
+	pmock "proxy/internal/modules/proxy/controller/mock_service"
+	"proxy/internal/utils/logging"
+	"proxy/internal/utils/metrics"
+	"proxy/internal/utils/tracing"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var addresses = []*gentities.Address{
+	{City: "Москва", Street: "Ленина", House: "1", Lat: "55.75", Lon: "37.62"},
+	{City: "Москва", Street: "Ленина", House: "2", Lat: "55.76", Lon: "37.63"},
+}
+
+// newTestServer serves the real router of the app with the geo provider
+// mocked.
+func newTestServer(t *testing.T, geo *gmock.MockGeoServicer) *httptest.Server {
+	t.Helper()
+	ctrl := gomock.NewController(t)
+
+	proxy := pmock.NewMockProxyReverser(ctrl)
+	proxy.EXPECT().ProxyReverse(gomock.Any()).DoAndReturn(func(next http.Handler) http.Handler { return next })
+	proxy.EXPECT().Close()
+
+	tp, err := tracing.NewProvider(context.Background(), tracing.ExporterNone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	services := &modules.Services{
+		Geo:     geo,
+		Auth:    aservice.NewUserAuth(cfg.Auth.JwtAlg, "secret", dbrepo.NewMapDBRepo()),
+		Proxy:   proxy,
+		Health:  hservice.NewHealthService(),
+		Metrics: metrics.NewRegistry(),
+		Tracing: tp,
+	}
+
+	a, err := app.NewApp(cfg, logging.Discard(), app.WithServices(services))
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+	server := httptest.NewServer(a.Handler())
+	t.Cleanup(func() {
+		server.Close()
+		a.Shutdown(context.Background())
+	})
+	return server
+}
+
+// geoctl runs a command against the server with the config file in dir.
+func geoctl(t *testing.T, server *httptest.Server, dir, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-server", server.URL, "-config", filepath.Join(dir, "config.json")}, args...)
+	err := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), err
+}
+
+func TestRun(t *testing.T) {
+	geo := gmock.NewMockGeoServicer(gomock.NewController(t))
+	geo.EXPECT().AddressSearch(gomock.Any(), "Ленина улица").Return(addresses, nil).AnyTimes()
+	geo.EXPECT().GeoCode(gomock.Any(), "55.75", "37.62").Return(addresses[:1], nil).AnyTimes()
+	server := newTestServer(t, geo)
+	dir := t.TempDir()
+
+	if _, err := geoctl(t, server, dir, "", "search", "Ленина"); err == nil || !strings.Contains(err.Error(), "not logged in") {
+		t.Errorf("got error %v before login, want not logged in", err)
+	}
+	if _, err := geoctl(t, server, dir, "password\n", "register", "-email", "user@example.com"); err != nil {
+		t.Fatalf("register error = %v", err)
+	}
+	if _, err := geoctl(t, server, dir, "password\n", "login", "-email", "user@example.com"); err != nil {
+		t.Fatalf("login error = %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("got config file mode %v, want 0600", info.Mode().Perm())
+	}
+
+	testCases := []struct {
+		name  string
+		stdin string
+		args  []string
+		want  string
+	}{
+		{
+			name: "search table",
+			args: []string{"search", "Ленина", "улица"},
+			want: "" +
+				"CITY    STREET  HOUSE  LAT    LON\n" +
+				"Москва  Ленина  1      55.75  37.62\n" +
+				"Москва  Ленина  2      55.76  37.63\n",
+		},
+		{
+			name: "geocode json",
+			args: []string{"-o", "json", "geocode", "-per-page", "5", "55.75", "37.62"},
+			want: `{
+  "addresses": [
+    {
+      "city": "Москва",
+      "street": "Ленина",
+      "house": "1",
+      "lat": "55.75",
+      "lon": "37.62"
+    }
+  ],
+  "meta": {
+    "page": 1,
+    "per_page": 5,
+    "total": 1,
+    "total_pages": 1
+  }
+}
+`,
+		},
+		{
+			name:  "batch table",
+			stdin: "id,latitude,longitude\n1,55.75,37.62\n",
+			args:  []string{"batch", "-lat-column", "latitude", "-lng-column", "longitude", "-"},
+			want: "" +
+				"ROW  POINT        CITY    STREET  HOUSE  ERROR\n" +
+				"1    55.75,37.62  Москва  Ленина  1      \n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := geoctl(t, server, dir, tc.stdin, tc.args...)
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got output\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := geoctl(t, server, dir, "", "logout"); err != nil {
+		t.Fatalf("logout error = %v", err)
+	}
+	if _, err := geoctl(t, server, dir, "", "geocode", "55.75", "37.62"); err == nil {
+		t.Error("got nil error after logout, want not logged in")
+	}
+}
+
+func TestPrintResults_GeoJSON(t *testing.T) {
+	var stdout bytes.Buffer
+	c := &cli{stdout: &stdout, output: "geojson"}
+	results := []client.GeocodeResult{
+		{Lat: "55.75", Lng: "37.62", Addresses: []client.Address{{City: "Москва", Street: "Ленина", House: "1", Lat: "55.75", Lon: "37.62"}}},
+		{Lat: "-90", Lng: "0", Error: "geocoding failed"},
+		{Lat: "north", Lng: "0", Error: "geocoding failed"},
+	}
+	if err := c.printResults(results); err != nil {
+		t.Fatalf("printResults() error = %v", err)
+	}
+
+	var got featureCollection
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("got invalid JSON %s: %v", stdout.String(), err)
+	}
+	want := featureCollection{
+		Type: "FeatureCollection",
+		Features: []feature{
+			{
+				Type:       "Feature",
+				Geometry:   &geometry{Type: "Point", Coordinates: []float64{37.62, 55.75}},
+				Properties: map[string]any{"city": "Москва", "street": "Ленина", "house": "1", "row": 1.0},
+			},
+			{
+				Type:       "Feature",
+				Geometry:   &geometry{Type: "Point", Coordinates: []float64{0, -90}},
+				Properties: map[string]any{"error": "geocoding failed", "row": 2.0},
+			},
+			{
+				Type:       "Feature",
+				Properties: map[string]any{"error": "geocoding failed", "row": 3.0},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestReadPoints(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   string
+		want    []client.Point
+		wantErr bool
+	}{
+		{
+			name:  "columns by name",
+			input: "\ufeffName, LNG, Lat\nhome,37.62,55.75\n\"work, office\",30.31,59.94\n",
+			want:  []client.Point{{Lat: "55.75", Lng: "37.62"}, {Lat: "59.94", Lng: "30.31"}},
+		},
+		{name: "empty", input: "", wantErr: true},
+		{name: "no points", input: "lat,lng\n", wantErr: true},
+		{name: "missing column", input: "lat,lon\n55.75,37.62\n", wantErr: true},
+		{name: "short row", input: "name,lat,lng\nhome,55.75\n", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := readPoints(strings.NewReader(tc.input), "lat", "lng")
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
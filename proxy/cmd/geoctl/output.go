@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"proxy/client"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// featureCollection is a GeoJSON document (RFC 7946).
+type featureCollection struct {
+	Type     string    `json:"type"`
+	Features []feature `json:"features"`
+}
+
+type feature struct {
+	Type       string         `json:"type"`
+	Geometry   *geometry      `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+type geometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+func (c *cli) printPage(page *client.AddressPage) error {
+	switch c.output {
+	case "json":
+		return printJSON(c.stdout, page)
+	case "geojson":
+		collection := newFeatureCollection()
+		for _, address := range page.Addresses {
+			collection.add(address.Lat, address.Lon, addressProperties(address))
+		}
+		return printJSON(c.stdout, collection)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CITY\tSTREET\tHOUSE\tLAT\tLON")
+	for _, address := range page.Addresses {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", address.City, address.Street, address.House, address.Lat, address.Lon)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if page.Meta.TotalPages > 0 {
+		fmt.Fprintf(c.stderr, "page %d of %d, %d addresses\n", page.Meta.Page, page.Meta.TotalPages, page.Meta.Total)
+	}
+	return nil
+}
+
+// printResults prints a batch. In GeoJSON, every address is a feature and
+// points that failed are features at the point with an error.
+func (c *cli) printResults(results []client.GeocodeResult) error {
+	switch c.output {
+	case "json":
+		return printJSON(c.stdout, results)
+	case "geojson":
+		collection := newFeatureCollection()
+		for i, result := range results {
+			if result.Error != "" {
+				collection.add(result.Lat, result.Lng, map[string]any{"row": i + 1, "error": result.Error})
+				continue
+			}
+			for _, address := range result.Addresses {
+				properties := addressProperties(address)
+				properties["row"] = i + 1
+				collection.add(address.Lat, address.Lon, properties)
+			}
+		}
+		return printJSON(c.stdout, collection)
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ROW\tPOINT\tCITY\tSTREET\tHOUSE\tERROR")
+	for i, result := range results {
+		point := result.Lat + "," + result.Lng
+		if result.Error != "" || len(result.Addresses) == 0 {
+			fmt.Fprintf(w, "%d\t%s\t\t\t\t%s\n", i+1, point, result.Error)
+		}
+		for _, address := range result.Addresses {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t\n", i+1, point, address.City, address.Street, address.House)
+		}
+	}
+	return w.Flush()
+}
+
+func printJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(v)
+}
+
+func newFeatureCollection() *featureCollection {
+	return &featureCollection{Type: "FeatureCollection", Features: []feature{}}
+}
+
+// add appends a point feature, without geometry when the coordinates are
+// not numbers.
+func (fc *featureCollection) add(lat, lon string, properties map[string]any) {
+	f := feature{Type: "Feature", Properties: properties}
+	latitude, latErr := strconv.ParseFloat(lat, 64)
+	longitude, lonErr := strconv.ParseFloat(lon, 64)
+	if latErr == nil && lonErr == nil {
+		// GeoJSON puts the longitude first
+		f.Geometry = &geometry{Type: "Point", Coordinates: []float64{longitude, latitude}}
+	}
+	fc.Features = append(fc.Features, f)
+}
+
+func addressProperties(address client.Address) map[string]any {
+	return map[string]any{"city": address.City, "street": address.Street, "house": address.House}
+}
+
+// readPoints reads the points of a CSV file with a header row, finding the
+// coordinate columns by name.
+func readPoints(r io.Reader, latColumn, lngColumn string) ([]client.Point, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("empty file")
+	}
+	if err != nil {
+		return nil, err
+	}
+	lat, lng := -1, -1
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if strings.EqualFold(name, latColumn) {
+			lat = i
+		}
+		if strings.EqualFold(name, lngColumn) {
+			lng = i
+		}
+	}
+	if lat < 0 || lng < 0 {
+		return nil, fmt.Errorf("no %q and %q columns in header %q", latColumn, lngColumn, strings.Join(header, ","))
+	}
+
+	var points []client.Point
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) <= max(lat, lng) {
+			return nil, fmt.Errorf("line %d: missing coordinates", line)
+		}
+		points = append(points, client.Point{Lat: strings.TrimSpace(record[lat]), Lng: strings.TrimSpace(record[lng])})
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no points")
+	}
+	return points, nil
+}
@@ -2,28 +2,52 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"flag"
 	"log"
+	"os"
 	_ "proxy/docs"
 	"proxy/internal/app"
+	"proxy/internal/config"
+	"proxy/internal/utils/logging"
 	"time"
 )
 
 // @title Geoservice API
 // @version 2.0.0
-// @description Geoservice with swagger docs and authentication
+// @description Geoservice with swagger docs and authentication.
+// @description Routes are versioned under /api/v1 and /api/v2. Unversioned /api routes serve the version in the API-Version header, v1 when it is missing.
+// @description v1 is deprecated: its responses carry Deprecation, Sunset and successor-version Link headers.
 
-// @host localhost:8080
 // @BasePath /
 
 // @securityDefinitions.apiKey ApiKeyAuth
 // @in header
 // @name Authorization
 func main() {
+	cfg, printConfig, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if printConfig && cfg != nil {
+		cfg.Redacted().WriteYAML(os.Stdout)
+	}
+	if err != nil {
+		log.Fatalf("Invalid config:\n%v", err)
+	}
+	if printConfig {
+		return
+	}
 
-	a, err := app.NewApp()
+	logger, err := logging.NewLogger(os.Stdout, cfg.Log.Format, cfg.Log.Level)
 	if err != nil {
-		log.Fatalf("Failed to init app: %v", err.Error())
+		log.Fatalf("Invalid config:\n%v", err)
+	}
+
+	a, err := app.NewApp(cfg, logger)
+	if err != nil {
+		logger.Error("failed to init app", "error", err)
+		os.Exit(1)
 	}
 
 	go a.Serve()
@@ -31,22 +55,15 @@ func main() {
 	// waiting for a stop signal
 	<-a.Signal()
 
-	// create a context with timeout for graceful shutdown
+	logger.Info("shutting down")
+
+	// in-flight requests get up to 5 seconds to finish
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// countdown to make graceful shutdown explicit
-	ticker, secs := time.NewTicker(1*time.Second), 5
-	for {
-		select {
-		// countdown
-		case <-ticker.C:
-			fmt.Printf("%d...\n", secs)
-			secs -= 1
-		// stop server gracefully
-		case <-ctx.Done():
-			fmt.Println("Server stopped gracefully")
-			return
-		}
+	if err := a.Shutdown(ctx); err != nil {
+		logger.Error("forced shutdown", "error", err)
+		os.Exit(1)
 	}
+	logger.Info("server stopped gracefully")
 }
@@ -15,7 +15,7 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/address/geocode": {
+        "/api/v1/address/geocode": {
             "post": {
                 "security": [
                     {
@@ -24,15 +24,21 @@ const docTemplate = `{
                 ],
                 "description": "Return a list of addresses provided geo coordinates",
                 "consumes": [
-                    "application/json"
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor",
+                    "text/csv"
                 ],
                 "tags": [
                     "address"
                 ],
                 "summary": "Search by coordinates",
+                "deprecated": true,
                 "parameters": [
                     {
                         "description": "coordinates",
@@ -48,44 +54,828 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/readresponder.JSONResponse"
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/address/geocode/batch": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Stream a line of NDJSON per point, in order, as soon as it is geocoded. A point that fails or takes over 5 seconds gets an error in its line;\nif the provider becomes unavailable the stream stops early. The X-Stream-Error trailer reports why a stream stopped and\nX-Stream-Count the number of lines sent. Under v1 a rejected request gets the {error, message} body instead of problem details.",
+                "consumes": [
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor"
+                ],
+                "produces": [
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "address"
+                ],
+                "summary": "Search by a batch of coordinates",
+                "parameters": [
+                    {
+                        "description": "coordinates",
+                        "name": "query",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.AddressGeocodeBatch"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "one per line",
+                        "schema": {
+                            "$ref": "#/definitions/entities.GeocodeResult"
+                        }
+                    },
+                    "400": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/address/search": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Return a list of addresses provided street name. Send Accept: text/csv for a spreadsheet with a column per address field",
+                "consumes": [
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor",
+                    "text/csv"
+                ],
+                "tags": [
+                    "address"
+                ],
+                "summary": "Search by street name",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "description": "street name",
+                        "name": "query",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.AddressSearch"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Bad Gateway",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/cache/purge": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Drop cached pages under the path prefix, or the whole cache if the prefix is empty",
+                "consumes": [
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Purge proxy cache",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "description": "path prefix",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.CachePurge"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/readresponder.JSONResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/entities.CachePurged"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/login": {
+            "post": {
+                "description": "Authenticate user provided their email and password",
+                "consumes": [
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "authenticate user",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "description": "user credentials",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.User"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/logout": {
+            "post": {
+                "description": "Clear session cookies set on login",
+                "produces": [
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "log user out",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "csrf token from the csrf_token cookie",
+                        "name": "X-CSRF-Token",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/register": {
+            "post": {
+                "description": "Register new user provided email address and passport",
+                "consumes": [
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "register new user",
+                "deprecated": true,
+                "parameters": [
+                    {
+                        "description": "user credentials",
+                        "name": "input",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.User"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "406": {
+                        "description": "Not Acceptable",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Request Entity Too Large",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported Media Type",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.JSONResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/address/geocode": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Return a page of addresses provided geo coordinates",
+                "consumes": [
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor",
+                    "text/csv"
+                ],
+                "tags": [
+                    "address"
+                ],
+                "summary": "Search by coordinates",
+                "parameters": [
+                    {
+                        "description": "coordinates",
+                        "name": "query",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.AddressGeocode"
+                        }
+                    },
+                    {
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 1,
+                        "description": "page number, from 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 20,
+                        "description": "addresses per page",
+                        "name": "per_page",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/readresponder.Envelope"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/entities.Address"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "406": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "502": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/address/geocode/batch": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Stream a line of NDJSON per point, in order, as soon as it is geocoded. A point that fails or takes over 5 seconds gets an error in its line;\nif the provider becomes unavailable the stream stops early. The X-Stream-Error trailer reports why a stream stopped and\nX-Stream-Count the number of lines sent. Under v1 a rejected request gets the {error, message} body instead of problem details.",
+                "consumes": [
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor"
+                ],
+                "produces": [
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "address"
+                ],
+                "summary": "Search by a batch of coordinates",
+                "parameters": [
+                    {
+                        "description": "coordinates",
+                        "name": "query",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.AddressGeocodeBatch"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "one per line",
+                        "schema": {
+                            "$ref": "#/definitions/entities.GeocodeResult"
+                        }
+                    },
+                    "400": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "413": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/address/search": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Return a page of addresses provided street name. Send Accept: text/csv for a spreadsheet with a column per address field",
+                "consumes": [
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor",
+                    "text/csv"
+                ],
+                "tags": [
+                    "address"
+                ],
+                "summary": "Search by street name",
+                "parameters": [
+                    {
+                        "description": "street name",
+                        "name": "query",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/entities.AddressSearch"
+                        }
+                    },
+                    {
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 1,
+                        "description": "page number, from 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "maximum": 100,
+                        "minimum": 1,
+                        "type": "integer",
+                        "default": 20,
+                        "description": "addresses per page",
+                        "name": "per_page",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/readresponder.Envelope"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/entities.Address"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "406": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "502": {
+                        "description": "application/problem+json",
                         "schema": {
-                            "$ref": "#/definitions/readresponder.JSONResponse"
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/address/search": {
+        "/api/v2/admin/cache/purge": {
             "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Return a list of addresses provided street name",
+                "description": "Drop cached pages under the path prefix, or the whole cache if the prefix is empty",
                 "consumes": [
-                    "application/json"
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor"
                 ],
                 "tags": [
-                    "address"
+                    "admin"
                 ],
-                "summary": "Search by street name",
+                "summary": "Purge proxy cache",
                 "parameters": [
                     {
-                        "description": "street name",
-                        "name": "query",
+                        "description": "path prefix",
+                        "name": "input",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/entities.AddressSearch"
+                            "$ref": "#/definitions/entities.CachePurge"
                         }
                     }
                 ],
@@ -93,26 +883,72 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/readresponder.JSONResponse"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/readresponder.Envelope"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/entities.CachePurged"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "application/problem+json",
                         "schema": {
-                            "$ref": "#/definitions/readresponder.JSONResponse"
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "406": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/login": {
+        "/api/v2/login": {
             "post": {
-                "description": "Authenticate user provided their email and password",
+                "description": "Authenticate user provided their email and password. In session cookie mode the token is set as a cookie and left out of the response",
                 "consumes": [
-                    "application/json"
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor"
                 ],
                 "tags": [
                     "auth"
@@ -133,32 +969,112 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/readresponder.JSONResponse"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/readresponder.Envelope"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/entities.Session"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "application/problem+json",
                         "schema": {
-                            "$ref": "#/definitions/readresponder.JSONResponse"
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error",
+                        "description": "application/problem+json",
                         "schema": {
-                            "$ref": "#/definitions/readresponder.JSONResponse"
+                            "$ref": "#/definitions/readresponder.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/register": {
+        "/api/v2/logout": {
             "post": {
-                "description": "Register new user provided email address and passport",
+                "description": "Clear session cookies set on login",
+                "tags": [
+                    "auth"
+                ],
+                "summary": "log user out",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "csrf token from the csrf_token cookie",
+                        "name": "X-CSRF-Token",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v2/register": {
+            "post": {
+                "description": "Register new user provided email address and password",
                 "consumes": [
-                    "application/json"
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "application/msgpack",
+                    "application/cbor"
                 ],
                 "tags": [
                     "auth"
@@ -179,13 +1095,143 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/readresponder.JSONResponse"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/readresponder.Envelope"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/entities.Account"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "application/problem+json",
                         "schema": {
-                            "$ref": "#/definitions/readresponder.JSONResponse"
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "406": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "415": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "application/problem+json",
+                        "schema": {
+                            "$ref": "#/definitions/readresponder.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Report that the service is alive. It answers without probing any dependency, so that a dependency being down never gets the service restarted; use /readyz to tell whether it can serve traffic",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "service liveness",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/readresponder.JSONResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/entities.Health"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Report the status of every dependency, failing with 503 while a critical one (the user repository or the proxy upstreams) is down. A degraded service is still ready",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "service readiness",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/readresponder.JSONResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/entities.Health"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/readresponder.JSONResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/entities.Health"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     }
                 }
@@ -193,6 +1239,35 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "entities.Account": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "admin@example.com"
+                }
+            }
+        },
+        "entities.Address": {
+            "type": "object",
+            "properties": {
+                "city": {
+                    "type": "string"
+                },
+                "house": {
+                    "type": "string"
+                },
+                "lat": {
+                    "type": "string"
+                },
+                "lon": {
+                    "type": "string"
+                },
+                "street": {
+                    "type": "string"
+                }
+            }
+        },
         "entities.AddressGeocode": {
             "type": "object",
             "required": [
@@ -201,15 +1276,34 @@ const docTemplate = `{
             ],
             "properties": {
                 "lat": {
+                    "description": "Latitude between -90 and 90",
                     "type": "string",
                     "example": "55.753214"
                 },
                 "lng": {
+                    "description": "Longitude between -180 and 180",
                     "type": "string",
                     "example": "37.642589"
                 }
             }
         },
+        "entities.AddressGeocodeBatch": {
+            "type": "object",
+            "required": [
+                "points"
+            ],
+            "properties": {
+                "points": {
+                    "description": "Up to 100 coordinates, geocoded in order",
+                    "type": "array",
+                    "maxItems": 100,
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/entities.AddressGeocode"
+                    }
+                }
+            }
+        },
         "entities.AddressSearch": {
             "type": "object",
             "required": [
@@ -218,10 +1312,98 @@ const docTemplate = `{
             "properties": {
                 "query": {
                     "type": "string",
+                    "maxLength": 300,
                     "example": "Подкопаевский переулок"
                 }
             }
         },
+        "entities.CachePurge": {
+            "type": "object",
+            "properties": {
+                "prefix": {
+                    "description": "Path prefix starting with a slash; empty purges the whole cache",
+                    "type": "string",
+                    "example": "/tasks"
+                }
+            }
+        },
+        "entities.CachePurged": {
+            "type": "object",
+            "properties": {
+                "purged": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "entities.Check": {
+            "type": "object",
+            "properties": {
+                "checked_at": {
+                    "type": "string"
+                },
+                "critical": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "details": {},
+                "error": {
+                    "type": "string",
+                    "example": "context deadline exceeded"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "entities.GeocodeResult": {
+            "type": "object",
+            "properties": {
+                "addresses": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/entities.Address"
+                    }
+                },
+                "error": {
+                    "type": "string",
+                    "example": "geocoding failed"
+                },
+                "lat": {
+                    "type": "string",
+                    "example": "55.753214"
+                },
+                "lng": {
+                    "type": "string",
+                    "example": "37.642589"
+                }
+            }
+        },
+        "entities.Health": {
+            "type": "object",
+            "properties": {
+                "checks": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/entities.Check"
+                    }
+                },
+                "status": {
+                    "type": "string",
+                    "example": "ok"
+                }
+            }
+        },
+        "entities.Session": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string",
+                    "example": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."
+                }
+            }
+        },
         "entities.User": {
             "type": "object",
             "required": [
@@ -231,14 +1413,40 @@ const docTemplate = `{
             "properties": {
                 "email": {
                     "type": "string",
+                    "format": "email",
+                    "maxLength": 32,
                     "example": "admin@example.com"
                 },
                 "password": {
                     "type": "string",
+                    "maxLength": 32,
+                    "minLength": 3,
                     "example": "password"
                 }
             }
         },
+        "readresponder.Envelope": {
+            "type": "object",
+            "properties": {
+                "data": {},
+                "meta": {
+                    "$ref": "#/definitions/readresponder.Meta"
+                }
+            }
+        },
+        "readresponder.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string",
+                    "example": "email"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "is required"
+                }
+            }
+        },
         "readresponder.JSONResponse": {
             "type": "object",
             "properties": {
@@ -250,6 +1458,63 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "readresponder.Meta": {
+            "type": "object",
+            "properties": {
+                "page": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "per_page": {
+                    "type": "integer",
+                    "example": 20
+                },
+                "total": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "total_pages": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "readresponder.Problem": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string",
+                    "example": "user with this email already exists"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/readresponder.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/api/register"
+                },
+                "request_id": {
+                    "description": "RequestID is an extension member to match the response with the logs.",
+                    "type": "string",
+                    "example": "4bf92f3577b34da6a3ce929d0e0e4736"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 409
+                },
+                "title": {
+                    "type": "string",
+                    "example": "User already exists"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "/problems/user-exists"
+                }
+            }
         }
     },
     "securityDefinitions": {
@@ -264,11 +1529,11 @@ const docTemplate = `{
 // SwaggerInfo holds exported Swagger Info so clients can modify it
 var SwaggerInfo = &swag.Spec{
 	Version:          "2.0.0",
-	Host:             "localhost:8080",
+	Host:             "",
 	BasePath:         "/",
 	Schemes:          []string{},
 	Title:            "Geoservice API",
-	Description:      "Geoservice with swagger docs and authentication",
+	Description:      "Geoservice with swagger docs and authentication.\nRoutes are versioned under /api/v1 and /api/v2. Unversioned /api routes serve the version in the API-Version header, v1 when it is missing.\nv1 is deprecated: its responses carry Deprecation, Sunset and successor-version Link headers.",
 	InfoInstanceName: "swagger",
 	SwaggerTemplate:  docTemplate,
 	LeftDelim:        "{{",
@@ -1,31 +1,69 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/go-chi/chi/v5"
-	"github.com/joho/godotenv"
 	httpSwagger "github.com/swaggo/http-swagger"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"proxy/docs"
+	"proxy/internal/config"
 	"proxy/internal/modules"
+	"proxy/internal/utils/apiversion"
+	"proxy/internal/utils/certreload"
+	"proxy/internal/utils/compress"
+	"proxy/internal/utils/logging"
+	"proxy/internal/utils/metrics"
+	"proxy/internal/utils/openapi"
 	"proxy/internal/utils/readresponder"
+	"proxy/internal/utils/tracing"
+	"sync"
 	"syscall"
 	"time"
 )
 
+// v1DeprecatedAt is when API v2 was released and v1 deprecated.
+var v1DeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
 type App struct {
 	server      *http.Server
 	signalChan  chan os.Signal
-	config      modules.ServicesConfig
+	reloadChan  chan os.Signal
+	config      *config.Config
+	logger      *slog.Logger
 	services    *modules.Services
 	controllers *modules.Controllers
+	compressor  *compress.Compressor
+	spec        *openapi.Document
+	validator   *openapi.Validator
+	certs       *certreload.Reloader
+	redirect    *http.Server
+	metrics     *http.Server
+	inflight    *inflight
+	cancel      context.CancelFunc
+	workers     sync.WaitGroup
+}
+
+type AppOption func(*App)
+
+// WithServices serves the given services instead of building them from the
+// config, e.g. to run the router with mocked providers in tests.
+func WithServices(services *modules.Services) AppOption {
+	return func(a *App) {
+		a.services = services
+	}
 }
 
-func NewApp() (*App, error) {
-	a := &App{}
+func NewApp(cfg *config.Config, logger *slog.Logger, options ...AppOption) (*App, error) {
+	a := &App{config: cfg, logger: logger}
+	for _, option := range options {
+		option(a)
+	}
 
 	if err := a.init(); err != nil {
 		return nil, err
@@ -35,79 +73,353 @@ func NewApp() (*App, error) {
 }
 
 func (a *App) Serve() {
-	fmt.Println("Started server on port", a.config.Port)
-	if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		log.Fatal(err)
+	if a.metrics != nil {
+		go func() {
+			a.logger.Info("serving metrics", "port", a.config.Metrics.Port)
+			if err := a.metrics.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				a.fatal(err)
+			}
+		}()
 	}
+
+	if a.certs == nil {
+		a.logger.Info("started server", "port", a.config.Server.Port)
+		if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.fatal(err)
+		}
+		return
+	}
+
+	go func() {
+		a.logger.Info("redirecting HTTP to HTTPS", "port", a.config.Server.Port)
+		if err := a.redirect.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.fatal(err)
+		}
+	}()
+
+	a.logger.Info("started HTTPS server", "port", a.config.Server.TLS.Port)
+	// the certificate comes from TLSConfig.GetCertificate, hence no file names
+	if err := a.server.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		a.fatal(err)
+	}
+}
+
+func (a *App) fatal(err error) {
+	a.logger.Error("server failed", "error", err)
+	os.Exit(1)
+}
+
+// Handler is the complete router of the app, middlewares included, as
+// served on PORT.
+func (a *App) Handler() http.Handler {
+	return a.server.Handler
 }
 
 func (a *App) Signal() <-chan os.Signal {
 	return a.signalChan
 }
 
-func (a *App) readConfig(configPath string) error {
-	err := godotenv.Load(configPath)
-	if err != nil {
-		return err
+// Shutdown stops accepting connections and waits for in-flight requests to
+// finish, then stops the background workers, releases upstream connections
+// and flushes pending spans. If ctx expires first, the remaining
+// connections are closed and an *InFlightError lists the requests that
+// were cut.
+func (a *App) Shutdown(ctx context.Context) error {
+	servers := []*http.Server{a.server}
+	if a.redirect != nil {
+		servers = append(servers, a.redirect)
+	}
+	if a.metrics != nil {
+		servers = append(servers, a.metrics)
 	}
 
-	a.config.Port = os.Getenv("PORT")
-	a.config.JwtSecret = os.Getenv("JWT_SECRET")
-	a.config.JwtAlg = os.Getenv("JWT_ALG")
-	a.config.ApiKey = os.Getenv("DADATA_API_KEY")
-	a.config.SecretKey = os.Getenv("DADATA_SECRET_KEY")
-	a.config.ProxyHost = os.Getenv("PROXY_HOST")
-	a.config.ProxyPort = os.Getenv("PROXY_PORT")
+	var errs []error
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
 
-	return nil
+	var err error
+	if ctx.Err() != nil {
+		err = &InFlightError{Err: ctx.Err(), Requests: a.inflight.Snapshot()}
+		for _, server := range servers {
+			server.Close()
+		}
+	} else {
+		err = errors.Join(errs...)
+	}
+
+	// workers go last, requests being drained may still need them
+	signal.Stop(a.reloadChan)
+	close(a.reloadChan)
+	a.cancel()
+	a.workers.Wait()
+
+	a.services.Proxy.Close()
+
+	// flush the spans of the drained requests
+	if flushErr := a.services.Tracing.Shutdown(ctx); flushErr != nil && err == nil {
+		err = flushErr
+	}
+
+	return err
 }
 
 func (a *App) init() error {
+	if a.services == nil {
+		services, err := modules.NewServices(a.config, a.logger)
+		if err != nil {
+			return err
+		}
+		a.services = services
+	}
 
-	if err := a.readConfig(".env"); err != nil {
+	rr := readresponder.NewReadRespond(append(modules.Problems(), readresponder.WithMaxBytes(1<<20))...)
+	a.controllers = modules.NewControllers(a.services, rr, a.logger)
+	a.compressor = compress.NewCompressor(compress.WithMinSize(a.config.Compress.MinSize))
+
+	if err := a.initSpec(); err != nil {
 		return err
 	}
 
-	a.services = modules.NewServices(a.config)
-	rr := readresponder.NewReadRespond(readresponder.WithMaxBytes(1 << 20))
-	a.controllers = modules.NewControllers(a.services, rr)
+	a.inflight = newInflight()
+
+	var ctx context.Context
+	ctx, a.cancel = context.WithCancel(context.Background())
 
 	a.server = &http.Server{
-		Addr:         ":" + a.config.Port,
-		Handler:      a.routes(),
+		Addr:         ":" + a.config.Server.Port,
+		Handler:      a.inflight.Middleware(a.routes()),
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 5 * time.Second,
 	}
 
+	if a.config.Metrics.Port != "" {
+		// scrapers reach it on the internal network only, the port is not
+		// published like the API ports
+		metricsRouter := chi.NewRouter()
+		metricsRouter.Handle("/metrics", metrics.Handler(a.services.Metrics))
+		a.metrics = &http.Server{
+			Addr:         ":" + a.config.Metrics.Port,
+			Handler:      metricsRouter,
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 5 * time.Second,
+		}
+	}
+
+	if a.config.Server.TLS.CertFile != "" {
+		if err := a.initTLS(ctx); err != nil {
+			return err
+		}
+	}
+
 	a.signalChan = make(chan os.Signal, 1)
 	signal.Notify(a.signalChan, syscall.SIGINT, syscall.SIGTERM)
 
+	a.reloadChan = make(chan os.Signal, 1)
+	signal.Notify(a.reloadChan, syscall.SIGHUP)
+	a.workers.Add(1)
+	go func() {
+		defer a.workers.Done()
+		a.reload()
+	}()
+
+	return nil
+}
+
+// initSpec converts the generated Swagger docs to OpenAPI 3.1 and, when
+// enabled, compiles the validator of API traffic.
+func (a *App) initSpec() error {
+	spec, err := openapi.Convert([]byte(docs.SwaggerInfo.ReadDoc()))
+	if err != nil {
+		return fmt.Errorf("openapi spec: %w", err)
+	}
+	a.spec = spec
+
+	if a.config.OpenAPI.Validate == "" {
+		return nil
+	}
+	var options []openapi.ValidatorOption
+	if a.config.OpenAPI.Validate == "strict" {
+		options = append(options, openapi.WithRejectInvalid())
+	}
+	a.validator, err = openapi.NewValidator(spec, a.logger, options...)
+	if err != nil {
+		return fmt.Errorf("openapi validator: %w", err)
+	}
+	a.logger.Warn("validating API traffic against the OpenAPI spec", "mode", a.config.OpenAPI.Validate)
+	return nil
+}
+
+// initTLS moves the server to TLS_PORT with certificates reloaded from disk
+// and turns PORT into a plain HTTP listener redirecting to HTTPS, except for
+// the health endpoints.
+func (a *App) initTLS(ctx context.Context) error {
+	certs, err := certreload.NewReloader(a.config.Server.TLS.CertFile, a.config.Server.TLS.KeyFile, a.config.Server.TLS.ClientCAFile)
+	if err != nil {
+		return err
+	}
+	a.certs = certs
+
+	a.workers.Add(1)
+	go func() {
+		defer a.workers.Done()
+		certs.Watch(ctx, 10*time.Second, func(err error) {
+			a.logger.Error("failed to reload TLS certificate", "error", err)
+		})
+	}()
+
+	a.server.Addr = ":" + a.config.Server.TLS.Port
+	a.server.TLSConfig = certs.TLSConfig()
+
+	a.redirect = &http.Server{
+		Addr:         ":" + a.config.Server.Port,
+		Handler:      a.plainHTTP(),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	}
+
 	return nil
 }
 
+// plainHTTP serves the health endpoints on PORT, for container health
+// checks probing localhost without a certificate, and redirects the rest.
+func (a *App) plainHTTP() http.Handler {
+	redirect := redirectHTTPS(a.config.Server.TLS.Port)
+
+	r := chi.NewRouter()
+	r.Get("/healthz", a.controllers.Health.Healthz)
+	r.Get("/readyz", a.controllers.Health.Readyz)
+	r.NotFound(redirect.ServeHTTP)
+	r.MethodNotAllowed(redirect.ServeHTTP)
+	return r
+}
+
+// redirectHTTPS permanently redirects every request to the same URL on the
+// HTTPS port.
+func redirectHTTPS(tlsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if tlsPort != "443" {
+			host = net.JoinHostPort(host, tlsPort)
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// reload re-reads the proxy routing table on SIGHUP without restarting the server.
+func (a *App) reload() {
+	for range a.reloadChan {
+		if err := a.services.Proxy.Reload(); err != nil {
+			a.logger.Error("failed to reload proxy routes", "error", err)
+			continue
+		}
+		a.logger.Info("proxy routes reloaded")
+	}
+}
+
 func (a *App) routes() *chi.Mux {
 	r := chi.NewRouter()
 
+	r.Use(logging.RequestID)
+	r.Use(tracing.Middleware(a.services.Tracing))
+	r.Use(logging.AccessLog(a.logger))
+	r.Use(metrics.NewHTTPMetrics(a.services.Metrics).Middleware)
+	r.Use(a.compressor.Middleware)
+	if a.validator != nil {
+		// inside the compressor, to see the bodies as written
+		r.Use(a.validator.Middleware)
+	}
 	r.Use(a.services.Proxy.ProxyReverse)
 
-	r.Route("/api", func(r chi.Router) {
-		r.Post("/register", a.controllers.Auth.Register)
-		r.Post("/login", a.controllers.Auth.Authenticate)
-		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(200)
-			w.Write([]byte("Hello from API"))
-		})
+	v1, v2 := a.apiV1(), a.apiV2()
+	r.Mount("/api/v1", v1)
+	r.Mount("/api/v2", v2)
+	r.Mount("/api", apiversion.Negotiate(a.config.API.DefaultVersion, map[int]http.Handler{1: v1, 2: v2}))
 
-		r.Route("/address", func(r chi.Router) {
-			r.Use(a.services.Auth.RequireAuthentication)
-			r.Post("/search", a.controllers.Geo.AddressSearch)
-			r.Post("/geocode", a.controllers.Geo.AddressGeocode)
-		})
+	r.Get("/healthz", a.controllers.Health.Healthz)
+	r.Get("/readyz", a.controllers.Health.Readyz)
+
+	r.Handle("/openapi.json", openapi.Handler(a.spec, a.config.OpenAPI.ServerURL))
+	// the UI only reads Swagger 2.0, served next to it whatever the host
+	r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL("doc.json")))
+
+	return r
+}
+
+// apiV1 serves the original API, which is deprecated in favor of v2. It is
+// also served at /api, without the version.
+func (a *App) apiV1() chi.Router {
+	r := chi.NewRouter()
+	r.Use(apiversion.Version(1, apiversion.WithDeprecation(a.v1Deprecation(), "/api/v1", "/api")))
+
+	r.Post("/register", a.controllers.Auth.Register)
+	r.Post("/login", a.controllers.Auth.Authenticate)
+	r.With(a.services.Auth.RequireCSRF).Post("/logout", a.controllers.Auth.Logout)
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+		w.Write([]byte("Hello from API"))
+	})
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(a.services.Auth.RequireAuthentication)
+		r.Use(a.services.Auth.RequireCSRF)
+		r.Use(a.services.Auth.RequireAdmin)
+		r.Post("/cache/purge", a.controllers.Proxy.PurgeCache)
+	})
+
+	r.Route("/address", func(r chi.Router) {
+		r.Use(a.services.Auth.RequireAuthentication)
+		r.Use(a.services.Auth.RequireCSRF)
+		r.Post("/search", a.controllers.Geo.AddressSearch)
+		r.Post("/geocode", a.controllers.Geo.AddressGeocode)
+		r.Post("/geocode/batch", a.controllers.Geo.AddressGeocodeBatch)
 	})
 
-	r.Get("/swagger/*", httpSwagger.Handler(
-		httpSwagger.URL(fmt.Sprintf("http://localhost:%s/swagger/doc.json", a.config.Port)),
-	))
+	return r
+}
+
+// apiV2 wraps responses in a typed envelope, paginates lists and reports
+// every error as problem details.
+func (a *App) apiV2() chi.Router {
+	r := chi.NewRouter()
+	r.Use(apiversion.Version(2))
+	r.Use(readresponder.ProblemDetails)
+
+	r.Post("/register", a.controllers.Auth.RegisterV2)
+	r.Post("/login", a.controllers.Auth.AuthenticateV2)
+	r.With(a.services.Auth.RequireCSRF).Post("/logout", a.controllers.Auth.LogoutV2)
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(a.services.Auth.RequireAuthentication)
+		r.Use(a.services.Auth.RequireCSRF)
+		r.Use(a.services.Auth.RequireAdmin)
+		r.Post("/cache/purge", a.controllers.Proxy.PurgeCacheV2)
+	})
+
+	r.Route("/address", func(r chi.Router) {
+		r.Use(a.services.Auth.RequireAuthentication)
+		r.Use(a.services.Auth.RequireCSRF)
+		r.Post("/search", a.controllers.Geo.AddressSearchV2)
+		r.Post("/geocode", a.controllers.Geo.AddressGeocodeV2)
+		r.Post("/geocode/batch", a.controllers.Geo.AddressGeocodeBatch)
+	})
 
 	return r
 }
+
+// v1Deprecation announces the release that introduced v2 as the
+// deprecation date, and the configured sunset date, if any.
+func (a *App) v1Deprecation() apiversion.Deprecation {
+	deprecation := apiversion.Deprecation{Since: v1DeprecatedAt, Successor: "/api/v2"}
+	if a.config.API.V1Sunset != "" {
+		// validated with the config
+		deprecation.Sunset, _ = time.Parse(time.DateOnly, a.config.API.V1Sunset)
+	}
+	return deprecation
+}
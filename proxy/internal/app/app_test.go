@@ -0,0 +1,324 @@
+package app
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"github.com/golang/mock/gomock"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"proxy/internal/config"
+	"proxy/internal/modules"
+	"proxy/internal/modules/auth/entities"
+	"proxy/internal/modules/auth/repository/dbrepo"
+	aservice "proxy/internal/modules/auth/service"
+	hservice "proxy/internal/modules/health/service"
+	"proxy/internal/modules/proxy/controller/mock_service"
+	pservice "proxy/internal/modules/proxy/service"
+	"proxy/internal/utils/httpcache"
+	"proxy/internal/utils/logging"
+	"proxy/internal/utils/metrics"
+	"proxy/internal/utils/tracing"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApp_Shutdown(t *testing.T) {
+	testCases := []struct {
+		name         string
+		handlerDelay time.Duration
+		timeout      time.Duration
+		wantInFlight []string
+	}{
+		{"drains in-flight request", 100 * time.Millisecond, time.Second, nil},
+		{"reports cut request", time.Second, 100 * time.Millisecond, []string{"GET /slow?q=1"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			proxy := mock_service.NewMockProxyReverser(ctrl)
+			proxy.EXPECT().Close()
+
+			started := make(chan struct{})
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				select {
+				case <-time.After(tc.handlerDelay):
+					w.Write([]byte("done"))
+				case <-r.Context().Done():
+				}
+			})
+
+			tp, _ := tracing.NewProvider(context.Background(), tracing.ExporterNone)
+			a := &App{
+				services:   &modules.Services{Proxy: proxy, Tracing: tp},
+				inflight:   newInflight(),
+				reloadChan: make(chan os.Signal, 1),
+			}
+			_, a.cancel = context.WithCancel(context.Background())
+			a.server = &http.Server{Handler: a.inflight.Middleware(handler)}
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			go a.server.Serve(ln)
+
+			respErr := make(chan error, 1)
+			go func() {
+				resp, err := http.Get("http://" + ln.Addr().String() + "/slow?q=1")
+				if err == nil {
+					resp.Body.Close()
+				}
+				respErr <- err
+			}()
+			<-started
+
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+			err = a.Shutdown(ctx)
+
+			var inFlight *InFlightError
+			if tc.wantInFlight == nil {
+				if err != nil {
+					t.Fatalf("got error %v, want nil", err)
+				}
+				if err := <-respErr; err != nil {
+					t.Errorf("got request error %v, want the request to complete", err)
+				}
+				return
+			}
+
+			if !errors.As(err, &inFlight) || !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("got error %v, want *InFlightError wrapping deadline exceeded", err)
+			}
+			if len(inFlight.Requests) != len(tc.wantInFlight) {
+				t.Fatalf("got in-flight %v, want %v", inFlight.Requests, tc.wantInFlight)
+			}
+			for i, want := range tc.wantInFlight {
+				if !strings.HasPrefix(inFlight.Requests[i], want) {
+					t.Errorf("got in-flight %q, want prefix %q", inFlight.Requests[i], want)
+				}
+			}
+		})
+	}
+}
+
+// newCachingServer serves the real router of the app in front of upstream,
+// with the proxy cache enabled.
+func newCachingServer(t *testing.T, upstream http.Handler) *httptest.Server {
+	t.Helper()
+	backend := httptest.NewServer(upstream)
+	t.Cleanup(backend.Close)
+
+	upstreams, err := pservice.ParseUpstreams(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := httpcache.NewCache(httpcache.NewMemoryStorage(10))
+	tp, _ := tracing.NewProvider(context.Background(), tracing.ExporterNone)
+	cfg := config.Default()
+	services := &modules.Services{
+		Auth:    aservice.NewUserAuth(cfg.Auth.JwtAlg, "secret", dbrepo.NewMapDBRepo()),
+		Proxy:   pservice.NewProxyReverse(upstreams, pservice.WithCache(cache)),
+		Health:  hservice.NewHealthService(),
+		Metrics: metrics.NewRegistry(),
+		Tracing: tp,
+	}
+
+	a, err := NewApp(cfg, logging.Discard(), WithServices(services))
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+	server := httptest.NewServer(a.Handler())
+	t.Cleanup(func() {
+		server.Close()
+		a.Shutdown(context.Background())
+	})
+	return server
+}
+
+func TestApp_CacheCompression(t *testing.T) {
+	page := strings.Repeat("<p>cached page</p>", 200)
+	server := newCachingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte(page))
+	}))
+
+	testCases := []struct {
+		name           string
+		acceptEncoding string
+		wantCache      string
+		wantEncoding   string
+	}{
+		{"miss", "gzip", httpcache.StatusMiss, "gzip"},
+		{"hit", "gzip", httpcache.StatusHit, "gzip"},
+		{"hit without compression", "identity", httpcache.StatusHit, ""},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/page", nil)
+		req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		body := io.Reader(resp.Body)
+		if resp.Header.Get("Content-Encoding") == "gzip" {
+			if body, err = gzip.NewReader(resp.Body); err != nil {
+				t.Fatalf("%s: got invalid gzip body: %v", tc.name, err)
+			}
+		}
+		got, _ := io.ReadAll(body)
+		resp.Body.Close()
+
+		if cache := resp.Header.Get(httpcache.HeaderCache); cache != tc.wantCache {
+			t.Errorf("%s: got X-Cache %q, want %q", tc.name, cache, tc.wantCache)
+		}
+		if encoding := resp.Header.Get("Content-Encoding"); encoding != tc.wantEncoding {
+			t.Errorf("%s: got Content-Encoding %q, want %q", tc.name, encoding, tc.wantEncoding)
+		}
+		if string(got) != page {
+			t.Errorf("%s: got body of %d bytes, want the page of %d bytes", tc.name, len(got), len(page))
+		}
+	}
+}
+
+func TestApp_CacheRequestID(t *testing.T) {
+	server := newCachingServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("cached"))
+	}))
+
+	testCases := []struct {
+		requestID string
+		wantCache string
+	}{
+		{"first", httpcache.StatusMiss},
+		{"second", httpcache.StatusHit},
+	}
+
+	for _, tc := range testCases {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/page", nil)
+		req.Header.Set(logging.HeaderRequestID, tc.requestID)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.requestID, err)
+		}
+		resp.Body.Close()
+
+		if cache := resp.Header.Get(httpcache.HeaderCache); cache != tc.wantCache {
+			t.Errorf("%s: got X-Cache %q, want %q", tc.requestID, cache, tc.wantCache)
+		}
+		if ids := resp.Header.Values(logging.HeaderRequestID); len(ids) != 1 || ids[0] != tc.requestID {
+			t.Errorf("got request IDs %v, want [%s]", ids, tc.requestID)
+		}
+	}
+}
+
+func TestApp_PlainHTTP(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.TLS.Port = "8443"
+	tp, _ := tracing.NewProvider(context.Background(), tracing.ExporterNone)
+	services := &modules.Services{
+		Auth:    aservice.NewUserAuth(cfg.Auth.JwtAlg, "secret", dbrepo.NewMapDBRepo()),
+		Proxy:   pservice.NewProxyReverse(nil),
+		Health:  hservice.NewHealthService(),
+		Metrics: metrics.NewRegistry(),
+		Tracing: tp,
+	}
+	a, err := NewApp(cfg, logging.Discard(), WithServices(services))
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+	defer a.Shutdown(context.Background())
+
+	testCases := []struct {
+		method       string
+		path         string
+		wantCode     int
+		wantLocation string
+	}{
+		{"GET", "/healthz", http.StatusOK, ""},
+		{"GET", "/readyz", http.StatusOK, ""},
+		{"GET", "/api/address/search", http.StatusPermanentRedirect, "https://example.com:8443/api/address/search"},
+		{"POST", "/healthz", http.StatusPermanentRedirect, "https://example.com:8443/healthz"},
+	}
+
+	handler := a.plainHTTP()
+	for _, tc := range testCases {
+		req := httptest.NewRequest(tc.method, "http://example.com:8080"+tc.path, nil)
+		wr := httptest.NewRecorder()
+		handler.ServeHTTP(wr, req)
+
+		if wr.Code != tc.wantCode || wr.Header().Get("Location") != tc.wantLocation {
+			t.Errorf("%s %s: got %d %q, want %d %q", tc.method, tc.path, wr.Code, wr.Header().Get("Location"), tc.wantCode, tc.wantLocation)
+		}
+	}
+}
+
+func TestApp_APIErrors(t *testing.T) {
+	cfg := config.Default()
+	tp, _ := tracing.NewProvider(context.Background(), tracing.ExporterNone)
+	auth := aservice.NewUserAuth(cfg.Auth.JwtAlg, "secret", dbrepo.NewMapDBRepo())
+	user := entities.User{Email: "user@example.com", Password: "password"}
+	if err := auth.Register(user); err != nil {
+		t.Fatal(err)
+	}
+	token, err := auth.Authenticate(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	services := &modules.Services{
+		Auth:    auth,
+		Proxy:   pservice.NewProxyReverse(nil),
+		Health:  hservice.NewHealthService(),
+		Metrics: metrics.NewRegistry(),
+		Tracing: tp,
+	}
+	a, err := NewApp(cfg, logging.Discard(), WithServices(services))
+	if err != nil {
+		t.Fatalf("NewApp() error = %v", err)
+	}
+	defer a.Shutdown(context.Background())
+
+	// v1 keeps the {error, message} body its clients parse, only v2 answers
+	// problem details
+	testCases := []struct {
+		path            string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{"/api/address/geocode", http.StatusBadRequest, "application/json", `"message":"lat: is required; lng: is required"`},
+		{"/api/v1/address/geocode", http.StatusBadRequest, "application/json", `"message":"lat: is required; lng: is required"`},
+		{"/api/v2/address/geocode", http.StatusUnprocessableEntity, "application/problem+json", `"type":`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tc.path, strings.NewReader("{}"))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+token)
+			wr := httptest.NewRecorder()
+			a.Handler().ServeHTTP(wr, req)
+
+			if wr.Code != tc.wantStatus {
+				t.Errorf("got status %d, want %d", wr.Code, tc.wantStatus)
+			}
+			if got := wr.Header().Get("Content-Type"); !strings.HasPrefix(got, tc.wantContentType) {
+				t.Errorf("got content type %q, want %q", got, tc.wantContentType)
+			}
+			if !strings.Contains(wr.Body.String(), tc.wantBody) {
+				t.Errorf("got body %s, want it to contain %s", wr.Body.String(), tc.wantBody)
+			}
+		})
+	}
+}
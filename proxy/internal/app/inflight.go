@@ -0,0 +1,82 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InFlightError is returned by Shutdown when the deadline passed before every
+// request finished, listing the requests that were cut.
+type InFlightError struct {
+	Err      error
+	Requests []string
+}
+
+func (e *InFlightError) Error() string {
+	return fmt.Sprintf("%v, %d request(s) still in flight: %s", e.Err, len(e.Requests), strings.Join(e.Requests, "; "))
+}
+
+func (e *InFlightError) Unwrap() error {
+	return e.Err
+}
+
+type inflightRequest struct {
+	method  string
+	uri     string
+	started time.Time
+}
+
+// inflight keeps track of the requests being served so that a shutdown
+// running out of time can tell which ones it is about to cut.
+type inflight struct {
+	m        sync.Mutex
+	next     uint64
+	requests map[uint64]inflightRequest
+}
+
+func newInflight() *inflight {
+	return &inflight{requests: make(map[uint64]inflightRequest)}
+}
+
+func (f *inflight) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.m.Lock()
+		id := f.next
+		f.next++
+		f.requests[id] = inflightRequest{method: r.Method, uri: r.URL.RequestURI(), started: time.Now()}
+		f.m.Unlock()
+
+		defer func() {
+			f.m.Lock()
+			delete(f.requests, id)
+			f.m.Unlock()
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Snapshot describes the requests in flight, oldest first.
+func (f *inflight) Snapshot() []string {
+	f.m.Lock()
+	requests := make([]inflightRequest, 0, len(f.requests))
+	for _, req := range f.requests {
+		requests = append(requests, req)
+	}
+	f.m.Unlock()
+
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].started.Before(requests[j].started)
+	})
+
+	now := time.Now()
+	snapshot := make([]string, len(requests))
+	for i, req := range requests {
+		snapshot[i] = fmt.Sprintf("%s %s (%s)", req.method, req.uri, now.Sub(req.started).Round(time.Millisecond))
+	}
+	return snapshot
+}
@@ -0,0 +1,275 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the complete application configuration. Every setting has a
+// key in the config file (yaml/toml tags), an environment variable (env)
+// and a command-line flag named after its dotted file key, e.g.
+// --proxy.health-interval. Settings tagged secret are redacted when printed.
+type Config struct {
+	Server   ServerConfig   `yaml:"server" toml:"server"`
+	Auth     AuthConfig     `yaml:"auth" toml:"auth"`
+	Geo      GeoConfig      `yaml:"geo" toml:"geo"`
+	Proxy    ProxyConfig    `yaml:"proxy" toml:"proxy"`
+	Cache    CacheConfig    `yaml:"cache" toml:"cache"`
+	Compress CompressConfig `yaml:"compress" toml:"compress"`
+	Tracing  TracingConfig  `yaml:"tracing" toml:"tracing"`
+	Log      LogConfig      `yaml:"log" toml:"log"`
+	Health   HealthConfig   `yaml:"health" toml:"health"`
+	API      APIConfig      `yaml:"api" toml:"api"`
+	OpenAPI  OpenAPIConfig  `yaml:"openapi" toml:"openapi"`
+	Metrics  MetricsConfig  `yaml:"metrics" toml:"metrics"`
+}
+
+type ServerConfig struct {
+	Port string    `yaml:"port" toml:"port" env:"PORT" default:"8080"`
+	TLS  TLSConfig `yaml:"tls" toml:"tls"`
+}
+
+// TLSConfig enables HTTPS on Port when CertFile is set; the server port
+// then only redirects to it.
+type TLSConfig struct {
+	CertFile     string `yaml:"cert_file" toml:"cert_file" env:"TLS_CERT_FILE"`
+	KeyFile      string `yaml:"key_file" toml:"key_file" env:"TLS_KEY_FILE"`
+	ClientCAFile string `yaml:"client_ca_file" toml:"client_ca_file" env:"TLS_CLIENT_CA_FILE"`
+	// ClientIdentities maps client certificate names to caller identities.
+	ClientIdentities map[string]string `yaml:"client_identities" toml:"client_identities" env:"TLS_CLIENT_IDENTITIES"`
+	Port             string            `yaml:"port" toml:"port" env:"TLS_PORT" default:"8443"`
+}
+
+type AuthConfig struct {
+	JwtAlg         string   `yaml:"jwt_alg" toml:"jwt_alg" env:"JWT_ALG" default:"HS256"`
+	JwtSecret      string   `yaml:"jwt_secret" toml:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+	SessionCookies bool     `yaml:"session_cookies" toml:"session_cookies" env:"SESSION_COOKIES"`
+	AdminEmails    []string `yaml:"admin_emails" toml:"admin_emails" env:"ADMIN_EMAILS"`
+	// UsersFile keeps the users across restarts and lets geoadmin manage
+	// them; without it they are kept in memory.
+	UsersFile string `yaml:"users_file" toml:"users_file" env:"AUTH_USERS_FILE"`
+}
+
+type GeoConfig struct {
+	ApiKey           string        `yaml:"api_key" toml:"api_key" env:"DADATA_API_KEY" secret:"true"`
+	SecretKey        string        `yaml:"secret_key" toml:"secret_key" env:"DADATA_SECRET_KEY" secret:"true"`
+	Retries          int           `yaml:"retries" toml:"retries" env:"GEO_RETRIES" default:"2"`
+	Timeout          time.Duration `yaml:"timeout" toml:"timeout" env:"GEO_TIMEOUT" default:"5s"`
+	BreakerThreshold int           `yaml:"breaker_threshold" toml:"breaker_threshold" env:"GEO_BREAKER_THRESHOLD" default:"5"`
+	BreakerTimeout   time.Duration `yaml:"breaker_timeout" toml:"breaker_timeout" env:"GEO_BREAKER_TIMEOUT" default:"30s"`
+	OfflineFile      string        `yaml:"offline_file" toml:"offline_file" env:"GEO_OFFLINE_FILE"`
+}
+
+type ProxyConfig struct {
+	Host string `yaml:"host" toml:"host" env:"PROXY_HOST" default:"hugo"`
+	Port string `yaml:"port" toml:"port" env:"PROXY_PORT" default:"1313"`
+	// Upstreams overrides Host and Port with host:port[=weight],... when set.
+	Upstreams         string        `yaml:"upstreams" toml:"upstreams" env:"PROXY_UPSTREAMS"`
+	Strategy          string        `yaml:"strategy" toml:"strategy" env:"PROXY_STRATEGY" default:"round-robin"`
+	HealthPath        string        `yaml:"health_path" toml:"health_path" env:"PROXY_HEALTH_PATH" default:"/"`
+	HealthInterval    time.Duration `yaml:"health_interval" toml:"health_interval" env:"PROXY_HEALTH_INTERVAL" default:"10s"`
+	Routes            string        `yaml:"routes" toml:"routes" env:"PROXY_ROUTES"`
+	StreamIdleTimeout time.Duration `yaml:"stream_idle_timeout" toml:"stream_idle_timeout" env:"PROXY_STREAM_IDLE_TIMEOUT" default:"5m"`
+}
+
+type CacheConfig struct {
+	// Storage is memory, disk or empty to disable caching.
+	Storage    string        `yaml:"storage" toml:"storage" env:"CACHE_STORAGE" default:"memory"`
+	Dir        string        `yaml:"dir" toml:"dir" env:"CACHE_DIR" default:"cache"`
+	MaxEntries int           `yaml:"max_entries" toml:"max_entries" env:"CACHE_MAX_ENTRIES" default:"1000"`
+	DefaultTTL time.Duration `yaml:"default_ttl" toml:"default_ttl" env:"CACHE_DEFAULT_TTL" default:"0s"`
+}
+
+type CompressConfig struct {
+	MinSize int `yaml:"min_size" toml:"min_size" env:"COMPRESS_MIN_SIZE" default:"1024"`
+}
+
+type TracingConfig struct {
+	// Exporter is otlp, stdout or empty to disable tracing.
+	Exporter    string  `yaml:"exporter" toml:"exporter" env:"TRACING_EXPORTER"`
+	Endpoint    string  `yaml:"endpoint" toml:"endpoint" env:"TRACING_OTLP_ENDPOINT" default:"localhost:4318"`
+	Insecure    bool    `yaml:"insecure" toml:"insecure" env:"TRACING_OTLP_INSECURE"`
+	ServiceName string  `yaml:"service_name" toml:"service_name" env:"TRACING_SERVICE_NAME" default:"geoservice"`
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" default:"1"`
+}
+
+type LogConfig struct {
+	// Format is text or json.
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT" default:"text"`
+	Level  string `yaml:"level" toml:"level" env:"LOG_LEVEL" default:"info"`
+}
+
+type HealthConfig struct {
+	Timeout time.Duration `yaml:"timeout" toml:"timeout" env:"HEALTH_TIMEOUT" default:"2s"`
+	// GeoTTL is how long the result of the geo provider probe is reused.
+	GeoTTL time.Duration `yaml:"geo_ttl" toml:"geo_ttl" env:"HEALTH_GEO_TTL" default:"30s"`
+}
+
+type APIConfig struct {
+	// DefaultVersion serves /api routes requested without an API-Version header.
+	DefaultVersion int `yaml:"default_version" toml:"default_version" env:"API_DEFAULT_VERSION" default:"1"`
+	// V1Sunset is the date, as 2006-01-02, after which /api/v1 may be removed.
+	V1Sunset string `yaml:"v1_sunset" toml:"v1_sunset" env:"API_V1_SUNSET"`
+}
+
+type OpenAPIConfig struct {
+	// ServerURL is the public URL of the API listed in /openapi.json, e.g.
+	// https://geo.example.com. The URL of each request is listed when empty.
+	ServerURL string `yaml:"server_url" toml:"server_url" env:"OPENAPI_SERVER_URL"`
+	// Validate checks API requests and responses against the spec during
+	// development: log reports mismatches, strict also rejects invalid
+	// requests, empty disables it.
+	Validate string `yaml:"validate" toml:"validate" env:"OPENAPI_VALIDATE"`
+}
+
+type MetricsConfig struct {
+	// Port serves /metrics on its own plain HTTP listener, kept off the
+	// public ports; empty disables it.
+	Port string `yaml:"port" toml:"port" env:"METRICS_PORT" default:"9090"`
+}
+
+const redacted = "******"
+
+// Default returns the configuration with only the default values applied.
+func Default() *Config {
+	c := &Config{}
+	walk(c, func(f field) error {
+		if value, ok := f.tag.Lookup("default"); ok {
+			return f.set(value)
+		}
+		return nil
+	})
+	return c
+}
+
+// Validate checks every setting and reports all the problems found at once.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	check(validPort(c.Server.Port), "server.port", "invalid port %q", c.Server.Port)
+
+	tls := c.Server.TLS
+	check((tls.CertFile == "") == (tls.KeyFile == ""), "server.tls", "cert_file and key_file must be set together")
+	if tls.CertFile != "" {
+		check(validPort(tls.Port), "server.tls.port", "invalid port %q", tls.Port)
+		check(tls.Port != c.Server.Port, "server.tls.port", "must differ from server.port")
+	}
+	check(len(tls.ClientIdentities) == 0 || tls.ClientCAFile != "", "server.tls.client_identities", "require client_ca_file")
+	check(tls.ClientCAFile == "" || tls.CertFile != "", "server.tls.client_ca_file", "requires cert_file")
+
+	check(c.Auth.JwtSecret != "", "auth.jwt_secret", "is required")
+	check(oneOf(c.Auth.JwtAlg, "HS256", "HS384", "HS512"), "auth.jwt_alg", "unsupported algorithm %q", c.Auth.JwtAlg)
+
+	check(c.Geo.ApiKey != "", "geo.api_key", "is required")
+	check(c.Geo.SecretKey != "", "geo.secret_key", "is required")
+	check(c.Geo.Retries >= 0, "geo.retries", "must not be negative")
+	check(c.Geo.Timeout > 0, "geo.timeout", "must be positive")
+	check(c.Geo.BreakerThreshold > 0, "geo.breaker_threshold", "must be positive")
+	check(c.Geo.BreakerTimeout > 0, "geo.breaker_timeout", "must be positive")
+
+	check(c.Proxy.Upstreams != "" || (c.Proxy.Host != "" && validPort(c.Proxy.Port)), "proxy", "upstreams or host and port are required")
+	check(oneOf(c.Proxy.Strategy, "round-robin", "least-connections", "weighted"), "proxy.strategy", "unknown strategy %q", c.Proxy.Strategy)
+	check(c.Proxy.HealthInterval > 0, "proxy.health_interval", "must be positive")
+	check(c.Proxy.StreamIdleTimeout >= 0, "proxy.stream_idle_timeout", "must not be negative")
+
+	check(oneOf(c.Cache.Storage, "", "memory", "disk"), "cache.storage", "unknown storage %q", c.Cache.Storage)
+	check(c.Cache.Storage != "disk" || c.Cache.Dir != "", "cache.dir", "is required for disk storage")
+	check(c.Cache.Storage != "memory" || c.Cache.MaxEntries > 0, "cache.max_entries", "must be positive for memory storage")
+	check(c.Cache.DefaultTTL >= 0, "cache.default_ttl", "must not be negative")
+
+	check(c.Compress.MinSize >= 0, "compress.min_size", "must not be negative")
+
+	check(oneOf(c.Tracing.Exporter, "", "stdout", "otlp"), "tracing.exporter", "unknown exporter %q", c.Tracing.Exporter)
+	check(c.Tracing.Exporter != "otlp" || c.Tracing.Endpoint != "", "tracing.endpoint", "is required for the otlp exporter")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be between 0 and 1")
+
+	check(oneOf(c.Log.Format, "text", "json"), "log.format", "unknown format %q", c.Log.Format)
+	check(oneOf(strings.ToLower(c.Log.Level), "debug", "info", "warn", "error"), "log.level", "unknown level %q", c.Log.Level)
+
+	check(c.Health.Timeout > 0, "health.timeout", "must be positive")
+	check(c.Health.GeoTTL >= 0, "health.geo_ttl", "must not be negative")
+
+	check(c.API.DefaultVersion == 1 || c.API.DefaultVersion == 2, "api.default_version", "unknown version %d", c.API.DefaultVersion)
+	_, err := time.Parse(time.DateOnly, c.API.V1Sunset)
+	check(c.API.V1Sunset == "" || err == nil, "api.v1_sunset", "invalid date %q, use YYYY-MM-DD", c.API.V1Sunset)
+
+	serverURL, err := url.Parse(c.OpenAPI.ServerURL)
+	check(c.OpenAPI.ServerURL == "" || err == nil && oneOf(serverURL.Scheme, "http", "https") && serverURL.Host != "",
+		"openapi.server_url", "invalid URL %q", c.OpenAPI.ServerURL)
+	check(oneOf(c.OpenAPI.Validate, "", "log", "strict"), "openapi.validate", "unknown mode %q", c.OpenAPI.Validate)
+
+	if c.Metrics.Port != "" {
+		check(validPort(c.Metrics.Port), "metrics.port", "invalid port %q", c.Metrics.Port)
+		check(c.Metrics.Port != c.Server.Port && (tls.CertFile == "" || c.Metrics.Port != tls.Port), "metrics.port", "must differ from the server ports")
+	}
+
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy of the config safe to print, with the secrets
+// that are set replaced by a placeholder.
+func (c *Config) Redacted() *Config {
+	copied := *c
+	walk(&copied, func(f field) error {
+		if f.tag.Get("secret") == "true" && f.value.String() != "" {
+			f.value.SetString(redacted)
+		}
+		return nil
+	})
+	return &copied
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n < 1<<16
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+// field is a leaf setting of the config found by walk.
+type field struct {
+	key   string
+	tag   reflect.StructTag
+	value reflect.Value
+}
+
+// walk calls fn for every leaf setting of c, stopping at the first error.
+func walk(c *Config, fn func(field) error) error {
+	return walkStruct(reflect.ValueOf(c).Elem(), "", fn)
+}
+
+func walkStruct(v reflect.Value, prefix string, fn func(field) error) error {
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		key := prefix + sf.Tag.Get("yaml")
+
+		if sf.Type.Kind() == reflect.Struct && sf.Type != reflect.TypeOf(time.Duration(0)) {
+			if err := walkStruct(v.Field(i), key+".", fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := fn(field{key: key, tag: sf.Tag, value: v.Field(i)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func setRequired(t *testing.T) {
+	t.Setenv("JWT_SECRET", "verysecret")
+	t.Setenv("DADATA_API_KEY", "key")
+	t.Setenv("DADATA_SECRET_KEY", "secret")
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	yamlFile := writeFile(t, "config.yaml", `
+server:
+  port: "9000"
+proxy:
+  strategy: weighted
+  health_interval: 30s
+cache:
+  max_entries: 50
+`)
+	tomlFile := writeFile(t, "config.toml", `
+[server]
+port = "9000"
+
+[proxy]
+strategy = "weighted"
+health_interval = "30s"
+
+[cache]
+max_entries = 50
+`)
+
+	testCases := []struct {
+		name         string
+		args         []string
+		env          map[string]string
+		wantPort     string
+		wantStrategy string
+		wantInterval time.Duration
+		wantEntries  int
+	}{
+		{"defaults", nil, nil, "8080", "round-robin", 10 * time.Second, 1000},
+		{"yaml file", []string{"--config", yamlFile}, nil, "9000", "weighted", 30 * time.Second, 50},
+		{"toml file from env", nil, map[string]string{"CONFIG_FILE": tomlFile}, "9000", "weighted", 30 * time.Second, 50},
+		{"env over file", []string{"--config", yamlFile}, map[string]string{"PORT": "9100", "PROXY_HEALTH_INTERVAL": "1m"}, "9100", "weighted", time.Minute, 50},
+		{"flags over env", []string{"--config", yamlFile, "--server.port", "9200", "--cache.max-entries=5"}, map[string]string{"PORT": "9100"}, "9200", "weighted", 30 * time.Second, 5},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setRequired(t)
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+
+			c, _, err := Load(tc.args)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			if c.Server.Port != tc.wantPort {
+				t.Errorf("got port %q, want %q", c.Server.Port, tc.wantPort)
+			}
+			if c.Proxy.Strategy != tc.wantStrategy {
+				t.Errorf("got strategy %q, want %q", c.Proxy.Strategy, tc.wantStrategy)
+			}
+			if c.Proxy.HealthInterval != tc.wantInterval {
+				t.Errorf("got health interval %v, want %v", c.Proxy.HealthInterval, tc.wantInterval)
+			}
+			if c.Cache.MaxEntries != tc.wantEntries {
+				t.Errorf("got max entries %d, want %d", c.Cache.MaxEntries, tc.wantEntries)
+			}
+		})
+	}
+}
+
+func TestLoad_DotEnv(t *testing.T) {
+	dir := t.TempDir()
+	dotenv := "PORT=9300\nPROXY_HEALTH_INTERVAL=45s\nCACHE_MAX_ENTRIES=\n"
+	if err := os.WriteFile(filepath.Join(dir, EnvFile), []byte(dotenv), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	yamlFile := writeFile(t, "config.yaml", `
+server:
+  port: "9000"
+`)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	testCases := []struct {
+		name         string
+		args         []string
+		env          map[string]string
+		wantPort     string
+		wantInterval time.Duration
+	}{
+		{".env over defaults", nil, nil, "9300", 45 * time.Second},
+		{"file over .env", []string{"--config", yamlFile}, nil, "9000", 45 * time.Second},
+		{"env over .env", nil, map[string]string{"PORT": "9100"}, "9100", 45 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setRequired(t)
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+
+			c, _, err := Load(tc.args)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			if c.Server.Port != tc.wantPort {
+				t.Errorf("got port %q, want %q", c.Server.Port, tc.wantPort)
+			}
+			if c.Proxy.HealthInterval != tc.wantInterval {
+				t.Errorf("got health interval %v, want %v", c.Proxy.HealthInterval, tc.wantInterval)
+			}
+			if c.Cache.MaxEntries != 1000 {
+				t.Errorf("got max entries %d, want the default for the empty value", c.Cache.MaxEntries)
+			}
+			if _, ok := os.LookupEnv("PROXY_HEALTH_INTERVAL"); ok {
+				t.Errorf("got PROXY_HEALTH_INTERVAL in the environment, want .env kept out of it")
+			}
+		})
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{"unknown flag", []string{"--nope"}, nil, "flag provided but not defined"},
+		{"bad env value", nil, map[string]string{"GEO_RETRIES": "many"}, "GEO_RETRIES"},
+		{"bad flag value", []string{"--proxy.health-interval", "soon"}, nil, "--proxy.health-interval"},
+		{"unknown file key", []string{"--config", writeFile(t, "c.yaml", "server:\n  prot: 1\n")}, nil, "prot"},
+		{"unknown file format", []string{"--config", writeFile(t, "c.ini", "")}, nil, ErrorFileFormat.Error()},
+		{"bad identities", nil, map[string]string{"TLS_CLIENT_IDENTITIES": "worker"}, "TLS_CLIENT_IDENTITIES"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			setRequired(t)
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+
+			_, _, err := Load(tc.args)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("got error %v, want it to contain %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad_AllErrors(t *testing.T) {
+	setRequired(t)
+	t.Setenv("GEO_RETRIES", "many")
+	t.Setenv("GEO_BREAKER_TIMEOUT", "5")
+	t.Setenv("METRICS_PORT", "abc")
+
+	c, _, err := Load([]string{"--proxy.health-interval", "soon"})
+	if c != nil {
+		t.Errorf("got config %+v, want nil for values that do not parse", c)
+	}
+	if err == nil {
+		t.Fatal("got nil error, want every bad value reported")
+	}
+
+	// a malformed value does not hide the ones after it
+	for _, key := range []string{"GEO_RETRIES", "GEO_BREAKER_TIMEOUT", "--proxy.health-interval", "metrics.port"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("got error %q, want it to mention %s", err, key)
+		}
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	c := Default()
+	c.Server.Port = "http"
+	c.Server.TLS.CertFile = "cert.pem"
+	c.Server.TLS.ClientIdentities = map[string]string{"worker": "geo-worker"}
+	c.Cache.Storage = "disk"
+	c.Cache.Dir = ""
+	c.OpenAPI.ServerURL = "geo.example.com"
+	c.OpenAPI.Validate = "always"
+	c.Metrics.Port = c.Server.TLS.Port
+	c.Geo.Timeout = 0
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("got nil error, want validation errors")
+	}
+
+	// every problem is reported, not just the first one
+	for _, key := range []string{
+		"server.port", "server.tls:", "server.tls.client_identities", "auth.jwt_secret",
+		"geo.api_key", "geo.secret_key", "geo.timeout", "cache.dir", "openapi.server_url", "openapi.validate",
+		"metrics.port",
+	} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("got error %q, want it to mention %s", err, key)
+		}
+	}
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	c := Default()
+	c.Auth.JwtSecret = "verysecret"
+	c.Geo.ApiKey = "key"
+	c.Server.TLS.ClientIdentities = map[string]string{"worker.internal": "geo-worker"}
+
+	var out bytes.Buffer
+	if err := c.Redacted().WriteYAML(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secret := range []string{"verysecret", "key\n"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("printed config contains secret %q:\n%s", secret, out.String())
+		}
+	}
+	if c.Auth.JwtSecret != "verysecret" {
+		t.Errorf("Redacted() changed the original config")
+	}
+
+	// the printed config is a valid config file
+	printed := Default()
+	if err := printed.readFile(writeFile(t, "printed.yaml", out.String())); err != nil {
+		t.Fatalf("reading printed config: %v", err)
+	}
+	if !reflect.DeepEqual(printed.Server.TLS.ClientIdentities, c.Server.TLS.ClientIdentities) {
+		t.Errorf("got identities %v, want %v", printed.Server.TLS.ClientIdentities, c.Server.TLS.ClientIdentities)
+	}
+	if printed.Proxy.StreamIdleTimeout != c.Proxy.StreamIdleTimeout {
+		t.Errorf("got stream idle timeout %v, want %v", printed.Proxy.StreamIdleTimeout, c.Proxy.StreamIdleTimeout)
+	}
+}
@@ -0,0 +1,262 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvFile holds local defaults when present: the config file, the
+// environment and flags all take precedence over it, and empty values in it
+// are ignored.
+const EnvFile = ".env"
+
+var ErrorFileFormat = errors.New("config file must be .yaml, .yml or .toml")
+
+// Load builds the configuration from, in increasing order of precedence,
+// the defaults, the .env file, the config file given by --config or
+// CONFIG_FILE, the environment and the command-line flags.
+//
+// A config that was read but failed validation is returned together with
+// the validation error, so that it can still be printed. Values that do not
+// parse return no config, with every parse and validation error joined.
+// printConfig reports whether --print-config was passed.
+func Load(args []string) (c *Config, printConfig bool, err error) {
+	fset := flag.NewFlagSet("geoservice", flag.ContinueOnError)
+	configFile := fset.String("config", "", "path to a YAML or TOML config file (env CONFIG_FILE)")
+	fset.BoolVar(&printConfig, "print-config", false, "print the resulting config with secrets redacted and exit")
+
+	c = Default()
+	flags := make(map[string]field)
+	walk(c, func(f field) error {
+		name := strings.ReplaceAll(f.key, "_", "-")
+		flags[name] = f
+		usage := "env " + f.tag.Get("env")
+		if value, ok := f.tag.Lookup("default"); ok {
+			usage += ", default " + value
+		}
+		fset.Var(&stringFlag{}, name, usage)
+		return nil
+	})
+
+	if err := fset.Parse(args); err != nil {
+		return nil, false, err
+	}
+
+	// every problem is reported at once: the values that do not parse along
+	// with those that fail validation
+	var errs []error
+	dotenv, err := godotenv.Read(EnvFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		errs = append(errs, fmt.Errorf("%s: %w", EnvFile, err))
+	}
+	lookupDotenv := func(name string) (string, bool) {
+		value := dotenv[name]
+		return value, value != ""
+	}
+	if err := c.readEnv(lookupDotenv); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", EnvFile, err))
+	}
+
+	if *configFile == "" {
+		*configFile = os.Getenv("CONFIG_FILE")
+	}
+	if *configFile == "" {
+		*configFile = dotenv["CONFIG_FILE"]
+	}
+	if *configFile != "" {
+		if err := c.readFile(*configFile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := c.readEnv(os.LookupEnv); err != nil {
+		errs = append(errs, err)
+	}
+
+	fset.Visit(func(fl *flag.Flag) {
+		f, ok := flags[fl.Name]
+		if !ok {
+			return
+		}
+		if err := f.set(fl.Value.String()); err != nil {
+			errs = append(errs, fmt.Errorf("--%s: %w", fl.Name, err))
+		}
+	})
+
+	if len(errs) > 0 {
+		return nil, printConfig, errors.Join(append(errs, c.Validate())...)
+	}
+	return c, printConfig, c.Validate()
+}
+
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, c)
+	case ".toml":
+		var meta toml.MetaData
+		meta, err = toml.Decode(string(data), c)
+		if undecoded := meta.Undecoded(); err == nil && len(undecoded) > 0 {
+			err = fmt.Errorf("unknown keys %v", undecoded)
+		}
+	default:
+		err = ErrorFileFormat
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// readEnv sets the fields with an env tag found by lookup, reporting every
+// value that does not parse.
+func (c *Config) readEnv(lookup func(string) (string, bool)) error {
+	var errs []error
+	walk(c, func(f field) error {
+		name := f.tag.Get("env")
+		value, ok := lookup(name)
+		if name == "" || !ok {
+			return nil
+		}
+
+		if err := f.set(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+		return nil
+	})
+	return errors.Join(errs...)
+}
+
+// set parses the textual form of a setting used by defaults, the
+// environment and flags. Lists are comma-separated and maps are
+// comma-separated key=value pairs.
+func (f field) set(value string) error {
+	switch f.value.Interface().(type) {
+	case string:
+		f.value.SetString(value)
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		f.value.SetBool(b)
+	case int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(n))
+	case float64:
+		f64, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		f.value.SetFloat(f64)
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(d))
+	case []string:
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(list))
+	case map[string]string:
+		m := make(map[string]string)
+		for _, pair := range strings.Split(value, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			k, v, ok := strings.Cut(pair, "=")
+			if !ok || k == "" || v == "" {
+				return fmt.Errorf("invalid key=value pair %q", pair)
+			}
+			m[k] = v
+		}
+		f.value.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("unsupported setting type %s", f.value.Type())
+	}
+	return nil
+}
+
+// stringFlag only records the raw flag value so that flags can be applied
+// last, after the config file they may point to has been read.
+type stringFlag struct {
+	value string
+}
+
+func (s *stringFlag) String() string {
+	return s.value
+}
+
+func (s *stringFlag) Set(value string) error {
+	s.value = value
+	return nil
+}
+
+// WriteYAML writes the config in the config file format. Call it on the
+// Redacted copy when the output may be seen by others.
+func (c *Config) WriteYAML(w io.Writer) error {
+	out, err := yaml.Marshal(toYAML(reflect.ValueOf(c).Elem()))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// toYAML keeps the field order of the struct and prints durations as
+// strings that the file loader reads back.
+func toYAML(v reflect.Value) interface{} {
+	switch value := v.Interface().(type) {
+	case time.Duration:
+		return value.String()
+	case map[string]string:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		ordered := yaml.MapSlice{}
+		for _, k := range keys {
+			ordered = append(ordered, yaml.MapItem{Key: k, Value: value[k]})
+		}
+		return ordered
+	}
+
+	if v.Kind() != reflect.Struct {
+		return v.Interface()
+	}
+
+	ordered := yaml.MapSlice{}
+	for i := 0; i < v.NumField(); i++ {
+		ordered = append(ordered, yaml.MapItem{Key: v.Type().Field(i).Tag.Get("yaml"), Value: toYAML(v.Field(i))})
+	}
+	return ordered
+}
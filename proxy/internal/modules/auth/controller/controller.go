@@ -1,46 +1,38 @@
 package controller
 
 import (
+	"errors"
+	"log/slog"
 	"net/http"
 	"proxy/internal/modules/auth/entities"
 	"proxy/internal/modules/auth/service"
+	"proxy/internal/utils/logging"
 	"proxy/internal/utils/readresponder"
 )
 
 type Auth struct {
 	authService   service.Authenticator
 	readResponder readresponder.ReadResponder
+	logger        *slog.Logger
 }
 
-func NewAuth(authService service.Authenticator, responder readresponder.ReadResponder) *Auth {
-	return &Auth{authService: authService, readResponder: responder}
+func NewAuth(authService service.Authenticator, responder readresponder.ReadResponder, logger *slog.Logger) *Auth {
+	return &Auth{authService: authService, readResponder: responder, logger: logger}
 }
 
 // Register godoc
 // @Summary register new user
 // @Description Register new user provided email address and passport
 // @Tags auth
-// @Accept json
-// @Produce json
+// @Accept json,application/msgpack,application/cbor
+// @Produce json,application/msgpack,application/cbor
 // @Param input body entities.User true "user credentials"
 // @Success 201 {object} readresponder.JSONResponse
-// @Failure 400 {object} readresponder.JSONResponse
-// @Router /api/register [post]
+// @Failure 400,406,413,415 {object} readresponder.JSONResponse
+// @Deprecated
+// @Router /api/v1/register [post]
 func (a *Auth) Register(w http.ResponseWriter, r *http.Request) {
-	var user entities.User
-
-	if err := a.readResponder.ReadJSON(w, r, &user); err != nil {
-		a.readResponder.WriteJSONError(w, err) // 400 status by default
-		return
-	}
-
-	if user.Password == "" || user.Email == "" {
-		a.readResponder.WriteJSONError(w, service.ErrorEOF)
-		return
-	}
-
-	if err := a.authService.Register(user); err != nil {
-		a.readResponder.WriteJSONError(w, err) // 400 status by default
+	if _, ok := a.register(w, r); !ok {
 		return
 	}
 
@@ -49,42 +41,106 @@ func (a *Auth) Register(w http.ResponseWriter, r *http.Request) {
 		Message: "user registered",
 	}
 
-	a.readResponder.WriteJSON(w, http.StatusCreated, responseBody)
+	a.readResponder.Write(w, r, http.StatusCreated, responseBody)
 }
 
 // Authenticate godoc
 // @Summary authenticate user
 // @Description Authenticate user provided their email and password
 // @Tags auth
-// @Accept json
-// @Produce json
+// @Accept json,application/msgpack,application/cbor
+// @Produce json,application/msgpack,application/cbor
 // @Param input body entities.User true "user credentials"
 // @Success 200 {object} readresponder.JSONResponse
-// @Failure 400,500 {object} readresponder.JSONResponse
-// @Router /api/login [post]
+// @Failure 400,406,413,415,500 {object} readresponder.JSONResponse
+// @Deprecated
+// @Router /api/v1/login [post]
 func (a *Auth) Authenticate(w http.ResponseWriter, r *http.Request) {
-	var user entities.User
-	if err := a.readResponder.ReadJSON(w, r, &user); err != nil {
-		a.readResponder.WriteJSONError(w, err)
+	tokenString, ok := a.authenticate(w, r)
+	if !ok {
 		return
 	}
 
-	if user.Password == "" || user.Email == "" {
-		a.readResponder.WriteJSONError(w, service.ErrorEOF)
-		return
+	resp := readresponder.JSONResponse{
+		Error:   false,
+		Message: "user authenticated",
 	}
-
-	tokenString, err := a.authService.Authenticate(user)
-	if err != nil {
-		a.readResponder.WriteJSONError(w, err)
-		return
+	if tokenString != "" {
+		resp.Data = tokenString
 	}
 
+	a.readResponder.Write(w, r, http.StatusOK, resp)
+}
+
+// Logout godoc
+// @Summary log user out
+// @Description Clear session cookies set on login
+// @Tags auth
+// @Produce json,application/msgpack,application/cbor
+// @Param X-CSRF-Token header string false "csrf token from the csrf_token cookie"
+// @Success 200 {object} readresponder.JSONResponse
+// @Failure 403 {string} string
+// @Deprecated
+// @Router /api/v1/logout [post]
+func (a *Auth) Logout(w http.ResponseWriter, r *http.Request) {
+	a.authService.EndSession(w)
+
 	resp := readresponder.JSONResponse{
 		Error:   false,
-		Message: "user authenticated",
-		Data:    tokenString,
+		Message: "user logged out",
+	}
+
+	a.readResponder.Write(w, r, http.StatusOK, resp)
+}
+
+// register registers the user in the body of r. On failure it writes the
+// error and returns false.
+func (a *Auth) register(w http.ResponseWriter, r *http.Request) (entities.User, bool) {
+	var user entities.User
+
+	if err := a.readResponder.Read(w, r, &user); err != nil {
+		a.readResponder.WriteError(w, r, err) // 400, 413 or 415 by the error
+		return user, false
+	}
+
+	if err := a.authService.Register(user); err != nil {
+		a.readResponder.WriteError(w, r, err) // 400 status by default
+		return user, false
+	}
+	a.logger.InfoContext(r.Context(), "user registered", "email", user.Email)
+
+	return user, true
+}
+
+// authenticate logs in the user in the body of r and returns the token to
+// hand out, which is empty in session mode. On failure it writes the error
+// and returns false.
+func (a *Auth) authenticate(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var user entities.User
+	if err := a.readResponder.Read(w, r, &user); err != nil {
+		a.readResponder.WriteError(w, r, err)
+		return "", false
 	}
 
-	a.readResponder.WriteJSON(w, http.StatusOK, resp)
+	logging.AddAttrs(r.Context(), slog.String("user", user.Email))
+
+	tokenString, err := a.authService.Authenticate(user)
+	if errors.Is(err, service.ErrorInvalidCredentials) {
+		a.logger.WarnContext(r.Context(), "login failed", "email", user.Email)
+	}
+	if err != nil {
+		a.readResponder.WriteError(w, r, err)
+		return "", false
+	}
+
+	// in session mode the token lives in an HttpOnly cookie and must not be exposed to scripts
+	if a.authService.SessionCookies() {
+		if err := a.authService.StartSession(w, tokenString); err != nil {
+			a.logger.ErrorContext(r.Context(), "failed to start session", "error", err)
+			a.readResponder.WriteError(w, r, err, http.StatusInternalServerError)
+			return "", false
+		}
+		return "", true
+	}
+	return tokenString, true
 }
@@ -9,6 +9,7 @@ import (
 	"proxy/internal/modules/auth/controller/mock_service"
 	"proxy/internal/modules/auth/entities"
 	"proxy/internal/modules/auth/service"
+	"proxy/internal/utils/logging"
 	"proxy/internal/utils/readresponder"
 	"reflect"
 	"testing"
@@ -26,16 +27,17 @@ func TestAuth_Register(t *testing.T) {
 		wantStatus  int
 		wantMessage string
 	}{
-		{"successful registry", entities.User{"some@user.com", "password"}, 201, "user registered"},
+		{"successful registry", entities.User{Email: "some@user.com", Password: "password"}, 201, "user registered"},
+		{"invalid user", entities.User{Email: "some.user.com", Password: "pw"}, 400, "email: must be a valid email address; password: must be at least 3 characters"},
 		{"user exists", mockUser, 400, service.ErrorUserExists.Error()},
-		{"wrong body", struct{ id int }{1}, 400, service.ErrorEOF.Error()},
+		{"wrong body", struct{ id int }{1}, 400, "email: is required; password: is required"},
 	}
 
 	controller := gomock.NewController(t)
 	defer controller.Finish()
 
 	mockService := NewMockService(controller)
-	auth := NewAuth(mockService, readresponder.NewReadRespond())
+	auth := NewAuth(mockService, readresponder.NewReadRespond(), logging.Discard())
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -77,15 +79,15 @@ func TestAuth_Authenticate(t *testing.T) {
 		wantMessage string
 	}{
 		{"successful authentication", mockUser, 200, "user authenticated"},
-		{"invalid credentials", entities.User{"test@test.com", "password"}, 400, service.ErrorInvalidCredentials.Error()},
-		{"wrong body", struct{ id int }{1}, 400, service.ErrorEOF.Error()},
+		{"invalid credentials", entities.User{Email: "test@test.com", Password: "password"}, 400, service.ErrorInvalidCredentials.Error()},
+		{"wrong body", struct{ id int }{1}, 400, "email: is required; password: is required"},
 	}
 
 	controller := gomock.NewController(t)
 	defer controller.Finish()
 
 	mockService := NewMockService(controller)
-	auth := NewAuth(mockService, readresponder.NewReadRespond())
+	auth := NewAuth(mockService, readresponder.NewReadRespond(), logging.Discard())
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -140,5 +142,7 @@ func NewMockService(controller *gomock.Controller) *mock_service.MockAuthenticat
 		}
 	}).AnyTimes()
 
+	mockService.EXPECT().SessionCookies().Return(false).AnyTimes()
+
 	return mockService
 }
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"net/http"
+	"proxy/internal/modules/auth/entities"
+	"proxy/internal/utils/readresponder"
+)
+
+// RegisterV2 godoc
+// @Summary register new user
+// @Description Register new user provided email address and password
+// @Tags auth
+// @Accept json,application/msgpack,application/cbor
+// @Produce json,application/msgpack,application/cbor
+// @Param input body entities.User true "user credentials"
+// @Success 201 {object} readresponder.Envelope{data=entities.Account}
+// @Failure 400,403,406,409,413,415,422 {object} readresponder.Problem "application/problem+json"
+// @Router /api/v2/register [post]
+func (a *Auth) RegisterV2(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.register(w, r)
+	if !ok {
+		return
+	}
+
+	resp := readresponder.Envelope{Data: entities.Account{Email: user.Email}}
+	a.readResponder.Write(w, r, http.StatusCreated, resp)
+}
+
+// AuthenticateV2 godoc
+// @Summary authenticate user
+// @Description Authenticate user provided their email and password. In session cookie mode the token is set as a cookie and left out of the response
+// @Tags auth
+// @Accept json,application/msgpack,application/cbor
+// @Produce json,application/msgpack,application/cbor
+// @Param input body entities.User true "user credentials"
+// @Success 200 {object} readresponder.Envelope{data=entities.Session}
+// @Failure 400,401,404,406,413,415,422,500 {object} readresponder.Problem "application/problem+json"
+// @Router /api/v2/login [post]
+func (a *Auth) AuthenticateV2(w http.ResponseWriter, r *http.Request) {
+	tokenString, ok := a.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	resp := readresponder.Envelope{Data: entities.Session{Token: tokenString}}
+	a.readResponder.Write(w, r, http.StatusOK, resp)
+}
+
+// LogoutV2 godoc
+// @Summary log user out
+// @Description Clear session cookies set on login
+// @Tags auth
+// @Param X-CSRF-Token header string false "csrf token from the csrf_token cookie"
+// @Success 204
+// @Failure 403 {string} string
+// @Router /api/v2/logout [post]
+func (a *Auth) LogoutV2(w http.ResponseWriter, r *http.Request) {
+	a.authService.EndSession(w)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -5,4 +5,8 @@ import "net/http"
 type Authenticator interface {
 	Register(w http.ResponseWriter, r *http.Request)
 	Authenticate(w http.ResponseWriter, r *http.Request)
+	Logout(w http.ResponseWriter, r *http.Request)
+	RegisterV2(w http.ResponseWriter, r *http.Request)
+	AuthenticateV2(w http.ResponseWriter, r *http.Request)
+	LogoutV2(w http.ResponseWriter, r *http.Request)
 }
@@ -50,6 +50,18 @@ func (mr *MockAuthenticatorMockRecorder) Authenticate(user interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authenticate", reflect.TypeOf((*MockAuthenticator)(nil).Authenticate), user)
 }
 
+// EndSession mocks base method.
+func (m *MockAuthenticator) EndSession(w http.ResponseWriter) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "EndSession", w)
+}
+
+// EndSession indicates an expected call of EndSession.
+func (mr *MockAuthenticatorMockRecorder) EndSession(w interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EndSession", reflect.TypeOf((*MockAuthenticator)(nil).EndSession), w)
+}
+
 // Register mocks base method.
 func (m *MockAuthenticator) Register(user entities.User) error {
 	m.ctrl.T.Helper()
@@ -64,6 +76,20 @@ func (mr *MockAuthenticatorMockRecorder) Register(user interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Register", reflect.TypeOf((*MockAuthenticator)(nil).Register), user)
 }
 
+// RequireAdmin mocks base method.
+func (m *MockAuthenticator) RequireAdmin(arg0 http.Handler) http.Handler {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequireAdmin", arg0)
+	ret0, _ := ret[0].(http.Handler)
+	return ret0
+}
+
+// RequireAdmin indicates an expected call of RequireAdmin.
+func (mr *MockAuthenticatorMockRecorder) RequireAdmin(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequireAdmin", reflect.TypeOf((*MockAuthenticator)(nil).RequireAdmin), arg0)
+}
+
 // RequireAuthentication mocks base method.
 func (m *MockAuthenticator) RequireAuthentication(arg0 http.Handler) http.Handler {
 	m.ctrl.T.Helper()
@@ -77,3 +103,45 @@ func (mr *MockAuthenticatorMockRecorder) RequireAuthentication(arg0 interface{})
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequireAuthentication", reflect.TypeOf((*MockAuthenticator)(nil).RequireAuthentication), arg0)
 }
+
+// RequireCSRF mocks base method.
+func (m *MockAuthenticator) RequireCSRF(arg0 http.Handler) http.Handler {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequireCSRF", arg0)
+	ret0, _ := ret[0].(http.Handler)
+	return ret0
+}
+
+// RequireCSRF indicates an expected call of RequireCSRF.
+func (mr *MockAuthenticatorMockRecorder) RequireCSRF(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequireCSRF", reflect.TypeOf((*MockAuthenticator)(nil).RequireCSRF), arg0)
+}
+
+// SessionCookies mocks base method.
+func (m *MockAuthenticator) SessionCookies() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SessionCookies")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// SessionCookies indicates an expected call of SessionCookies.
+func (mr *MockAuthenticatorMockRecorder) SessionCookies() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SessionCookies", reflect.TypeOf((*MockAuthenticator)(nil).SessionCookies))
+}
+
+// StartSession mocks base method.
+func (m *MockAuthenticator) StartSession(w http.ResponseWriter, token string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartSession", w, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StartSession indicates an expected call of StartSession.
+func (mr *MockAuthenticatorMockRecorder) StartSession(w, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartSession", reflect.TypeOf((*MockAuthenticator)(nil).StartSession), w, token)
+}
@@ -1,6 +1,57 @@
 package entities
 
+import "time"
+
+// RoleAdmin grants access to the admin routes, like ADMIN_EMAILS.
+const RoleAdmin = "admin"
+
+// Roles are the roles users can be assigned.
+var Roles = []string{RoleAdmin}
+
 type User struct {
-	Email    string `json:"email" binding:"required" example:"admin@example.com"`
-	Password string `json:"password" binding:"required" example:"password"`
+	Email    string `json:"email" binding:"required,email,max=32" format:"email" example:"admin@example.com"`
+	Password string `json:"password" binding:"required,min=3,max=32" example:"password"`
+	// Roles and APIKeys are only managed with geoadmin, never from requests.
+	Roles   []string `json:"-"`
+	APIKeys []APIKey `json:"-"`
+}
+
+// APIKey authenticates scripts as its user in the X-API-Key header. Only
+// the hash of the secret is stored; the key itself is shown once, when
+// geoadmin creates it.
+type APIKey struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name,omitempty"`
+	SecretHash string    `json:"secret_hash"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// UserRecord is a user as stored in the users file and exported by
+// geoadmin, with the password hashed.
+type UserRecord struct {
+	Email        string   `json:"email"`
+	PasswordHash string   `json:"password_hash"`
+	Roles        []string `json:"roles,omitempty"`
+	APIKeys      []APIKey `json:"api_keys,omitempty"`
+}
+
+// Record is the stored form of a user whose Password is already hashed.
+func (u User) Record() UserRecord {
+	return UserRecord{Email: u.Email, PasswordHash: u.Password, Roles: u.Roles, APIKeys: u.APIKeys}
+}
+
+// User is the user of a record, with the hash as Password.
+func (r UserRecord) User() User {
+	return User{Email: r.Email, Password: r.PasswordHash, Roles: r.Roles, APIKeys: r.APIKeys}
+}
+
+// Account is the registered user, without the password.
+type Account struct {
+	Email string `json:"email" example:"admin@example.com"`
+}
+
+// Session is the result of a login. Token is empty in session cookie
+// mode, where the token is set as an HttpOnly cookie instead.
+type Session struct {
+	Token string `json:"token,omitempty" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
 }
@@ -0,0 +1,79 @@
+package dbrepo
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"proxy/internal/modules/auth/entities"
+	"sort"
+	"sync"
+)
+
+type MapDBRepo struct {
+	store map[string]entities.User
+	m     sync.RWMutex
+}
+
+func NewMapDBRepo(initUsers ...entities.User) *MapDBRepo {
+	store := make(map[string]entities.User)
+
+	for _, user := range initUsers {
+		password, _ := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+		user.Password = string(password)
+		store[user.Email] = user
+	}
+
+	return &MapDBRepo{store: store}
+}
+
+func (db *MapDBRepo) GetUserByEmail(userEmail string) (entities.User, error) {
+	db.m.RLock() // block for writing
+	defer db.m.RUnlock()
+
+	if user, ok := db.store[userEmail]; ok {
+		return user, nil
+	}
+	return entities.User{}, errors.New("user not found")
+}
+
+func (db *MapDBRepo) InsertUser(user entities.User) error {
+	db.m.Lock() // block for reading and writing
+	defer db.m.Unlock()
+
+	db.store[user.Email] = user
+
+	return nil
+}
+
+func (db *MapDBRepo) ListUsers() ([]entities.User, error) {
+	db.m.RLock()
+	defer db.m.RUnlock()
+
+	return sortedUsers(db.store), nil
+}
+
+// Ping only checks that the store is not locked up by a writer.
+func (db *MapDBRepo) Ping(ctx context.Context) error {
+	locked := make(chan struct{})
+	go func() {
+		db.m.RLock()
+		db.m.RUnlock()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func sortedUsers(store map[string]entities.User) []entities.User {
+	users := make([]entities.User, 0, len(store))
+	for _, user := range store {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Email < users[j].Email })
+	return users
+}
@@ -0,0 +1,162 @@
+package dbrepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"proxy/internal/modules/auth/entities"
+	"sync"
+	"time"
+)
+
+// FileDBRepo keeps the users in a JSON file, so that they survive restarts
+// and geoadmin can manage them while the server runs. The file is read
+// again whenever another process replaced it, and every write replaces it
+// at once.
+type FileDBRepo struct {
+	path  string
+	store map[string]entities.User
+	// modTime and size identify the version of the file in store
+	modTime time.Time
+	size    int64
+	m       sync.Mutex
+}
+
+// NewFileDBRepo loads the users file at path. A missing file is an empty
+// repository, created on the first insert.
+func NewFileDBRepo(path string) (*FileDBRepo, error) {
+	db := &FileDBRepo{path: path, store: make(map[string]entities.User)}
+	if err := db.reload(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *FileDBRepo) GetUserByEmail(userEmail string) (entities.User, error) {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	if err := db.reload(); err != nil {
+		return entities.User{}, err
+	}
+	if user, ok := db.store[userEmail]; ok {
+		return user, nil
+	}
+	return entities.User{}, errors.New("user not found")
+}
+
+func (db *FileDBRepo) InsertUser(user entities.User) error {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	if err := db.reload(); err != nil {
+		return err
+	}
+
+	previous, existed := db.store[user.Email]
+	db.store[user.Email] = user
+	if err := db.write(); err != nil {
+		// keep the store in line with the file
+		if existed {
+			db.store[user.Email] = previous
+		} else {
+			delete(db.store, user.Email)
+		}
+		return err
+	}
+	return nil
+}
+
+func (db *FileDBRepo) ListUsers() ([]entities.User, error) {
+	db.m.Lock()
+	defer db.m.Unlock()
+
+	if err := db.reload(); err != nil {
+		return nil, err
+	}
+	return sortedUsers(db.store), nil
+}
+
+// Ping checks that the users file can still be read.
+func (db *FileDBRepo) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.m.Lock()
+	defer db.m.Unlock()
+	return db.reload()
+}
+
+// reload reads the file again if it changed since it was last read or
+// written.
+func (db *FileDBRepo) reload() error {
+	info, err := os.Stat(db.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		db.store, db.modTime, db.size = make(map[string]entities.User), time.Time{}, 0
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(db.modTime) && info.Size() == db.size {
+		return nil
+	}
+
+	data, err := os.ReadFile(db.path)
+	if err != nil {
+		return err
+	}
+	var records []entities.UserRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return fmt.Errorf("%s: %w", db.path, err)
+	}
+
+	store := make(map[string]entities.User, len(records))
+	for _, record := range records {
+		store[record.Email] = record.User()
+	}
+	db.store, db.modTime, db.size = store, info.ModTime(), info.Size()
+	return nil
+}
+
+// write replaces the file with the store, readable by its owner only as it
+// holds password hashes.
+func (db *FileDBRepo) write() error {
+	records := make([]entities.UserRecord, 0, len(db.store))
+	for _, user := range sortedUsers(db.store) {
+		records = append(records, user.Record())
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(db.path), ".users-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), db.path); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(db.path)
+	if err != nil {
+		return err
+	}
+	db.modTime, db.size = info.ModTime(), info.Size()
+	return nil
+}
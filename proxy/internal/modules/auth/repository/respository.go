@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+	"proxy/internal/modules/auth/entities"
+)
+
+type DatabaseRepo interface {
+	GetUserByEmail(string) (entities.User, error)
+	// InsertUser adds the user, or replaces the one with the same email.
+	InsertUser(entities.User) error
+	// ListUsers returns every user, sorted by email.
+	ListUsers() ([]entities.User, error)
+	// Ping reports whether the repository can serve requests.
+	Ping(ctx context.Context) error
+}
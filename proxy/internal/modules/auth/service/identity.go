@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"net/http"
+)
+
+type identityKey struct{}
+
+// WithClientIdentities authenticates internal callers by a verified TLS
+// client certificate. Certificate names (the subject common name or any
+// DNS/URI SAN) are mapped to the identity the caller acts as.
+func WithClientIdentities(identities map[string]string) UserAuthOption {
+	return func(a *UserAuth) {
+		a.identities = identities
+	}
+}
+
+// clientIdentity returns the identity mapped to the request's verified client
+// certificate, if any.
+func (a *UserAuth) clientIdentity(r *http.Request) (string, bool) {
+	if len(a.identities) == 0 || r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return "", false
+	}
+
+	leaf := r.TLS.VerifiedChains[0][0]
+	names := append([]string{leaf.Subject.CommonName}, leaf.DNSNames...)
+	for _, uri := range leaf.URIs {
+		names = append(names, uri.String())
+	}
+
+	for _, name := range names {
+		if identity, ok := a.identities[name]; ok && name != "" {
+			return identity, true
+		}
+	}
+	return "", false
+}
+
+// IdentityFromContext returns the identity of a caller authenticated by
+// client certificate.
+func IdentityFromContext(ctx context.Context) (string, bool) {
+	identity, ok := ctx.Value(identityKey{}).(string)
+	return identity, ok
+}
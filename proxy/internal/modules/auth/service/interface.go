@@ -9,5 +9,10 @@ import (
 type Authenticator interface {
 	Register(user entities.User) error
 	Authenticate(user entities.User) (string, error)
+	SessionCookies() bool
+	StartSession(w http.ResponseWriter, token string) error
+	EndSession(w http.ResponseWriter)
 	RequireAuthentication(http.Handler) http.Handler
+	RequireCSRF(http.Handler) http.Handler
+	RequireAdmin(http.Handler) http.Handler
 }
@@ -0,0 +1,34 @@
+package service
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const (
+	methodLogin       = "login"
+	methodToken       = "token"
+	methodCertificate = "certificate"
+	methodAPIKey      = "api_key"
+)
+
+// WithMetrics registers the counts of successful and failed logins and
+// request authentications with reg.
+func WithMetrics(reg prometheus.Registerer) UserAuthOption {
+	return func(a *UserAuth) {
+		a.attempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "auth_attempts_total",
+			Help: "Authentication attempts by method and result.",
+		}, []string{"method", "result"})
+		reg.MustRegister(a.attempts)
+	}
+}
+
+func (a *UserAuth) observe(method string, ok bool) {
+	if a.attempts == nil {
+		return
+	}
+
+	result := "success"
+	if !ok {
+		result = "failure"
+	}
+	a.attempts.WithLabelValues(method, result).Inc()
+}
@@ -5,6 +5,7 @@
 package mock_repository
 
 import (
+	context "context"
 	entities "proxy/internal/modules/auth/entities"
 	reflect "reflect"
 
@@ -62,3 +63,32 @@ func (mr *MockDatabaseRepoMockRecorder) InsertUser(arg0 interface{}) *gomock.Cal
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertUser", reflect.TypeOf((*MockDatabaseRepo)(nil).InsertUser), arg0)
 }
+
+// ListUsers mocks base method.
+func (m *MockDatabaseRepo) ListUsers() ([]entities.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsers")
+	ret0, _ := ret[0].([]entities.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsers indicates an expected call of ListUsers.
+func (mr *MockDatabaseRepoMockRecorder) ListUsers() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsers", reflect.TypeOf((*MockDatabaseRepo)(nil).ListUsers))
+}
+
+// Ping mocks base method.
+func (m *MockDatabaseRepo) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockDatabaseRepoMockRecorder) Ping(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockDatabaseRepo)(nil).Ping), ctx)
+}
@@ -1,21 +1,43 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/go-chi/jwtauth/v5"
+	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/crypto/bcrypt"
+	"log/slog"
 	"net/http"
 	"proxy/internal/modules/auth/entities"
 	"proxy/internal/modules/auth/repository"
+	"proxy/internal/utils/logging"
+	"slices"
 )
 
 type UserAuth struct {
-	DB        repository.DatabaseRepo
-	tokenAuth *jwtauth.JWTAuth
+	DB              repository.DatabaseRepo
+	tokenAuth       *jwtauth.JWTAuth
+	sessionCookies  bool
+	insecureCookies bool
+	admins          map[string]bool
+	identities      map[string]string
+	attempts        *prometheus.CounterVec
 }
 
 type Claims map[string]interface{}
 
+type UserAuthOption func(*UserAuth)
+
+// WithAdmins grants admin privileges to the users with the given emails.
+func WithAdmins(emails ...string) UserAuthOption {
+	return func(a *UserAuth) {
+		for _, email := range emails {
+			a.admins[email] = true
+		}
+	}
+}
+
 var (
 	ErrorBadPassword        = errors.New("password must be between 3 and 32 characters")
 	ErrorBadEmail           = errors.New("email must be between 5 and 32 characters")
@@ -23,35 +45,48 @@ var (
 	ErrorUserNotFound       = errors.New("user not found")
 	ErrorInvalidCredentials = errors.New("invalid credentials")
 	ErrorEOF                = errors.New("EOF")
+	ErrorNotAdmin           = errors.New("admin privileges required")
+	ErrorUnknownRole        = errors.New("unknown role")
+	ErrorReservedEmail      = errors.New("email is reserved")
 )
 
-func NewUserAuth(algorithm, secret string, db repository.DatabaseRepo) *UserAuth {
+func NewUserAuth(algorithm, secret string, db repository.DatabaseRepo, options ...UserAuthOption) *UserAuth {
 	tokenAuth := jwtauth.New(algorithm, []byte(secret), nil)
 
 	userAuth := &UserAuth{
 		DB:        db,
 		tokenAuth: tokenAuth,
+		admins:    make(map[string]bool),
+	}
+
+	for _, option := range options {
+		option(userAuth)
 	}
 
 	return userAuth
 }
 
 func (a *UserAuth) Register(user entities.User) error {
-	if len(user.Password) < 3 || len(user.Password) > 32 {
-		return ErrorBadPassword
+	encryptedPassword, err := hashPassword(user.Password)
+	if err != nil {
+		return err
 	}
 
 	if len(user.Email) < 5 || len(user.Email) > 32 {
 		return ErrorBadEmail
 	}
 
+	// whoever registered an admin email first would become the admin
+	if a.admins[user.Email] {
+		return ErrorReservedEmail
+	}
+
 	if _, err := a.DB.GetUserByEmail(user.Email); err == nil {
 		return ErrorUserExists
 	}
 
 	var newUser entities.User
-	encryptedPassword, _ := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
-	newUser.Password = string(encryptedPassword)
+	newUser.Password = encryptedPassword
 	newUser.Email = user.Email
 
 	if err := a.DB.InsertUser(newUser); err != nil {
@@ -61,7 +96,55 @@ func (a *UserAuth) Register(user entities.User) error {
 	return nil
 }
 
-func (a *UserAuth) Authenticate(userQuery entities.User) (string, error) {
+// ResetPassword replaces the password of an existing user.
+func (a *UserAuth) ResetPassword(email, password string) error {
+	encryptedPassword, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	user, err := a.DB.GetUserByEmail(email)
+	if err != nil {
+		return ErrorUserNotFound
+	}
+	user.Password = encryptedPassword
+
+	return a.DB.InsertUser(user)
+}
+
+// SetRoles replaces the roles of an existing user; none revokes them all.
+func (a *UserAuth) SetRoles(email string, roles ...string) error {
+	for _, role := range roles {
+		if !slices.Contains(entities.Roles, role) {
+			return fmt.Errorf("%w %q", ErrorUnknownRole, role)
+		}
+	}
+
+	user, err := a.DB.GetUserByEmail(email)
+	if err != nil {
+		return ErrorUserNotFound
+	}
+	roles = slices.Clone(roles)
+	slices.Sort(roles)
+	user.Roles = slices.Compact(roles)
+
+	return a.DB.InsertUser(user)
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < 3 || len(password) > 32 {
+		return "", ErrorBadPassword
+	}
+
+	encryptedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(encryptedPassword), err
+}
+
+func (a *UserAuth) Authenticate(userQuery entities.User) (token string, err error) {
+	defer func() {
+		a.observe(methodLogin, err == nil)
+	}()
+
 	user, err := a.DB.GetUserByEmail(userQuery.Email)
 	if errors.Is(err, ErrorUserNotFound) {
 		return "", ErrorInvalidCredentials
@@ -82,15 +165,77 @@ func (a *UserAuth) Authenticate(userQuery entities.User) (string, error) {
 	return tokenString, nil
 }
 
+// RequireAuthentication accepts a verified JWT, an API key in the X-API-Key
+// header or a client certificate mapped to an identity with
+// WithClientIdentities.
 func (a *UserAuth) RequireAuthentication(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if identity, ok := a.clientIdentity(r); ok {
+			a.observe(methodCertificate, true)
+			logging.AddAttrs(r.Context(), slog.String("user", identity))
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, identity)))
+			return
+		}
+
+		if key := r.Header.Get(APIKeyHeader); key != "" {
+			user, err := a.authenticateAPIKey(key)
+			a.observe(methodAPIKey, err == nil)
+			if errors.Is(err, ErrorInvalidAPIKey) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			} else if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			// the handlers and RequireAdmin read the user from the token claims
+			token, _, err := a.tokenAuth.Encode(Claims{"email": user.Email})
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			logging.AddAttrs(r.Context(), slog.String("user", user.Email))
+			next.ServeHTTP(w, r.WithContext(jwtauth.NewContext(r.Context(), token, nil)))
+			return
+		}
+
 		token, err := jwtauth.VerifyRequest(a.tokenAuth, r, jwtauth.TokenFromCookie, jwtauth.TokenFromHeader)
 
+		a.observe(methodToken, err == nil && token != nil)
 		if err != nil || token == nil {
 			http.Error(w, err.Error(), http.StatusForbidden)
 			return
 		}
 
+		if email, ok := token.Get("email"); ok {
+			logging.AddAttrs(r.Context(), slog.Any("user", email))
+		}
+		next.ServeHTTP(w, r.WithContext(jwtauth.NewContext(r.Context(), token, nil)))
+	})
+}
+
+// RequireAdmin lets through only users listed as admins or given the admin
+// role. It must run after RequireAuthentication, which puts the verified
+// token into the context.
+func (a *UserAuth) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, claims, err := jwtauth.FromContext(r.Context())
+		email, _ := claims["email"].(string)
+
+		if err != nil || !a.isAdmin(email) {
+			http.Error(w, ErrorNotAdmin.Error(), http.StatusForbidden)
+			return
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
+
+func (a *UserAuth) isAdmin(email string) bool {
+	if a.admins[email] {
+		return true
+	}
+
+	user, err := a.DB.GetUserByEmail(email)
+	return err == nil && slices.Contains(user.Roles, entities.RoleAdmin)
+}
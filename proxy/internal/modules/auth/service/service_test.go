@@ -1,11 +1,19 @@
 package service
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"errors"
 	"github.com/golang/mock/gomock"
 	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
 	"proxy/internal/modules/auth/entities"
+	"proxy/internal/modules/auth/repository/dbrepo"
 	"proxy/internal/modules/auth/service/mock_repository"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -14,23 +22,30 @@ var mockUser = entities.User{
 	Password: "password",
 }
 
+var roleUser = entities.User{
+	Email:    "editor@example.com",
+	Password: "password",
+	Roles:    []string{entities.RoleAdmin},
+}
+
 func TestUserAuth_Register(t *testing.T) {
 	testCases := []struct {
 		name    string
 		user    entities.User
 		wantErr error
 	}{
-		{"incorrect password", entities.User{"test@test.com", "ps"}, ErrorBadPassword},
-		{"incorrect email", entities.User{"te", "password"}, ErrorBadEmail},
+		{"incorrect password", entities.User{Email: "test@test.com", Password: "ps"}, ErrorBadPassword},
+		{"incorrect email", entities.User{Email: "te", Password: "password"}, ErrorBadEmail},
 		{"existing user", mockUser, ErrorUserExists},
-		{"successful case", entities.User{"test@test.com", "password"}, nil},
+		{"admin email", entities.User{Email: "root@example.com", Password: "password"}, ErrorReservedEmail},
+		{"successful case", entities.User{Email: "test@test.com", Password: "password"}, nil},
 	}
 
 	controller := gomock.NewController(t)
 	defer controller.Finish()
 
 	mockDb := NewMockDb(controller)
-	userAuth := NewUserAuth("HS256", "verysecret", mockDb)
+	userAuth := NewUserAuth("HS256", "verysecret", mockDb, WithAdmins("root@example.com"))
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -48,8 +63,8 @@ func TestUserAuth_Authenticate(t *testing.T) {
 		user    entities.User
 		wantErr error
 	}{
-		{"non-existing user", entities.User{"test@test.com", "password"}, ErrorInvalidCredentials},
-		{"invalid password", entities.User{mockUser.Email, "agoajgeoh"}, ErrorInvalidCredentials},
+		{"non-existing user", entities.User{Email: "test@test.com", Password: "password"}, ErrorInvalidCredentials},
+		{"invalid password", entities.User{Email: mockUser.Email, Password: "agoajgeoh"}, ErrorInvalidCredentials},
 		{"successful case", mockUser, nil},
 	}
 
@@ -69,20 +84,328 @@ func TestUserAuth_Authenticate(t *testing.T) {
 	}
 }
 
-func NewMockDb(controller *gomock.Controller) *mock_repository.MockDatabaseRepo {
+func TestUserAuth_RequireCSRF(t *testing.T) {
+	testCases := []struct {
+		name       string
+		method     string
+		cookies    []*http.Cookie
+		headers    map[string]string
+		wantStatus int
+	}{
+		{"safe method", http.MethodGet, []*http.Cookie{{Name: SessionCookie, Value: "token"}}, nil, 200},
+		{"bearer token", http.MethodPost, []*http.Cookie{{Name: SessionCookie, Value: "token"}}, map[string]string{"Authorization": "Bearer token"}, 200},
+		{"api key", http.MethodPost, []*http.Cookie{{Name: SessionCookie, Value: "token"}}, map[string]string{APIKeyHeader: "gk_key"}, 200},
+		{"missing csrf header", http.MethodPost, []*http.Cookie{{Name: SessionCookie, Value: "token"}, {Name: CSRFCookie, Value: "csrf"}}, nil, 403},
+		{"mismatched csrf header", http.MethodPost, []*http.Cookie{{Name: SessionCookie, Value: "token"}, {Name: CSRFCookie, Value: "csrf"}}, map[string]string{CSRFHeader: "other"}, 403},
+		{"matching csrf header", http.MethodPost, []*http.Cookie{{Name: SessionCookie, Value: "token"}, {Name: CSRFCookie, Value: "csrf"}}, map[string]string{CSRFHeader: "csrf"}, 200},
+	}
+
+	userAuth := NewUserAuth("HS256", "verysecret", nil, WithSessionCookies())
+	handler := userAuth.RequireCSRF(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/api/address/search", nil)
+			for _, cookie := range tc.cookies {
+				req.AddCookie(cookie)
+			}
+			for key, value := range tc.headers {
+				req.Header.Set(key, value)
+			}
+			wr := httptest.NewRecorder()
+
+			handler.ServeHTTP(wr, req)
+
+			if wr.Code != tc.wantStatus {
+				t.Errorf("got status code %d, want %d", wr.Code, tc.wantStatus)
+			}
+		})
+	}
+}
+
+func TestUserAuth_RequireAdmin(t *testing.T) {
+	userAuth := NewUserAuth("HS256", "verysecret", NewMockDb(gomock.NewController(t)), WithAdmins(mockUser.Email))
+	_, adminToken, _ := userAuth.tokenAuth.Encode(Claims{"email": mockUser.Email})
+	_, roleToken, _ := userAuth.tokenAuth.Encode(Claims{"email": roleUser.Email})
+	_, userToken, _ := userAuth.tokenAuth.Encode(Claims{"email": "test@test.com"})
+
+	testCases := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{"admin", adminToken, 200},
+		{"admin role", roleToken, 200},
+		{"regular user", userToken, 403},
+		{"no token", "", 403},
+	}
+
+	handler := userAuth.RequireAuthentication(userAuth.RequireAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/cache/purge", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			wr := httptest.NewRecorder()
+
+			handler.ServeHTTP(wr, req)
+
+			if wr.Code != tc.wantStatus {
+				t.Errorf("got status code %d, want %d", wr.Code, tc.wantStatus)
+			}
+		})
+	}
+}
+
+func TestUserAuth_APIKeys(t *testing.T) {
+	userAuth := NewUserAuth("HS256", "verysecret", dbrepo.NewMapDBRepo())
+	if err := userAuth.Register(entities.User{Email: roleUser.Email, Password: "password"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := userAuth.SetRoles(roleUser.Email, entities.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	key, apiKey, err := userAuth.CreateAPIKey(roleUser.Email, "deploy")
+	if err != nil {
+		t.Fatalf("CreateAPIKey() error = %v", err)
+	}
+	user, _ := userAuth.DB.GetUserByEmail(roleUser.Email)
+	if len(user.APIKeys) != 1 || user.APIKeys[0] != apiKey || !strings.HasPrefix(key, "gk_"+apiKey.ID+"_") {
+		t.Errorf("got key %q and stored keys %v, want %v", key, user.APIKeys, apiKey)
+	}
+	if _, _, err := userAuth.CreateAPIKey("test@test.com", "deploy"); !errors.Is(err, ErrorUserNotFound) {
+		t.Errorf("CreateAPIKey() of unknown user error = %v, want %v", err, ErrorUserNotFound)
+	}
+
+	handler := userAuth.RequireAuthentication(userAuth.RequireAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+	status := func(key string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/cache/purge", nil)
+		req.Header.Set(APIKeyHeader, key)
+		wr := httptest.NewRecorder()
+		handler.ServeHTTP(wr, req)
+		return wr.Code
+	}
+
+	testCases := []struct {
+		name       string
+		key        string
+		wantStatus int
+	}{
+		{"valid key", key, 200},
+		{"wrong secret", key[:len(key)-4] + "AAAA", 403},
+		{"unknown id", "gk_0000000000000000_" + key[len(key)-43:], 403},
+		{"malformed key", "deploy", 403},
+	}
+	for _, tc := range testCases {
+		if got := status(tc.key); got != tc.wantStatus {
+			t.Errorf("%s: got status code %d, want %d", tc.name, got, tc.wantStatus)
+		}
+	}
+
+	if err := userAuth.RevokeAPIKey(roleUser.Email, "unknown"); !errors.Is(err, ErrorAPIKeyNotFound) {
+		t.Errorf("RevokeAPIKey() of unknown key error = %v, want %v", err, ErrorAPIKeyNotFound)
+	}
+	if err := userAuth.RevokeAPIKey(roleUser.Email, apiKey.ID); err != nil {
+		t.Fatalf("RevokeAPIKey() error = %v", err)
+	}
+	if got := status(key); got != 403 {
+		t.Errorf("got status code %d with a revoked key, want 403", got)
+	}
+}
+
+func TestUserAuth_ResetPassword(t *testing.T) {
+	testCases := []struct {
+		name     string
+		email    string
+		password string
+		wantErr  error
+	}{
+		{"successful case", mockUser.Email, "newpassword", nil},
+		{"incorrect password", mockUser.Email, "ps", ErrorBadPassword},
+		{"non-existing user", "test@test.com", "newpassword", ErrorUserNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var inserted entities.User
+			mockDb := NewMockDb(gomock.NewController(t), func(user entities.User) { inserted = user })
+			userAuth := NewUserAuth("HS256", "verysecret", mockDb)
+
+			err := userAuth.ResetPassword(tc.email, tc.password)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("ResetPassword() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if bcrypt.CompareHashAndPassword([]byte(inserted.Password), []byte(tc.password)) != nil {
+				t.Errorf("got user %v, want the new password hashed", inserted)
+			}
+		})
+	}
+}
+
+func TestUserAuth_SetRoles(t *testing.T) {
+	testCases := []struct {
+		name      string
+		email     string
+		roles     []string
+		wantRoles []string
+		wantErr   error
+	}{
+		{"assign", mockUser.Email, []string{entities.RoleAdmin, entities.RoleAdmin}, []string{entities.RoleAdmin}, nil},
+		{"revoke", roleUser.Email, nil, nil, nil},
+		{"unknown role", mockUser.Email, []string{"root"}, nil, ErrorUnknownRole},
+		{"non-existing user", "test@test.com", []string{entities.RoleAdmin}, nil, ErrorUserNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var inserted entities.User
+			mockDb := NewMockDb(gomock.NewController(t), func(user entities.User) { inserted = user })
+			userAuth := NewUserAuth("HS256", "verysecret", mockDb)
+
+			err := userAuth.SetRoles(tc.email, tc.roles...)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("SetRoles() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if inserted.Email != tc.email || !reflect.DeepEqual(inserted.Roles, tc.wantRoles) {
+				t.Errorf("got user %v with roles %v, want %s with roles %v", inserted.Email, inserted.Roles, tc.email, tc.wantRoles)
+			}
+		})
+	}
+}
+
+func TestUserAuth_StartSession(t *testing.T) {
+	testCases := []struct {
+		name       string
+		options    []UserAuthOption
+		wantSecure bool
+	}{
+		{"https", []UserAuthOption{WithSessionCookies()}, true},
+		{"plain http", []UserAuthOption{WithSessionCookies(), WithInsecureCookies()}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			userAuth := NewUserAuth("HS256", "verysecret", nil, tc.options...)
+			wr := httptest.NewRecorder()
+
+			if err := userAuth.StartSession(wr, "token"); err != nil {
+				t.Fatalf("StartSession() error = %v", err)
+			}
+
+			cookies := make(map[string]*http.Cookie)
+			for _, cookie := range wr.Result().Cookies() {
+				cookies[cookie.Name] = cookie
+			}
+
+			session, ok := cookies[SessionCookie]
+			if !ok || session.Value != "token" || !session.HttpOnly || session.SameSite != http.SameSiteStrictMode {
+				t.Errorf("got session cookie %+v, want HttpOnly, SameSite=Strict token cookie", session)
+			}
+
+			csrf, ok := cookies[CSRFCookie]
+			if !ok || csrf.Value == "" || csrf.HttpOnly {
+				t.Errorf("got csrf cookie %+v, want non-empty cookie readable by scripts", csrf)
+			}
+
+			for _, cookie := range []*http.Cookie{session, csrf} {
+				if cookie != nil && cookie.Secure != tc.wantSecure {
+					t.Errorf("got %s cookie Secure %v, want %v", cookie.Name, cookie.Secure, tc.wantSecure)
+				}
+			}
+
+			wr = httptest.NewRecorder()
+			userAuth.EndSession(wr)
+			for _, cookie := range wr.Result().Cookies() {
+				if cookie.Secure != tc.wantSecure {
+					t.Errorf("got cleared %s cookie Secure %v, want %v", cookie.Name, cookie.Secure, tc.wantSecure)
+				}
+			}
+		})
+	}
+}
+
+// NewMockDb knows mockUser and roleUser, and passes inserted users to the
+// inserted callbacks.
+func NewMockDb(controller *gomock.Controller, inserted ...func(entities.User)) *mock_repository.MockDatabaseRepo {
 	mockDb := mock_repository.NewMockDatabaseRepo(controller)
 
 	mockDb.EXPECT().GetUserByEmail(gomock.Any()).DoAndReturn(func(email string) (entities.User, error) {
 		switch email {
 		case mockUser.Email:
 			encryptedPassword, _ := bcrypt.GenerateFromPassword([]byte(mockUser.Password), bcrypt.DefaultCost)
-			return entities.User{mockUser.Email, string(encryptedPassword)}, nil
+			return entities.User{Email: mockUser.Email, Password: string(encryptedPassword)}, nil
+		case roleUser.Email:
+			return roleUser, nil
 		default:
 			return entities.User{}, ErrorUserNotFound
 		}
 	}).AnyTimes()
 
-	mockDb.EXPECT().InsertUser(gomock.Any()).Return(nil).AnyTimes()
+	mockDb.EXPECT().InsertUser(gomock.Any()).DoAndReturn(func(user entities.User) error {
+		for _, fn := range inserted {
+			fn(user)
+		}
+		return nil
+	}).AnyTimes()
 
 	return mockDb
 }
+
+func TestUserAuth_ClientIdentity(t *testing.T) {
+	userAuth := NewUserAuth("HS256", "verysecret", nil,
+		WithClientIdentities(map[string]string{"worker.internal": "geo-worker"}))
+
+	testCases := []struct {
+		name         string
+		state        *tls.ConnectionState
+		wantStatus   int
+		wantIdentity string
+	}{
+		{"mapped common name", verifiedState(&x509.Certificate{Subject: pkix.Name{CommonName: "worker.internal"}}), 200, "geo-worker"},
+		{"mapped dns name", verifiedState(&x509.Certificate{DNSNames: []string{"worker.internal"}}), 200, "geo-worker"},
+		{"unknown certificate", verifiedState(&x509.Certificate{Subject: pkix.Name{CommonName: "other"}}), 403, ""},
+		{"unverified certificate", &tls.ConnectionState{}, 403, ""},
+		{"plain http", nil, 403, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var identity string
+			handler := userAuth.RequireAuthentication(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				identity, _ = IdentityFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/address/search", nil)
+			req.TLS = tc.state
+			wr := httptest.NewRecorder()
+
+			handler.ServeHTTP(wr, req)
+
+			if wr.Code != tc.wantStatus {
+				t.Errorf("got status code %d, want %d", wr.Code, tc.wantStatus)
+			}
+			if identity != tc.wantIdentity {
+				t.Errorf("got identity %q, want %q", identity, tc.wantIdentity)
+			}
+		})
+	}
+}
+
+func verifiedState(leaf *x509.Certificate) *tls.ConnectionState {
+	return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{leaf}}}
+}
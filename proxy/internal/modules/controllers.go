@@ -1,19 +1,26 @@
 package modules
 
 import (
+	"log/slog"
 	acontroller "proxy/internal/modules/auth/controller"
 	gcontroller "proxy/internal/modules/geo/controller"
+	hcontroller "proxy/internal/modules/health/controller"
+	pcontroller "proxy/internal/modules/proxy/controller"
 	"proxy/internal/utils/readresponder"
 )
 
 type Controllers struct {
-	Geo  gcontroller.GeoServicer
-	Auth acontroller.Authenticator
+	Geo    gcontroller.GeoServicer
+	Auth   acontroller.Authenticator
+	Proxy  pcontroller.CachePurger
+	Health hcontroller.HealthChecker
 }
 
-func NewControllers(services *Services, responder readresponder.ReadResponder) *Controllers {
+func NewControllers(services *Services, responder readresponder.ReadResponder, logger *slog.Logger) *Controllers {
 	return &Controllers{
-		Auth: acontroller.NewAuth(services.Auth, responder),
-		Geo:  gcontroller.NewGeo(services.Geo, responder),
+		Auth:   acontroller.NewAuth(services.Auth, responder, logger),
+		Geo:    gcontroller.NewGeo(services.Geo, responder),
+		Proxy:  pcontroller.NewProxy(services.Proxy, responder, logger),
+		Health: hcontroller.NewHealth(services.Health, responder),
 	}
 }
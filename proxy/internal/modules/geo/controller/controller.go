@@ -1,53 +1,56 @@
 package controller
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"proxy/internal/modules/geo/entities"
 	"proxy/internal/modules/geo/service"
 	"proxy/internal/utils/readresponder"
+	"time"
 )
 
+// batchPointTimeout bounds the geocoding of a single point of a batch. It
+// is shorter than the stream write timeout, which starts anew for every
+// point.
+const batchPointTimeout = 5 * time.Second
+
 type Geo struct {
 	geoService    service.GeoServicer
 	readResponder readresponder.ReadResponder
+	pointTimeout  time.Duration
 }
 
 func NewGeo(geoService service.GeoServicer, responder readresponder.ReadResponder) *Geo {
-	return &Geo{geoService: geoService, readResponder: responder}
+	return &Geo{geoService: geoService, readResponder: responder, pointTimeout: batchPointTimeout}
 }
 
 // AddressSearch
 // @Summary Search by street name
 // @Security ApiKeyAuth
-// @Description Return a list of addresses provided street name
+// @Description Return a list of addresses provided street name. Send Accept: text/csv for a spreadsheet with a column per address field
 // @Tags address
-// @Accept json
-// @Produce json
+// @Accept json,application/msgpack,application/cbor
+// @Produce json,application/msgpack,application/cbor,text/csv
 // @Param query body entities.AddressSearch true "street name"
 // @Success 200 {object} readresponder.JSONResponse
-// @Failure 400 {object} readresponder.JSONResponse
-// @Router /api/address/search [post]
+// @Failure 400,406,413,415,422,502,503 {object} readresponder.JSONResponse
+// @Failure 403 {string} string
+// @Deprecated
+// @Router /api/v1/address/search [post]
 func (g *Geo) AddressSearch(w http.ResponseWriter, r *http.Request) {
-	var req entities.AddressSearch
-
-	if err := g.readResponder.ReadJSON(w, r, &req); err != nil {
-		g.readResponder.WriteJSONError(w, err)
-		return
-	} else if req.Query == "" {
-		g.readResponder.WriteJSONError(w, errors.New("query is required"))
+	addresses, ok := g.search(w, r)
+	if !ok {
 		return
 	}
 
-	addresses, _ := g.geoService.AddressSearch(req.Query)
-
 	resp := readresponder.JSONResponse{
 		Error:   false,
 		Message: "search completed",
 		Data:    addresses,
 	}
 
-	g.readResponder.WriteJSON(w, http.StatusOK, resp)
+	g.readResponder.Write(w, r, http.StatusOK, resp)
 }
 
 // AddressGeocode
@@ -55,29 +58,123 @@ func (g *Geo) AddressSearch(w http.ResponseWriter, r *http.Request) {
 // @Security ApiKeyAuth
 // @Description Return a list of addresses provided geo coordinates
 // @Tags address
-// @Accept json
-// @Produce json
+// @Accept json,application/msgpack,application/cbor
+// @Produce json,application/msgpack,application/cbor,text/csv
 // @Param query body entities.AddressGeocode true "coordinates"
 // @Success 200 {object} readresponder.JSONResponse
-// @Failure 400 {object} readresponder.JSONResponse
-// @Router /api/address/geocode [post]
+// @Failure 400,406,413,415,422,502,503 {object} readresponder.JSONResponse
+// @Failure 403 {string} string
+// @Deprecated
+// @Router /api/v1/address/geocode [post]
 func (g *Geo) AddressGeocode(w http.ResponseWriter, r *http.Request) {
-	var req entities.AddressGeocode
-	if err := g.readResponder.ReadJSON(w, r, &req); err != nil {
-		g.readResponder.WriteJSONError(w, err)
-		return
-	} else if req.Lat == "" || req.Lng == "" {
-		g.readResponder.WriteJSONError(w, errors.New("both lat and lng are required"))
+	addresses, ok := g.geocode(w, r)
+	if !ok {
 		return
 	}
 
-	addresses, _ := g.geoService.GeoCode(req.Lat, req.Lng)
-
 	resp := readresponder.JSONResponse{
 		Error:   false,
 		Message: "search completed",
 		Data:    addresses,
 	}
 
-	g.readResponder.WriteJSON(w, http.StatusOK, resp)
+	g.readResponder.Write(w, r, http.StatusOK, resp)
+}
+
+// AddressGeocodeBatch
+// @Summary Search by a batch of coordinates
+// @Security ApiKeyAuth
+// @Description Stream a line of NDJSON per point, in order, as soon as it is geocoded. A point that fails or takes over 5 seconds gets an error in its line;
+// @Description if the provider becomes unavailable the stream stops early. The X-Stream-Error trailer reports why a stream stopped and
+// @Description X-Stream-Count the number of lines sent. Under v1 a rejected request gets the {error, message} body instead of problem details.
+// @Tags address
+// @Accept json,application/msgpack,application/cbor
+// @Produce application/x-ndjson
+// @Param query body entities.AddressGeocodeBatch true "coordinates"
+// @Success 200 {object} entities.GeocodeResult "one per line"
+// @Failure 400,413,415,422 {object} readresponder.Problem "application/problem+json"
+// @Failure 403 {string} string
+// @Router /api/v1/address/geocode/batch [post]
+// @Router /api/v2/address/geocode/batch [post]
+func (g *Geo) AddressGeocodeBatch(w http.ResponseWriter, r *http.Request) {
+	var req entities.AddressGeocodeBatch
+	if err := g.readResponder.Read(w, r, &req); err != nil {
+		g.readResponder.WriteError(w, r, err)
+		return
+	}
+
+	stream := g.readResponder.Stream(w, r, http.StatusOK)
+	for _, point := range req.Points {
+		result := entities.GeocodeResult{Lat: point.Lat, Lng: point.Lng}
+
+		stream.Extend()
+		ctx, cancel := context.WithTimeout(r.Context(), g.pointTimeout)
+		addresses, err := g.geoService.GeoCode(ctx, point.Lat, point.Lng)
+		timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded) && r.Context().Err() == nil
+		cancel()
+
+		switch {
+		case timedOut:
+			// a slow point fails alone, the next one gets its own deadline
+			result.Error = "geocoding timed out"
+		case errors.Is(err, service.ErrorUnavailable):
+			// the remaining points would fail the same way
+			stream.Close(service.ErrorUnavailable)
+			return
+		case err != nil:
+			result.Error = "geocoding failed"
+		default:
+			result.Addresses = addresses
+		}
+
+		if err := stream.Send(result); err != nil {
+			// the client is gone, stop geocoding for it
+			stream.Close(err)
+			return
+		}
+	}
+	stream.Close(nil)
+}
+
+// search runs the search in the body of r. On failure it writes the error
+// and returns false.
+func (g *Geo) search(w http.ResponseWriter, r *http.Request) ([]*entities.Address, bool) {
+	var req entities.AddressSearch
+
+	if err := g.readResponder.Read(w, r, &req); err != nil {
+		g.readResponder.WriteError(w, r, err)
+		return nil, false
+	}
+
+	addresses, err := g.geoService.AddressSearch(r.Context(), req.Query)
+	if err != nil {
+		g.writeServiceError(w, r, err)
+		return nil, false
+	}
+	return addresses, true
+}
+
+// geocode is search for the coordinates in the body of r.
+func (g *Geo) geocode(w http.ResponseWriter, r *http.Request) ([]*entities.Address, bool) {
+	var req entities.AddressGeocode
+
+	if err := g.readResponder.Read(w, r, &req); err != nil {
+		g.readResponder.WriteError(w, r, err)
+		return nil, false
+	}
+
+	addresses, err := g.geoService.GeoCode(r.Context(), req.Lat, req.Lng)
+	if err != nil {
+		g.writeServiceError(w, r, err)
+		return nil, false
+	}
+	return addresses, true
+}
+
+func (g *Geo) writeServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, service.ErrorUnavailable) {
+		g.readResponder.WriteError(w, r, service.ErrorUnavailable, http.StatusServiceUnavailable)
+		return
+	}
+	g.readResponder.WriteError(w, r, err, http.StatusBadGateway)
 }
@@ -2,14 +2,21 @@ package controller
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/golang/mock/gomock"
 	"log"
+	"net/http"
 	"net/http/httptest"
 	"proxy/internal/modules/geo/controller/mock_service"
 	"proxy/internal/modules/geo/entities"
+	"proxy/internal/modules/geo/service"
 	"proxy/internal/utils/readresponder"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGeo_AddressSearch(t *testing.T) {
@@ -20,7 +27,8 @@ func TestGeo_AddressSearch(t *testing.T) {
 		wantMessage string
 	}{
 		{"successful request", entities.AddressSearch{"улица Ленина"}, 200, "search completed"},
-		{"empty query", nil, 400, "query is required"},
+		{"empty query", nil, 400, "query: is required"},
+		{"provider unavailable", entities.AddressSearch{"provider down"}, 503, service.ErrorUnavailable.Error()},
 	}
 
 	controller := gomock.NewController(t)
@@ -69,9 +77,10 @@ func TestGeo_AddressGeocode(t *testing.T) {
 		wantMessage string
 	}{
 		{"successful request", entities.AddressGeocode{"5.12501", "1.15080"}, 200, "search completed"},
-		{"no latitude", entities.AddressGeocode{"", "1.14080"}, 400, "both lat and lng are required"},
-		{"no longitude", entities.AddressGeocode{"5.15080", ""}, 400, "both lat and lng are required"},
-		{"empty body", nil, 400, "both lat and lng are required"},
+		{"no latitude", entities.AddressGeocode{"", "1.14080"}, 400, "lat: is required"},
+		{"no longitude", entities.AddressGeocode{"5.15080", ""}, 400, "lng: is required"},
+		{"out of range", entities.AddressGeocode{"95.1", "-181"}, 400, "lat: must be a latitude between -90 and 90; lng: must be a longitude between -180 and 180"},
+		{"empty body", nil, 400, "lat: is required; lng: is required"},
 	}
 
 	controller := gomock.NewController(t)
@@ -112,11 +121,200 @@ func TestGeo_AddressGeocode(t *testing.T) {
 	}
 }
 
+func TestGeo_AddressSearchV2(t *testing.T) {
+	testCases := []struct {
+		name       string
+		query      string
+		body       any
+		wantStatus int
+		wantBody   string
+	}{
+		{"first page", "?per_page=2", entities.AddressSearch{"улица Ленина"}, 200,
+			`{"data":[{"city":"Москва","street":"улица Ленина","house":"1","lat":"","lon":""},{"city":"Москва","street":"улица Ленина","house":"2","lat":"","lon":""}],"meta":{"page":1,"per_page":2,"total":3,"total_pages":2}}`},
+		{"empty result", "", entities.AddressSearch{"nowhere"}, 200, `{"data":[],"meta":{"page":1,"per_page":20,"total":0,"total_pages":0}}`},
+		{"invalid page", "?page=0", entities.AddressSearch{"улица Ленина"}, 422, ""},
+	}
+
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockService := NewMockService(controller)
+	geo := NewGeo(mockService, readresponder.NewReadRespond())
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var body bytes.Buffer
+			_ = json.NewEncoder(&body).Encode(tc.body)
+
+			req := httptest.NewRequest("POST", "/api/v2/address/search"+tc.query, &body)
+			wr := httptest.NewRecorder()
+
+			readresponder.ProblemDetails(http.HandlerFunc(geo.AddressSearchV2)).ServeHTTP(wr, req)
+
+			if wr.Code != tc.wantStatus {
+				t.Errorf("got status code %d, want %d", wr.Code, tc.wantStatus)
+			}
+			if got := strings.TrimSpace(wr.Body.String()); tc.wantBody != "" && got != tc.wantBody {
+				t.Errorf("got body %s, want %s", got, tc.wantBody)
+			}
+		})
+	}
+}
+
+func TestGeo_AddressGeocodeBatch(t *testing.T) {
+	testCases := []struct {
+		name       string
+		body       any
+		wantStatus int
+		wantLines  []entities.GeocodeResult
+		wantError  string
+	}{
+		{
+			name:       "successful request",
+			body:       entities.AddressGeocodeBatch{Points: []entities.AddressGeocode{{"55.75", "37.64"}, {"-90", "0"}}},
+			wantStatus: 200,
+			wantLines: []entities.GeocodeResult{
+				{Lat: "55.75", Lng: "37.64", Addresses: []*entities.Address{{City: "Москва"}}},
+				{Lat: "-90", Lng: "0", Error: "geocoding failed"},
+			},
+		},
+		{
+			name:       "provider unavailable",
+			body:       entities.AddressGeocodeBatch{Points: []entities.AddressGeocode{{"55.75", "37.64"}, {"90", "0"}, {"55.75", "37.64"}}},
+			wantStatus: 200,
+			wantLines: []entities.GeocodeResult{
+				{Lat: "55.75", Lng: "37.64", Addresses: []*entities.Address{{City: "Москва"}}},
+			},
+			wantError: service.ErrorUnavailable.Error(),
+		},
+		{
+			name:       "invalid point",
+			body:       entities.AddressGeocodeBatch{Points: []entities.AddressGeocode{{"55.75", "37.64"}, {"95.1", "0"}}},
+			wantStatus: 400,
+		},
+		{"empty batch", entities.AddressGeocodeBatch{}, 400, nil, ""},
+	}
+
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockService := NewMockService(controller)
+	unavailable := readresponder.ProblemType{Status: http.StatusServiceUnavailable}
+	geo := NewGeo(mockService, readresponder.NewReadRespond(readresponder.WithProblem(service.ErrorUnavailable, unavailable)))
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var body bytes.Buffer
+			_ = json.NewEncoder(&body).Encode(tc.body)
+
+			req := httptest.NewRequest("POST", "/api/address/geocode/batch", &body)
+			wr := httptest.NewRecorder()
+
+			geo.AddressGeocodeBatch(wr, req)
+
+			r := wr.Result()
+			defer r.Body.Close()
+
+			if r.StatusCode != tc.wantStatus {
+				t.Errorf("got status code %d, want %d", r.StatusCode, tc.wantStatus)
+			}
+			if r.StatusCode != 200 {
+				return
+			}
+
+			var lines []entities.GeocodeResult
+			decoder := json.NewDecoder(r.Body)
+			for decoder.More() {
+				var line entities.GeocodeResult
+				if err := decoder.Decode(&line); err != nil {
+					t.Fatal(err)
+				}
+				lines = append(lines, line)
+			}
+
+			if !reflect.DeepEqual(lines, tc.wantLines) {
+				t.Errorf("got lines %+v, want %+v", lines, tc.wantLines)
+			}
+			if got := r.Trailer.Get(readresponder.TrailerStreamError); got != tc.wantError {
+				t.Errorf("got error trailer %q, want %q", got, tc.wantError)
+			}
+		})
+	}
+}
+
 func NewMockService(controller *gomock.Controller) *mock_service.MockGeoServicer {
 	mockService := mock_service.NewMockGeoServicer(controller)
 
-	mockService.EXPECT().AddressSearch(gomock.Any()).Return([]*entities.Address{}, nil).AnyTimes()
-	mockService.EXPECT().GeoCode(gomock.Any(), gomock.Any()).Return([]*entities.Address{}, nil).AnyTimes()
+	mockService.EXPECT().AddressSearch(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, query string) ([]*entities.Address, error) {
+		switch query {
+		case "provider down":
+			return nil, service.ErrorUnavailable
+		case "улица Ленина":
+			return []*entities.Address{
+				{City: "Москва", Street: query, House: "1"},
+				{City: "Москва", Street: query, House: "2"},
+				{City: "Москва", Street: query, House: "3"},
+			}, nil
+		default:
+			return []*entities.Address{}, nil
+		}
+	}).AnyTimes()
+	mockService.EXPECT().GeoCode(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, lat, _ string) ([]*entities.Address, error) {
+		switch lat {
+		case "90":
+			return nil, service.ErrorUnavailable
+		case "-90":
+			return nil, errors.New("unexpected provider response")
+		default:
+			return []*entities.Address{{City: "Москва"}}, nil
+		}
+	}).AnyTimes()
 
 	return mockService
 }
+
+func TestGeo_AddressGeocodeBatchSlowPoint(t *testing.T) {
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockService := mock_service.NewMockGeoServicer(controller)
+	mockService.EXPECT().GeoCode(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, lat, lng string) ([]*entities.Address, error) {
+			if lat == "90" {
+				// a hanging provider answers only once the point deadline passes
+				<-ctx.Done()
+				return nil, errors.Join(service.ErrorUnavailable, ctx.Err())
+			}
+			return []*entities.Address{{City: "Москва"}}, nil
+		}).Times(3)
+
+	geo := NewGeo(mockService, readresponder.NewReadRespond())
+	geo.pointTimeout = 10 * time.Millisecond
+
+	var body bytes.Buffer
+	_ = json.NewEncoder(&body).Encode(entities.AddressGeocodeBatch{Points: []entities.AddressGeocode{{"55.75", "37.64"}, {"90", "0"}, {"55.75", "37.64"}}})
+	wr := httptest.NewRecorder()
+	geo.AddressGeocodeBatch(wr, httptest.NewRequest("POST", "/api/address/geocode/batch", &body))
+
+	var lines []entities.GeocodeResult
+	decoder := json.NewDecoder(wr.Result().Body)
+	for decoder.More() {
+		var line entities.GeocodeResult
+		if err := decoder.Decode(&line); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+
+	want := []entities.GeocodeResult{
+		{Lat: "55.75", Lng: "37.64", Addresses: []*entities.Address{{City: "Москва"}}},
+		{Lat: "90", Lng: "0", Error: "geocoding timed out"},
+		{Lat: "55.75", Lng: "37.64", Addresses: []*entities.Address{{City: "Москва"}}},
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("got lines %+v, want %+v", lines, want)
+	}
+	if got := wr.Result().Trailer.Get(readresponder.TrailerStreamError); got != "" {
+		t.Errorf("got error trailer %q, want the stream complete", got)
+	}
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"net/http"
+	"proxy/internal/utils/readresponder"
+)
+
+// AddressSearchV2
+// @Summary Search by street name
+// @Security ApiKeyAuth
+// @Description Return a page of addresses provided street name. Send Accept: text/csv for a spreadsheet with a column per address field
+// @Tags address
+// @Accept json,application/msgpack,application/cbor
+// @Produce json,application/msgpack,application/cbor,text/csv
+// @Param query body entities.AddressSearch true "street name"
+// @Param page query int false "page number, from 1" minimum(1) default(1)
+// @Param per_page query int false "addresses per page" minimum(1) maximum(100) default(20)
+// @Success 200 {object} readresponder.Envelope{data=[]entities.Address}
+// @Failure 400,406,413,415,422,502,503 {object} readresponder.Problem "application/problem+json"
+// @Failure 403 {string} string
+// @Router /api/v2/address/search [post]
+func (g *Geo) AddressSearchV2(w http.ResponseWriter, r *http.Request) {
+	page, err := readresponder.ReadPage(r)
+	if err != nil {
+		g.readResponder.WriteError(w, r, err)
+		return
+	}
+
+	addresses, ok := g.search(w, r)
+	if !ok {
+		return
+	}
+
+	g.readResponder.Write(w, r, http.StatusOK, readresponder.Paginate(addresses, page))
+}
+
+// AddressGeocodeV2
+// @Summary Search by coordinates
+// @Security ApiKeyAuth
+// @Description Return a page of addresses provided geo coordinates
+// @Tags address
+// @Accept json,application/msgpack,application/cbor
+// @Produce json,application/msgpack,application/cbor,text/csv
+// @Param query body entities.AddressGeocode true "coordinates"
+// @Param page query int false "page number, from 1" minimum(1) default(1)
+// @Param per_page query int false "addresses per page" minimum(1) maximum(100) default(20)
+// @Success 200 {object} readresponder.Envelope{data=[]entities.Address}
+// @Failure 400,406,413,415,422,502,503 {object} readresponder.Problem "application/problem+json"
+// @Failure 403 {string} string
+// @Router /api/v2/address/geocode [post]
+func (g *Geo) AddressGeocodeV2(w http.ResponseWriter, r *http.Request) {
+	page, err := readresponder.ReadPage(r)
+	if err != nil {
+		g.readResponder.WriteError(w, r, err)
+		return
+	}
+
+	addresses, ok := g.geocode(w, r)
+	if !ok {
+		return
+	}
+
+	g.readResponder.Write(w, r, http.StatusOK, readresponder.Paginate(addresses, page))
+}
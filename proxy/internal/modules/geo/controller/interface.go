@@ -8,4 +8,7 @@ import (
 type GeoServicer interface {
 	AddressSearch(w http.ResponseWriter, r *http.Request)
 	AddressGeocode(w http.ResponseWriter, r *http.Request)
+	AddressGeocodeBatch(w http.ResponseWriter, r *http.Request)
+	AddressSearchV2(w http.ResponseWriter, r *http.Request)
+	AddressGeocodeV2(w http.ResponseWriter, r *http.Request)
 }
@@ -5,7 +5,9 @@
 package mock_service
 
 import (
+	context "context"
 	entities "proxy/internal/modules/geo/entities"
+	breaker "proxy/internal/utils/breaker"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -35,31 +37,68 @@ func (m *MockGeoServicer) EXPECT() *MockGeoServicerMockRecorder {
 }
 
 // AddressSearch mocks base method.
-func (m *MockGeoServicer) AddressSearch(input string) ([]*entities.Address, error) {
+func (m *MockGeoServicer) AddressSearch(ctx context.Context, input string) ([]*entities.Address, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddressSearch", input)
+	ret := m.ctrl.Call(m, "AddressSearch", ctx, input)
 	ret0, _ := ret[0].([]*entities.Address)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AddressSearch indicates an expected call of AddressSearch.
-func (mr *MockGeoServicerMockRecorder) AddressSearch(input interface{}) *gomock.Call {
+func (mr *MockGeoServicerMockRecorder) AddressSearch(ctx, input interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddressSearch", reflect.TypeOf((*MockGeoServicer)(nil).AddressSearch), input)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddressSearch", reflect.TypeOf((*MockGeoServicer)(nil).AddressSearch), ctx, input)
 }
 
 // GeoCode mocks base method.
-func (m *MockGeoServicer) GeoCode(lat, lng string) ([]*entities.Address, error) {
+func (m *MockGeoServicer) GeoCode(ctx context.Context, lat, lng string) ([]*entities.Address, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GeoCode", lat, lng)
+	ret := m.ctrl.Call(m, "GeoCode", ctx, lat, lng)
 	ret0, _ := ret[0].([]*entities.Address)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GeoCode indicates an expected call of GeoCode.
-func (mr *MockGeoServicerMockRecorder) GeoCode(lat, lng interface{}) *gomock.Call {
+func (mr *MockGeoServicerMockRecorder) GeoCode(ctx, lat, lng interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GeoCode", reflect.TypeOf((*MockGeoServicer)(nil).GeoCode), lat, lng)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GeoCode", reflect.TypeOf((*MockGeoServicer)(nil).GeoCode), ctx, lat, lng)
+}
+
+// MockStatusReporter is a mock of StatusReporter interface.
+type MockStatusReporter struct {
+	ctrl     *gomock.Controller
+	recorder *MockStatusReporterMockRecorder
+}
+
+// MockStatusReporterMockRecorder is the mock recorder for MockStatusReporter.
+type MockStatusReporterMockRecorder struct {
+	mock *MockStatusReporter
+}
+
+// NewMockStatusReporter creates a new mock instance.
+func NewMockStatusReporter(ctrl *gomock.Controller) *MockStatusReporter {
+	mock := &MockStatusReporter{ctrl: ctrl}
+	mock.recorder = &MockStatusReporterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStatusReporter) EXPECT() *MockStatusReporterMockRecorder {
+	return m.recorder
+}
+
+// Status mocks base method.
+func (m *MockStatusReporter) Status() breaker.Status {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Status")
+	ret0, _ := ret[0].(breaker.Status)
+	return ret0
+}
+
+// Status indicates an expected call of Status.
+func (mr *MockStatusReporterMockRecorder) Status() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Status", reflect.TypeOf((*MockStatusReporter)(nil).Status))
 }
@@ -13,10 +13,26 @@ type Addresses struct {
 }
 
 type AddressSearch struct {
-	Query string `json:"query" binding:"required" example:"Подкопаевский переулок"`
+	Query string `json:"query" binding:"required,max=300" example:"Подкопаевский переулок"`
 }
 
 type AddressGeocode struct {
-	Lat string `json:"lat" example:"55.753214" binding:"required"`
-	Lng string `json:"lng" example:"37.642589" binding:"required"`
+	// Latitude between -90 and 90
+	Lat string `json:"lat" example:"55.753214" binding:"required,latitude"`
+	// Longitude between -180 and 180
+	Lng string `json:"lng" example:"37.642589" binding:"required,longitude"`
+}
+
+type AddressGeocodeBatch struct {
+	// Up to 100 coordinates, geocoded in order
+	Points []AddressGeocode `json:"points" binding:"required,min=1,max=100,dive"`
+}
+
+// GeocodeResult is a line of the batch geocoding stream. Error is set
+// instead of Addresses when the point could not be geocoded.
+type GeocodeResult struct {
+	Lat       string     `json:"lat" example:"55.753214"`
+	Lng       string     `json:"lng" example:"37.642589"`
+	Addresses []*Address `json:"addresses,omitempty"`
+	Error     string     `json:"error,omitempty" example:"geocoding failed"`
 }
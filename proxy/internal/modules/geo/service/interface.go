@@ -1,9 +1,25 @@
 package service
 
-import "proxy/internal/modules/geo/entities"
+import (
+	"context"
+	"proxy/internal/modules/geo/entities"
+	"proxy/internal/utils/breaker"
+)
 
 //go:generate mockgen -source=./interface.go -destination=../controller/mock_service/mock_service.go
 type GeoServicer interface {
-	AddressSearch(input string) ([]*entities.Address, error)
-	GeoCode(lat, lng string) ([]*entities.Address, error)
+	AddressSearch(ctx context.Context, input string) ([]*entities.Address, error)
+	GeoCode(ctx context.Context, lat, lng string) ([]*entities.Address, error)
+}
+
+// StatusReporter exposes the provider state for health checks.
+type StatusReporter interface {
+	Status() breaker.Status
+	Ping(ctx context.Context) error
+}
+
+// Pinger is implemented by providers that can be probed without running a
+// lookup.
+type Pinger interface {
+	Ping(ctx context.Context) error
 }
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+type geoMetrics struct {
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+// WithMetrics registers the latency and error counts of the calls made to
+// the provider, retries included, with reg.
+func WithMetrics(reg prometheus.Registerer) ResilientGeoOption {
+	return func(g *ResilientGeo) {
+		g.metrics = &geoMetrics{
+			duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Name:    "geo_provider_request_duration_seconds",
+				Help:    "Latency of the calls made to the geo provider.",
+				Buckets: prometheus.DefBuckets,
+			}, []string{"operation"}),
+			errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "geo_provider_errors_total",
+				Help: "Calls to the geo provider that returned an error.",
+			}, []string{"operation"}),
+		}
+		reg.MustRegister(g.metrics.duration, g.metrics.errors)
+	}
+}
+
+func (m *geoMetrics) observe(operation string, started time.Time, err error) {
+	if m == nil {
+		return
+	}
+
+	m.duration.WithLabelValues(operation).Observe(time.Since(started).Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(operation).Inc()
+	}
+}
@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/ekomobile/dadata/v2/client"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"proxy/internal/modules/geo/entities"
+	"proxy/internal/utils/breaker"
+	"proxy/internal/utils/logging"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrorUnavailable = errors.New("geo provider is unavailable")
+
+// ResilientGeo wraps a geo provider with a circuit breaker and retries, and
+// answers from previously seen or offline results while the provider is down.
+type ResilientGeo struct {
+	provider GeoServicer
+	breaker  *breaker.Breaker
+	retries  int
+	backoff  time.Duration
+	timeout  time.Duration
+	fallback *fallbackCache
+	offline  []*entities.Address
+	sleep    func(context.Context, time.Duration) error
+	metrics  *geoMetrics
+	tracer   trace.Tracer
+	logger   *slog.Logger
+}
+
+type ResilientGeoOption func(*ResilientGeo)
+
+func WithBreaker(b *breaker.Breaker) ResilientGeoOption {
+	return func(g *ResilientGeo) {
+		g.breaker = b
+	}
+}
+
+// WithRetries sets how many times a failed lookup is retried and the base
+// delay of the jittered exponential backoff between attempts.
+func WithRetries(retries int, backoff time.Duration) ResilientGeoOption {
+	return func(g *ResilientGeo) {
+		g.retries = retries
+		g.backoff = backoff
+	}
+}
+
+// WithAttemptTimeout bounds every call to the provider, so that a provider
+// hanging instead of refusing connections fails the attempt too.
+func WithAttemptTimeout(timeout time.Duration) ResilientGeoOption {
+	return func(g *ResilientGeo) {
+		g.timeout = timeout
+	}
+}
+
+// WithFallbackCache keeps up to size recent successful results to serve when
+// the provider fails.
+func WithFallbackCache(size int) ResilientGeoOption {
+	return func(g *ResilientGeo) {
+		g.fallback = newFallbackCache(size)
+	}
+}
+
+// WithOfflineAddresses sets a local address set searched when neither the
+// provider nor the fallback cache can answer.
+func WithOfflineAddresses(addresses []*entities.Address) ResilientGeoOption {
+	return func(g *ResilientGeo) {
+		g.offline = addresses
+	}
+}
+
+// WithTracerProvider records a span for every lookup, noting whether the
+// result came from the provider or a fallback.
+func WithTracerProvider(tp trace.TracerProvider) ResilientGeoOption {
+	return func(g *ResilientGeo) {
+		g.tracer = tp.Tracer("proxy/internal/modules/geo/service")
+	}
+}
+
+// WithLogger logs provider failures and the fallback used to answer them.
+func WithLogger(logger *slog.Logger) ResilientGeoOption {
+	return func(g *ResilientGeo) {
+		g.logger = logger
+	}
+}
+
+func NewResilientGeo(provider GeoServicer, options ...ResilientGeoOption) *ResilientGeo {
+	g := &ResilientGeo{
+		provider: provider,
+		breaker:  breaker.NewBreaker(),
+		retries:  2,
+		backoff:  100 * time.Millisecond,
+		timeout:  5 * time.Second,
+		fallback: newFallbackCache(1000),
+		sleep:    sleep,
+		tracer:   noop.NewTracerProvider().Tracer(""),
+		logger:   logging.Discard(),
+	}
+
+	for _, option := range options {
+		option(g)
+	}
+	return g
+}
+
+func (g *ResilientGeo) Status() breaker.Status {
+	return g.breaker.Status()
+}
+
+// Ping probes the provider directly, bypassing the breaker, so that a
+// recovered provider shows up before the breaker lets requests through.
+func (g *ResilientGeo) Ping(ctx context.Context) error {
+	if pinger, ok := g.provider.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (g *ResilientGeo) AddressSearch(ctx context.Context, input string) ([]*entities.Address, error) {
+	return g.lookup(ctx, "search", "search:"+input,
+		func(ctx context.Context) ([]*entities.Address, error) {
+			return g.provider.AddressSearch(ctx, input)
+		},
+		func() []*entities.Address {
+			return searchOffline(g.offline, input)
+		},
+	)
+}
+
+func (g *ResilientGeo) GeoCode(ctx context.Context, lat, lng string) ([]*entities.Address, error) {
+	return g.lookup(ctx, "geocode", "geocode:"+lat+","+lng,
+		func(ctx context.Context) ([]*entities.Address, error) {
+			return g.provider.GeoCode(ctx, lat, lng)
+		},
+		func() []*entities.Address {
+			return geocodeOffline(g.offline, lat, lng)
+		},
+	)
+}
+
+// lookup asks the provider and falls back to the cached result for key,
+// then to the offline addresses, when the provider fails.
+func (g *ResilientGeo) lookup(ctx context.Context, operation, key string,
+	fn func(context.Context) ([]*entities.Address, error), offline func() []*entities.Address) ([]*entities.Address, error) {
+	ctx, span := g.tracer.Start(ctx, "geo."+operation)
+	defer span.End()
+
+	res, err := g.call(ctx, operation, fn)
+	if err == nil {
+		g.fallback.set(key, res)
+		span.SetAttributes(attribute.String("geo.source", "provider"))
+		return res, nil
+	}
+
+	span.RecordError(err)
+	if !isFailure(err) {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	if cached, ok := g.fallback.get(key); ok {
+		span.SetAttributes(attribute.String("geo.source", "cache"))
+		g.logger.WarnContext(ctx, "geo provider failed, answering from cache", "operation", operation, "error", err)
+		return cached, nil
+	}
+	if g.offline != nil {
+		span.SetAttributes(attribute.String("geo.source", "offline"))
+		g.logger.WarnContext(ctx, "geo provider failed, answering from offline data", "operation", operation, "error", err)
+		return offline(), nil
+	}
+
+	span.SetStatus(codes.Error, ErrorUnavailable.Error())
+	g.logger.ErrorContext(ctx, "geo provider failed", "operation", operation, "error", err)
+	return nil, errors.Join(ErrorUnavailable, err)
+}
+
+// call runs the lookup through the breaker, retrying transient failures.
+// Both lookups are idempotent, so retrying them is safe.
+func (g *ResilientGeo) call(ctx context.Context, operation string, fn func(context.Context) ([]*entities.Address, error)) ([]*entities.Address, error) {
+	var res []*entities.Address
+	var err error
+
+	for attempt := 0; attempt <= g.retries; attempt++ {
+		if attempt > 0 {
+			// a cancelled request stops waiting, with the last failure
+			if g.sleep(ctx, jitter(g.backoff, attempt)) != nil {
+				return nil, err
+			}
+			trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(attribute.Int("geo.attempt", attempt)))
+		}
+
+		err = g.breaker.Execute(func() error {
+			attemptCtx, cancel := context.WithTimeout(ctx, g.timeout)
+			defer cancel()
+
+			started := time.Now()
+			var callErr error
+			res, callErr = fn(attemptCtx)
+			g.metrics.observe(operation, started, callErr)
+			return callErr
+		}, func(err error) bool {
+			// the deadline of the caller says nothing about the provider
+			return isFailure(err) && ctx.Err() == nil
+		})
+
+		if err == nil || !isFailure(err) || errors.Is(err, breaker.ErrorOpen) || ctx.Err() != nil {
+			return res, err
+		}
+	}
+
+	return nil, err
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// jitter returns a random delay up to base * 2^(attempt-1) ("full jitter").
+func jitter(base time.Duration, attempt int) time.Duration {
+	ceiling := base << (attempt - 1)
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// isFailure tells provider failures from client errors: a rejected request
+// says nothing about the provider health and is not worth retrying. An
+// attempt running out of time, context.DeadlineExceeded, is a failure.
+func isFailure(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var respErr *client.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.StatusCode >= http.StatusInternalServerError || respErr.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
+type fallbackCache struct {
+	size  int
+	items map[string][]*entities.Address
+	order []string
+	m     sync.Mutex
+}
+
+func newFallbackCache(size int) *fallbackCache {
+	return &fallbackCache{size: size, items: make(map[string][]*entities.Address)}
+}
+
+func (c *fallbackCache) get(key string) ([]*entities.Address, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	res, ok := c.items[key]
+	return res, ok
+}
+
+func (c *fallbackCache) set(key string, res []*entities.Address) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.size <= 0 {
+		return
+	}
+
+	if _, ok := c.items[key]; !ok {
+		c.order = append(c.order, key)
+		if len(c.order) > c.size {
+			delete(c.items, c.order[0])
+			c.order = c.order[1:]
+		}
+	}
+	c.items[key] = res
+}
+
+func searchOffline(addresses []*entities.Address, input string) []*entities.Address {
+	var res []*entities.Address
+
+	words := strings.Fields(strings.ToLower(input))
+	for _, address := range addresses {
+		full := strings.ToLower(address.City + " " + address.Street + " " + address.House)
+
+		matched := true
+		for _, word := range words {
+			if !strings.Contains(full, word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			res = append(res, address)
+		}
+	}
+
+	return res
+}
+
+// geocodeOffline returns the offline address closest to the coordinates.
+func geocodeOffline(addresses []*entities.Address, lat, lng string) []*entities.Address {
+	latF, err1 := strconv.ParseFloat(lat, 64)
+	lngF, err2 := strconv.ParseFloat(lng, 64)
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+
+	var nearest *entities.Address
+	best := -1.0
+	for _, address := range addresses {
+		aLat, err1 := strconv.ParseFloat(address.Lat, 64)
+		aLng, err2 := strconv.ParseFloat(address.Lon, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+
+		d := (aLat-latF)*(aLat-latF) + (aLng-lngF)*(aLng-lngF)
+		if best < 0 || d < best {
+			nearest, best = address, d
+		}
+	}
+
+	if nearest == nil {
+		return nil
+	}
+	return []*entities.Address{nearest}
+}
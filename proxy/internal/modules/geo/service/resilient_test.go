@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/ekomobile/dadata/v2/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"proxy/internal/modules/geo/entities"
+	"proxy/internal/utils/breaker"
+	"testing"
+	"time"
+)
+
+type stubProvider struct {
+	calls int
+	errs  []error
+}
+
+func (p *stubProvider) next() ([]*entities.Address, error) {
+	p.calls++
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return []*entities.Address{{City: "Москва", Street: "Ленина"}}, nil
+}
+
+func (p *stubProvider) AddressSearch(context.Context, string) ([]*entities.Address, error) {
+	return p.next()
+}
+
+func (p *stubProvider) GeoCode(context.Context, string, string) ([]*entities.Address, error) {
+	return p.next()
+}
+
+func TestResilientGeo_AddressSearch(t *testing.T) {
+	errorNetwork := errors.New("connection reset")
+	errorBadRequest := &client.ResponseError{Status: "400 Bad Request", StatusCode: 400}
+
+	testCases := []struct {
+		name       string
+		errs       []error
+		warm       bool
+		offline    []*entities.Address
+		wantCalls  int
+		wantErr    error
+		wantResult bool
+	}{
+		{"success", nil, false, nil, 1, nil, true},
+		{"retried transient failure", []error{errorNetwork, nil}, false, nil, 2, nil, true},
+		{"client error not retried", []error{errorBadRequest}, false, nil, 1, errorBadRequest, false},
+		{"retries exhausted", []error{errorNetwork, errorNetwork, errorNetwork}, false, nil, 3, ErrorUnavailable, false},
+		{"fallback to cache", []error{nil, errorNetwork, errorNetwork, errorNetwork}, true, nil, 4, nil, true},
+		{"fallback to offline", []error{errorNetwork, errorNetwork, errorNetwork}, false, []*entities.Address{{City: "Москва", Street: "Ленина"}}, 3, nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := &stubProvider{errs: tc.errs}
+			g := NewResilientGeo(provider, WithOfflineAddresses(tc.offline))
+			g.sleep = func(context.Context, time.Duration) error { return nil }
+
+			if tc.warm {
+				g.AddressSearch(context.Background(), "Ленина")
+			}
+
+			res, err := g.AddressSearch(context.Background(), "Ленина")
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("AddressSearch() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if (len(res) > 0) != tc.wantResult {
+				t.Errorf("AddressSearch() got = %v, want %v", res, tc.wantResult)
+			}
+			if provider.calls != tc.wantCalls {
+				t.Errorf("got %d provider calls, want %d", provider.calls, tc.wantCalls)
+			}
+		})
+	}
+}
+
+func TestResilientGeo_CancelBackoff(t *testing.T) {
+	errorNetwork := errors.New("connection reset")
+	provider := &stubProvider{errs: []error{errorNetwork, errorNetwork, errorNetwork}}
+
+	g := NewResilientGeo(provider, WithRetries(2, time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	if _, err := g.AddressSearch(ctx, "Ленина"); !errors.Is(err, errorNetwork) {
+		t.Errorf("AddressSearch() error = %v, want %v", err, errorNetwork)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("got AddressSearch after %v, want it to stop waiting on cancel", elapsed)
+	}
+	if provider.calls != 1 {
+		t.Errorf("got %d provider calls, want 1", provider.calls)
+	}
+}
+
+// hangingProvider answers only once the context of the call ends.
+type hangingProvider struct {
+	calls int
+}
+
+func (p *hangingProvider) AddressSearch(ctx context.Context, _ string) ([]*entities.Address, error) {
+	p.calls++
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (p *hangingProvider) GeoCode(ctx context.Context, _, _ string) ([]*entities.Address, error) {
+	return p.AddressSearch(ctx, "")
+}
+
+func TestResilientGeo_AttemptTimeout(t *testing.T) {
+	provider := &hangingProvider{}
+	g := NewResilientGeo(provider,
+		WithBreaker(breaker.NewBreaker(breaker.WithFailureThreshold(2))),
+		WithRetries(1, 0),
+		WithAttemptTimeout(10*time.Millisecond),
+	)
+	g.sleep = func(context.Context, time.Duration) error { return nil }
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := g.AddressSearch(ctx, "Ленина"); !errors.Is(err, ErrorUnavailable) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("AddressSearch() error = %v, want %v from the attempt deadline", err, ErrorUnavailable)
+	}
+	if provider.calls != 2 {
+		t.Errorf("got %d provider calls, want 2", provider.calls)
+	}
+	if state := g.Status().State; state != breaker.StateOpen {
+		t.Errorf("got breaker state %s, want %s", state, breaker.StateOpen)
+	}
+}
+
+func TestResilientGeo_BreakerOpen(t *testing.T) {
+	errorNetwork := errors.New("connection reset")
+	provider := &stubProvider{errs: []error{errorNetwork, errorNetwork, errorNetwork}}
+
+	g := NewResilientGeo(provider, WithBreaker(breaker.NewBreaker(breaker.WithFailureThreshold(2))))
+	g.sleep = func(context.Context, time.Duration) error { return nil }
+
+	if _, err := g.GeoCode(context.Background(), "55.75", "37.64"); !errors.Is(err, ErrorUnavailable) {
+		t.Fatalf("GeoCode() error = %v, want %v", err, ErrorUnavailable)
+	}
+
+	if state := g.Status().State; state != breaker.StateOpen {
+		t.Errorf("got breaker state %s, want %s", state, breaker.StateOpen)
+	}
+
+	// the open breaker rejects the lookup without calling the provider
+	calls := provider.calls
+	if _, err := g.GeoCode(context.Background(), "55.75", "37.64"); !errors.Is(err, breaker.ErrorOpen) {
+		t.Errorf("GeoCode() error = %v, want %v", err, breaker.ErrorOpen)
+	}
+	if provider.calls != calls {
+		t.Errorf("got %d provider calls while open, want %d", provider.calls, calls)
+	}
+}
+
+func TestResilientGeo_Metrics(t *testing.T) {
+	errorNetwork := errors.New("connection reset")
+	provider := &stubProvider{errs: []error{errorNetwork, errorNetwork, nil}}
+
+	g := NewResilientGeo(provider, WithMetrics(prometheus.NewRegistry()))
+	g.sleep = func(context.Context, time.Duration) error { return nil }
+
+	if _, err := g.GeoCode(context.Background(), "55.75", "37.61"); err != nil {
+		t.Fatalf("GeoCode() error = %v", err)
+	}
+
+	if got := testutil.ToFloat64(g.metrics.errors.WithLabelValues("geocode")); got != 2 {
+		t.Errorf("got %v provider errors, want 2", got)
+	}
+	if got := testutil.CollectAndCount(g.metrics.duration, "geo_provider_request_duration_seconds"); got != 1 {
+		t.Errorf("got %d latency series, want 1", got)
+	}
+}
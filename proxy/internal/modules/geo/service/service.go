@@ -12,15 +12,37 @@ import (
 	"strings"
 )
 
+const endpoint = "https://suggestions.dadata.ru/suggestions/api/4_1/rs/"
+
 type GeoService struct {
-	api       *suggest.Api
-	apiKey    string
-	secretKey string
+	api        *suggest.Api
+	apiKey     string
+	secretKey  string
+	httpClient *http.Client
+}
+
+type GeoServiceOption func(*GeoService)
+
+// WithHTTPClient sets the client used for every call to DaData.
+func WithHTTPClient(httpClient *http.Client) GeoServiceOption {
+	return func(g *GeoService) {
+		g.httpClient = httpClient
+	}
 }
 
-func NewGeoService(apiKey, secretKey string) *GeoService {
+func NewGeoService(apiKey, secretKey string, options ...GeoServiceOption) *GeoService {
+	g := &GeoService{
+		apiKey:     apiKey,
+		secretKey:  secretKey,
+		httpClient: &http.Client{},
+	}
+
+	for _, option := range options {
+		option(g)
+	}
+
 	var err error
-	endpointUrl, err := url.Parse("https://suggestions.dadata.ru/suggestions/api/4_1/rs/")
+	endpointUrl, err := url.Parse(endpoint)
 	if err != nil {
 		return nil
 	}
@@ -30,20 +52,19 @@ func NewGeoService(apiKey, secretKey string) *GeoService {
 		SecretKeyValue: secretKey,
 	}
 
-	api := suggest.Api{
-		Client: client.NewClient(endpointUrl, client.WithCredentialProvider(&creds)),
+	g.api = &suggest.Api{
+		Client: client.NewClient(endpointUrl,
+			client.WithCredentialProvider(&creds),
+			client.WithHttpClient(g.httpClient),
+		),
 	}
 
-	return &GeoService{
-		api:       &api,
-		apiKey:    apiKey,
-		secretKey: secretKey,
-	}
+	return g
 }
 
-func (g *GeoService) AddressSearch(input string) ([]*entities.Address, error) {
+func (g *GeoService) AddressSearch(ctx context.Context, input string) ([]*entities.Address, error) {
 	var res []*entities.Address
-	rawRes, err := g.api.Address(context.Background(), &suggest.RequestParams{Query: input})
+	rawRes, err := g.api.Address(ctx, &suggest.RequestParams{Query: input})
 	if err != nil {
 		return nil, err
 	}
@@ -58,20 +79,25 @@ func (g *GeoService) AddressSearch(input string) ([]*entities.Address, error) {
 	return res, nil
 }
 
-func (g *GeoService) GeoCode(lat, lng string) ([]*entities.Address, error) {
-	httpClient := &http.Client{}
+func (g *GeoService) GeoCode(ctx context.Context, lat, lng string) ([]*entities.Address, error) {
 	var data = strings.NewReader(fmt.Sprintf(`{"lat": %s, "lon": %s}`, lat, lng))
-	req, err := http.NewRequest("POST", "https://suggestions.dadata.ru/suggestions/api/4_1/rs/geolocate/address", data)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint+"geolocate/address", data)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Token %s", g.apiKey))
-	resp, err := httpClient.Do(req)
+	resp, err := g.httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &client.ResponseError{Status: resp.Status, StatusCode: resp.StatusCode}
+	}
+
 	var geoCode entities.GeoCode
 
 	err = json.NewDecoder(resp.Body).Decode(&geoCode)
@@ -92,3 +118,23 @@ func (g *GeoService) GeoCode(lat, lng string) ([]*entities.Address, error) {
 
 	return res, nil
 }
+
+// Ping checks that DaData answers without spending a request of the daily
+// quota: any response short of a server error means it is reachable.
+func (g *GeoService) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return &client.ResponseError{Status: resp.Status, StatusCode: resp.StatusCode}
+	}
+	return nil
+}
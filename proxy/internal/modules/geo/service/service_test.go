@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"github.com/joho/godotenv"
 	"log"
 	"os"
@@ -37,7 +38,7 @@ func TestGeoService_AddressSearch(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			res, err := g.AddressSearch(tc.input)
+			res, err := g.AddressSearch(context.Background(), tc.input)
 
 			if (err != nil) != tc.wantErr {
 				t.Errorf("AddressSearch() error = %v, wantErr %v", err, tc.wantErr)
@@ -68,7 +69,7 @@ func TestGeoService_GeoCode(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			res, err := g.GeoCode(tc.input.lat, tc.input.lng)
+			res, err := g.GeoCode(context.Background(), tc.input.lat, tc.input.lng)
 
 			if (err != nil) != tc.wantErr {
 				t.Errorf("AddressSearch() error = %v, wantErr %v", err, tc.wantErr)
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"net/http"
+	"proxy/internal/modules/health/entities"
+	"proxy/internal/modules/health/service"
+	"proxy/internal/utils/readresponder"
+)
+
+type Health struct {
+	healthService service.HealthChecker
+	readResponder readresponder.ReadResponder
+}
+
+func NewHealth(healthService service.HealthChecker, responder readresponder.ReadResponder) *Health {
+	return &Health{healthService: healthService, readResponder: responder}
+}
+
+// Healthz godoc
+// @Summary service liveness
+// @Description Report that the service is alive. It answers without probing any dependency, so that a dependency being down never gets the service restarted; use /readyz to tell whether it can serve traffic
+// @Tags health
+// @Produce json
+// @Success 200 {object} readresponder.JSONResponse{data=entities.Health}
+// @Router /healthz [get]
+func (h *Health) Healthz(w http.ResponseWriter, r *http.Request) {
+	h.write(w, http.StatusOK, entities.Health{Status: entities.StatusOK, Checks: map[string]entities.Check{}})
+}
+
+// Readyz godoc
+// @Summary service readiness
+// @Description Report the status of every dependency, failing with 503 while a critical one (the user repository or the proxy upstreams) is down. A degraded service is still ready
+// @Tags health
+// @Produce json
+// @Success 200 {object} readresponder.JSONResponse{data=entities.Health}
+// @Failure 503 {object} readresponder.JSONResponse{data=entities.Health}
+// @Router /readyz [get]
+func (h *Health) Readyz(w http.ResponseWriter, r *http.Request) {
+	health := h.healthService.Check(r.Context())
+
+	status := http.StatusOK
+	if health.Status == entities.StatusDown {
+		status = http.StatusServiceUnavailable
+	}
+	h.write(w, status, health)
+}
+
+func (h *Health) write(w http.ResponseWriter, status int, health entities.Health) {
+	resp := readresponder.JSONResponse{
+		Error:   status != http.StatusOK,
+		Message: health.Status,
+		Data:    health,
+	}
+
+	// probes must see the current state, not a cached one
+	h.readResponder.WriteJSON(w, status, resp, http.Header{"Cache-Control": {"no-store"}})
+}
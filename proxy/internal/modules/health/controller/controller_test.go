@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"encoding/json"
+	"github.com/golang/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"proxy/internal/modules/health/controller/mock_service"
+	"proxy/internal/modules/health/entities"
+	"proxy/internal/utils/readresponder"
+	"testing"
+)
+
+func TestHealth(t *testing.T) {
+	testCases := []struct {
+		name       string
+		status     string
+		wantReadyz int
+	}{
+		{"ok", entities.StatusOK, 200},
+		{"degraded", entities.StatusDegraded, 200},
+		{"down", entities.StatusDown, 503},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			health := entities.Health{
+				Status: tc.status,
+				Checks: map[string]entities.Check{"users": {Status: tc.status, Critical: true}},
+			}
+
+			mockService := mock_service.NewMockHealthChecker(controller)
+			// only readiness probes the dependencies
+			mockService.EXPECT().Check(gomock.Any()).Return(health).Times(1)
+
+			h := NewHealth(mockService, readresponder.NewReadRespond())
+
+			resp, code := get(t, h.Healthz)
+			if code != http.StatusOK || resp.Message != entities.StatusOK || len(resp.Data.Checks) != 0 {
+				t.Errorf("got healthz %d %+v, want 200 ok without checks", code, resp)
+			}
+
+			resp, code = get(t, h.Readyz)
+			if code != tc.wantReadyz {
+				t.Errorf("got readyz status code %d, want %d", code, tc.wantReadyz)
+			}
+			if resp.Message != tc.status || resp.Data.Checks["users"].Status != tc.status {
+				t.Errorf("got %+v, want status %q for the service and the users check", resp, tc.status)
+			}
+		})
+	}
+}
+
+type healthResponse struct {
+	Message string          `json:"message"`
+	Data    entities.Health `json:"data"`
+}
+
+func get(t *testing.T, handler http.HandlerFunc) (healthResponse, int) {
+	t.Helper()
+	wr := httptest.NewRecorder()
+	handler(wr, httptest.NewRequest("GET", "/", nil))
+
+	var resp healthResponse
+	if err := json.NewDecoder(wr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp, wr.Code
+}
@@ -0,0 +1,8 @@
+package controller
+
+import "net/http"
+
+type HealthChecker interface {
+	Healthz(w http.ResponseWriter, r *http.Request)
+	Readyz(w http.ResponseWriter, r *http.Request)
+}
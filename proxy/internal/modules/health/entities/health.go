@@ -0,0 +1,25 @@
+package entities
+
+import "time"
+
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+)
+
+// Health is the overall status along with the result of every dependency
+// check. It is down when a critical dependency is down and degraded when
+// any other check is not ok.
+type Health struct {
+	Status string           `json:"status" example:"ok"`
+	Checks map[string]Check `json:"checks"`
+}
+
+type Check struct {
+	Status    string    `json:"status" example:"ok"`
+	Critical  bool      `json:"critical" example:"true"`
+	Error     string    `json:"error,omitempty" example:"context deadline exceeded"`
+	Details   any       `json:"details,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
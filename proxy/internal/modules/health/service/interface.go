@@ -0,0 +1,11 @@
+package service
+
+import (
+	"context"
+	"proxy/internal/modules/health/entities"
+)
+
+//go:generate mockgen -source=./interface.go -destination=../controller/mock_service/mock_service.go
+type HealthChecker interface {
+	Check(ctx context.Context) entities.Health
+}
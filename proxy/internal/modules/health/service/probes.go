@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"proxy/internal/modules/auth/repository"
+	gservice "proxy/internal/modules/geo/service"
+	"proxy/internal/modules/health/entities"
+	pentities "proxy/internal/modules/proxy/entities"
+	"proxy/internal/utils/breaker"
+	"sort"
+	"strings"
+)
+
+// RepositoryProbe pings the user repository.
+func RepositoryProbe(db repository.DatabaseRepo) Probe {
+	return func(ctx context.Context) entities.Check {
+		if err := db.Ping(ctx); err != nil {
+			return entities.Check{Status: entities.StatusDown, Error: err.Error()}
+		}
+		return entities.Check{Status: entities.StatusOK}
+	}
+}
+
+// GeoProbe pings the geo provider and reports the circuit breaker state.
+// Lookups are answered from fallbacks while the provider is unreachable or
+// the breaker is open, so neither takes the geo service down for good.
+func GeoProbe(geo gservice.StatusReporter) Probe {
+	return func(ctx context.Context) entities.Check {
+		result := entities.Check{Status: entities.StatusOK, Details: geo.Status()}
+
+		if err := geo.Ping(ctx); err != nil {
+			result.Status = entities.StatusDown
+			result.Error = err.Error()
+		} else if geo.Status().State != breaker.StateClosed {
+			result.Status = entities.StatusDegraded
+		}
+		return result
+	}
+}
+
+// UpstreamReporter exposes the state of the proxy upstreams, as seen by
+// their periodic health checks.
+type UpstreamReporter interface {
+	UpstreamHealth() map[string][]pentities.UpstreamHealth
+}
+
+// UpstreamsProbe reports the proxy upstreams per pool. A pool without any
+// healthy upstream is down; a pool that lost some of them is degraded.
+func UpstreamsProbe(proxy UpstreamReporter) Probe {
+	return func(ctx context.Context) entities.Check {
+		pools := proxy.UpstreamHealth()
+		result := entities.Check{Status: entities.StatusOK, Details: pools}
+
+		var down []string
+		for name, upstreams := range pools {
+			healthy := 0
+			for _, u := range upstreams {
+				if u.Healthy {
+					healthy++
+				}
+			}
+
+			switch {
+			case healthy == 0:
+				down = append(down, name)
+			case healthy < len(upstreams) && result.Status == entities.StatusOK:
+				result.Status = entities.StatusDegraded
+			}
+		}
+
+		if len(down) > 0 {
+			sort.Strings(down)
+			result.Status = entities.StatusDown
+			result.Error = "no healthy upstream in " + strings.Join(down, ", ")
+		}
+		return result
+	}
+}
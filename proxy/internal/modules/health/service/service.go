@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"proxy/internal/modules/health/entities"
+	"sync"
+	"time"
+)
+
+// Probe checks a single dependency. It only sets the status, error and
+// details of the result; the service fills in the rest.
+type Probe func(ctx context.Context) entities.Check
+
+type check struct {
+	name     string
+	critical bool
+	ttl      time.Duration
+	probe    Probe
+}
+
+type HealthService struct {
+	checks  []check
+	timeout time.Duration
+	now     func() time.Time
+
+	m     sync.Mutex
+	cache map[string]entities.Check
+}
+
+type HealthServiceOption func(*HealthService)
+
+// WithCheck adds a dependency check. A critical dependency being down takes
+// the service down; others only degrade it. The result is reused for ttl,
+// so that expensive probes do not run on every health request.
+func WithCheck(name string, critical bool, ttl time.Duration, probe Probe) HealthServiceOption {
+	return func(h *HealthService) {
+		h.checks = append(h.checks, check{name: name, critical: critical, ttl: ttl, probe: probe})
+	}
+}
+
+// WithTimeout bounds the time every probe may take.
+func WithTimeout(timeout time.Duration) HealthServiceOption {
+	return func(h *HealthService) {
+		h.timeout = timeout
+	}
+}
+
+func NewHealthService(options ...HealthServiceOption) *HealthService {
+	h := &HealthService{
+		timeout: 2 * time.Second,
+		now:     time.Now,
+		cache:   make(map[string]entities.Check),
+	}
+
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+// Check runs the probes concurrently, skipping those with a fresh result.
+func (h *HealthService) Check(ctx context.Context) entities.Health {
+	health := entities.Health{
+		Status: entities.StatusOK,
+		Checks: make(map[string]entities.Check, len(h.checks)),
+	}
+
+	var wg sync.WaitGroup
+	var m sync.Mutex
+	for _, c := range h.checks {
+		wg.Add(1)
+		go func(c check) {
+			defer wg.Done()
+			result := h.run(ctx, c)
+
+			m.Lock()
+			health.Checks[c.name] = result
+			m.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	for _, result := range health.Checks {
+		switch {
+		case result.Status == entities.StatusDown && result.Critical:
+			health.Status = entities.StatusDown
+		case result.Status != entities.StatusOK && health.Status == entities.StatusOK:
+			health.Status = entities.StatusDegraded
+		}
+	}
+
+	return health
+}
+
+func (h *HealthService) run(ctx context.Context, c check) entities.Check {
+	h.m.Lock()
+	cached, ok := h.cache[c.name]
+	h.m.Unlock()
+	if ok && h.now().Sub(cached.CheckedAt) < c.ttl {
+		return cached
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	result := c.probe(ctx)
+	result.Critical = c.critical
+	result.CheckedAt = h.now()
+	if result.Status == "" {
+		result.Status = entities.StatusOK
+	}
+
+	// a probe cut short by the caller going away says nothing about the dependency
+	if ctx.Err() == nil || ctx.Err() == context.DeadlineExceeded {
+		h.m.Lock()
+		h.cache[c.name] = result
+		h.m.Unlock()
+	}
+	return result
+}
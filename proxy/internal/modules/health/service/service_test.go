@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"proxy/internal/modules/health/entities"
+	pentities "proxy/internal/modules/proxy/entities"
+	"testing"
+	"time"
+)
+
+func probe(status string) Probe {
+	return func(ctx context.Context) entities.Check {
+		return entities.Check{Status: status}
+	}
+}
+
+func TestHealthService_Check(t *testing.T) {
+	testCases := []struct {
+		name     string
+		critical string
+		optional string
+		want     string
+	}{
+		{"all ok", entities.StatusOK, entities.StatusOK, entities.StatusOK},
+		{"optional down", entities.StatusOK, entities.StatusDown, entities.StatusDegraded},
+		{"critical degraded", entities.StatusDegraded, entities.StatusOK, entities.StatusDegraded},
+		{"critical down", entities.StatusDown, entities.StatusOK, entities.StatusDown},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHealthService(
+				WithCheck("users", true, 0, probe(tc.critical)),
+				WithCheck("geo", false, 0, probe(tc.optional)),
+			)
+
+			health := h.Check(context.Background())
+			if health.Status != tc.want {
+				t.Errorf("got status %q, want %q", health.Status, tc.want)
+			}
+			if len(health.Checks) != 2 || !health.Checks["users"].Critical || health.Checks["geo"].Critical {
+				t.Errorf("got checks %+v, want users critical and geo not", health.Checks)
+			}
+		})
+	}
+}
+
+func TestHealthService_CheckCached(t *testing.T) {
+	calls := 0
+	expensive := func(ctx context.Context) entities.Check {
+		calls++
+		return entities.Check{Status: entities.StatusOK}
+	}
+
+	now := time.Now()
+	h := NewHealthService(WithCheck("geo", false, 30*time.Second, expensive))
+	h.now = func() time.Time { return now }
+
+	h.Check(context.Background())
+	now = now.Add(10 * time.Second)
+	h.Check(context.Background())
+	if calls != 1 {
+		t.Errorf("got %d probes within the ttl, want 1", calls)
+	}
+
+	now = now.Add(30 * time.Second)
+	h.Check(context.Background())
+	if calls != 2 {
+		t.Errorf("got %d probes after the ttl, want 2", calls)
+	}
+}
+
+func TestHealthService_CheckTimeout(t *testing.T) {
+	hanging := func(ctx context.Context) entities.Check {
+		<-ctx.Done()
+		return entities.Check{Status: entities.StatusDown, Error: ctx.Err().Error()}
+	}
+
+	h := NewHealthService(WithTimeout(10*time.Millisecond), WithCheck("users", true, 0, hanging))
+
+	health := h.Check(context.Background())
+	if got := health.Checks["users"]; got.Status != entities.StatusDown || got.Error != context.DeadlineExceeded.Error() {
+		t.Errorf("got %+v, want the probe cut off by the timeout", got)
+	}
+}
+
+type upstreams map[string][]pentities.UpstreamHealth
+
+func (u upstreams) UpstreamHealth() map[string][]pentities.UpstreamHealth {
+	return u
+}
+
+func TestUpstreamsProbe(t *testing.T) {
+	up := pentities.UpstreamHealth{Upstream: "hugo:1313", Healthy: true}
+	down := pentities.UpstreamHealth{Upstream: "backup:1313"}
+
+	testCases := []struct {
+		name      string
+		pools     upstreams
+		want      string
+		wantError string
+	}{
+		{"all healthy", upstreams{"default": {up, up}}, entities.StatusOK, ""},
+		{"some ejected", upstreams{"default": {up, down}}, entities.StatusDegraded, ""},
+		{"pools down", upstreams{"default": {up}, "b": {down}, "a": {down, down}}, entities.StatusDown, "no healthy upstream in a, b"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := UpstreamsProbe(tc.pools)(context.Background())
+			if got.Status != tc.want || got.Error != tc.wantError {
+				t.Errorf("got %q (%q), want %q (%q)", got.Status, got.Error, tc.want, tc.wantError)
+			}
+		})
+	}
+}
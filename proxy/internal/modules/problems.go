@@ -0,0 +1,22 @@
+package modules
+
+import (
+	"net/http"
+	aservice "proxy/internal/modules/auth/service"
+	gservice "proxy/internal/modules/geo/service"
+	"proxy/internal/utils/readresponder"
+)
+
+// Problems maps the service errors to the problem types returned by the
+// routes that opted into problem responses.
+func Problems() []readresponder.ReadRespondOption {
+	return []readresponder.ReadRespondOption{
+		readresponder.WithProblem(aservice.ErrorBadPassword, readresponder.ProblemValidation),
+		readresponder.WithProblem(aservice.ErrorBadEmail, readresponder.ProblemValidation),
+		readresponder.WithProblem(aservice.ErrorUserExists, readresponder.ProblemType{Type: "/problems/user-exists", Title: "User already exists", Status: http.StatusConflict}),
+		readresponder.WithProblem(aservice.ErrorReservedEmail, readresponder.ProblemType{Type: "/problems/reserved-email", Title: "Email reserved", Status: http.StatusForbidden}),
+		readresponder.WithProblem(aservice.ErrorUserNotFound, readresponder.ProblemType{Type: "/problems/user-not-found", Title: "User not found", Status: http.StatusNotFound}),
+		readresponder.WithProblem(aservice.ErrorInvalidCredentials, readresponder.ProblemType{Type: "/problems/invalid-credentials", Title: "Invalid credentials", Status: http.StatusUnauthorized}),
+		readresponder.WithProblem(gservice.ErrorUnavailable, readresponder.ProblemType{Type: "/problems/geo-unavailable", Title: "Geo provider unavailable", Status: http.StatusServiceUnavailable}),
+	}
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"log/slog"
+	"net/http"
+	"proxy/internal/modules/proxy/entities"
+	"proxy/internal/modules/proxy/service"
+	"proxy/internal/utils/readresponder"
+)
+
+type Proxy struct {
+	proxyService  service.ProxyReverser
+	readResponder readresponder.ReadResponder
+	logger        *slog.Logger
+}
+
+func NewProxy(proxyService service.ProxyReverser, responder readresponder.ReadResponder, logger *slog.Logger) *Proxy {
+	return &Proxy{proxyService: proxyService, readResponder: responder, logger: logger}
+}
+
+// PurgeCache godoc
+// @Summary Purge proxy cache
+// @Security ApiKeyAuth
+// @Description Drop cached pages under the path prefix, or the whole cache if the prefix is empty
+// @Tags admin
+// @Accept json,application/msgpack,application/cbor
+// @Produce json,application/msgpack,application/cbor
+// @Param input body entities.CachePurge true "path prefix"
+// @Success 200 {object} readresponder.JSONResponse{data=entities.CachePurged}
+// @Failure 400,406,413,415 {object} readresponder.JSONResponse
+// @Failure 403 {string} string
+// @Deprecated
+// @Router /api/v1/admin/cache/purge [post]
+func (p *Proxy) PurgeCache(w http.ResponseWriter, r *http.Request) {
+	purged, ok := p.purge(w, r)
+	if !ok {
+		return
+	}
+
+	resp := readresponder.JSONResponse{
+		Error:   false,
+		Message: "cache purged",
+		Data:    purged,
+	}
+
+	p.readResponder.Write(w, r, http.StatusOK, resp)
+}
+
+// purge drops the cached pages under the prefix in the body of r. On
+// failure it writes the error and returns false.
+func (p *Proxy) purge(w http.ResponseWriter, r *http.Request) (entities.CachePurged, bool) {
+	var req entities.CachePurge
+
+	if err := p.readResponder.Read(w, r, &req); err != nil {
+		p.readResponder.WriteError(w, r, err)
+		return entities.CachePurged{}, false
+	}
+
+	purged := p.proxyService.PurgeCache(req.Prefix)
+	p.logger.InfoContext(r.Context(), "cache purged", "prefix", req.Prefix, "purged", purged)
+
+	return entities.CachePurged{Purged: purged}, true
+}
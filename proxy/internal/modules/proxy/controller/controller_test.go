@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/golang/mock/gomock"
+	"log"
+	"net/http/httptest"
+	"proxy/internal/modules/proxy/controller/mock_service"
+	"proxy/internal/modules/proxy/entities"
+	"proxy/internal/utils/logging"
+	"proxy/internal/utils/readresponder"
+	"testing"
+)
+
+func TestProxy_PurgeCache(t *testing.T) {
+	testCases := []struct {
+		name        string
+		body        any
+		wantStatus  int
+		wantMessage string
+	}{
+		{"purge prefix", entities.CachePurge{Prefix: "/tasks"}, 200, "cache purged"},
+		{"purge all", entities.CachePurge{}, 200, "cache purged"},
+		{"wrong body", struct{ Path string }{"/tasks"}, 400, `body contains unknown field "Path"`},
+	}
+
+	controller := gomock.NewController(t)
+	defer controller.Finish()
+
+	mockService := mock_service.NewMockProxyReverser(controller)
+	mockService.EXPECT().PurgeCache(gomock.Any()).Return(1).AnyTimes()
+
+	proxy := NewProxy(mockService, readresponder.NewReadRespond(), logging.Discard())
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var body bytes.Buffer
+			_ = json.NewEncoder(&body).Encode(tc.body)
+
+			req := httptest.NewRequest("POST", "/api/admin/cache/purge", &body)
+			wr := httptest.NewRecorder()
+
+			proxy.PurgeCache(wr, req)
+
+			r := wr.Result()
+
+			var resp readresponder.JSONResponse
+			err := json.NewDecoder(r.Body).Decode(&resp)
+			if err != nil {
+				log.Println(err)
+			}
+
+			defer r.Body.Close()
+
+			if r.StatusCode != tc.wantStatus {
+				t.Errorf("got status code %d, want %d", r.StatusCode, tc.wantStatus)
+			}
+
+			if tc.wantMessage != resp.Message {
+				t.Errorf("got message %s, want %s", resp.Message, tc.wantMessage)
+			}
+		})
+	}
+}
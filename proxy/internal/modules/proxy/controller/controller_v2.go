@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"net/http"
+	"proxy/internal/utils/readresponder"
+)
+
+// PurgeCacheV2 godoc
+// @Summary Purge proxy cache
+// @Security ApiKeyAuth
+// @Description Drop cached pages under the path prefix, or the whole cache if the prefix is empty
+// @Tags admin
+// @Accept json,application/msgpack,application/cbor
+// @Produce json,application/msgpack,application/cbor
+// @Param input body entities.CachePurge true "path prefix"
+// @Success 200 {object} readresponder.Envelope{data=entities.CachePurged}
+// @Failure 400,406,413,415,422 {object} readresponder.Problem "application/problem+json"
+// @Failure 403 {string} string
+// @Router /api/v2/admin/cache/purge [post]
+func (p *Proxy) PurgeCacheV2(w http.ResponseWriter, r *http.Request) {
+	purged, ok := p.purge(w, r)
+	if !ok {
+		return
+	}
+
+	p.readResponder.Write(w, r, http.StatusOK, readresponder.Envelope{Data: purged})
+}
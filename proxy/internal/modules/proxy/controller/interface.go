@@ -0,0 +1,8 @@
+package controller
+
+import "net/http"
+
+type CachePurger interface {
+	PurgeCache(w http.ResponseWriter, r *http.Request)
+	PurgeCacheV2(w http.ResponseWriter, r *http.Request)
+}
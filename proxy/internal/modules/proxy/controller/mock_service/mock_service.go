@@ -0,0 +1,104 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./proxy_interface.go
+
+// Package mock_service is a generated GoMock package.
+package mock_service
+
+import (
+	http "net/http"
+	entities "proxy/internal/modules/proxy/entities"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockProxyReverser is a mock of ProxyReverser interface.
+type MockProxyReverser struct {
+	ctrl     *gomock.Controller
+	recorder *MockProxyReverserMockRecorder
+}
+
+// MockProxyReverserMockRecorder is the mock recorder for MockProxyReverser.
+type MockProxyReverserMockRecorder struct {
+	mock *MockProxyReverser
+}
+
+// NewMockProxyReverser creates a new mock instance.
+func NewMockProxyReverser(ctrl *gomock.Controller) *MockProxyReverser {
+	mock := &MockProxyReverser{ctrl: ctrl}
+	mock.recorder = &MockProxyReverserMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProxyReverser) EXPECT() *MockProxyReverserMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method.
+func (m *MockProxyReverser) Close() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Close")
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockProxyReverserMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockProxyReverser)(nil).Close))
+}
+
+// ProxyReverse mocks base method.
+func (m *MockProxyReverser) ProxyReverse(next http.Handler) http.Handler {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProxyReverse", next)
+	ret0, _ := ret[0].(http.Handler)
+	return ret0
+}
+
+// ProxyReverse indicates an expected call of ProxyReverse.
+func (mr *MockProxyReverserMockRecorder) ProxyReverse(next interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProxyReverse", reflect.TypeOf((*MockProxyReverser)(nil).ProxyReverse), next)
+}
+
+// PurgeCache mocks base method.
+func (m *MockProxyReverser) PurgeCache(prefix string) int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeCache", prefix)
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// PurgeCache indicates an expected call of PurgeCache.
+func (mr *MockProxyReverserMockRecorder) PurgeCache(prefix interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeCache", reflect.TypeOf((*MockProxyReverser)(nil).PurgeCache), prefix)
+}
+
+// Reload mocks base method.
+func (m *MockProxyReverser) Reload() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reload")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reload indicates an expected call of Reload.
+func (mr *MockProxyReverserMockRecorder) Reload() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reload", reflect.TypeOf((*MockProxyReverser)(nil).Reload))
+}
+
+// UpstreamHealth mocks base method.
+func (m *MockProxyReverser) UpstreamHealth() map[string][]entities.UpstreamHealth {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpstreamHealth")
+	ret0, _ := ret[0].(map[string][]entities.UpstreamHealth)
+	return ret0
+}
+
+// UpstreamHealth indicates an expected call of UpstreamHealth.
+func (mr *MockProxyReverserMockRecorder) UpstreamHealth() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpstreamHealth", reflect.TypeOf((*MockProxyReverser)(nil).UpstreamHealth))
+}
@@ -0,0 +1,10 @@
+package entities
+
+type CachePurge struct {
+	// Path prefix starting with a slash; empty purges the whole cache
+	Prefix string `json:"prefix" binding:"omitempty,startswith=/" example:"/tasks"`
+}
+
+type CachePurged struct {
+	Purged int `json:"purged" example:"3"`
+}
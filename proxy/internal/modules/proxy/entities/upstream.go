@@ -0,0 +1,8 @@
+package entities
+
+// UpstreamHealth is the state of an upstream as seen by the health checks.
+type UpstreamHealth struct {
+	Upstream          string `json:"upstream" example:"hugo:1313"`
+	Healthy           bool   `json:"healthy" example:"true"`
+	ActiveConnections int64  `json:"active_connections" example:"2"`
+}
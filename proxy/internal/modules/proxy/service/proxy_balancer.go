@@ -0,0 +1,81 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	StrategyRoundRobin       = "round-robin"
+	StrategyLeastConnections = "least-connections"
+	StrategyWeighted         = "weighted"
+)
+
+// Balancer picks an upstream for the next request out of the healthy ones.
+type Balancer interface {
+	Next(upstreams []*Upstream) *Upstream
+}
+
+func NewBalancer(strategy string) (Balancer, error) {
+	switch strategy {
+	case "", StrategyRoundRobin:
+		return &RoundRobin{}, nil
+	case StrategyLeastConnections:
+		return &LeastConnections{}, nil
+	case StrategyWeighted:
+		return &Weighted{}, nil
+	default:
+		return nil, fmt.Errorf("unknown balancing strategy %q", strategy)
+	}
+}
+
+type RoundRobin struct {
+	counter atomic.Uint64
+}
+
+func (b *RoundRobin) Next(upstreams []*Upstream) *Upstream {
+	if len(upstreams) == 0 {
+		return nil
+	}
+	n := b.counter.Add(1) - 1
+	return upstreams[n%uint64(len(upstreams))]
+}
+
+type LeastConnections struct{}
+
+func (b *LeastConnections) Next(upstreams []*Upstream) *Upstream {
+	var best *Upstream
+	for _, u := range upstreams {
+		if best == nil || u.ActiveConnections() < best.ActiveConnections() {
+			best = u
+		}
+	}
+	return best
+}
+
+// Weighted implements smooth weighted round-robin, the same algorithm nginx
+// uses, so heavier upstreams are interleaved rather than picked in bursts.
+type Weighted struct {
+	m sync.Mutex
+}
+
+func (b *Weighted) Next(upstreams []*Upstream) *Upstream {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	var best *Upstream
+	total := 0
+	for _, u := range upstreams {
+		u.currentWeight += u.Weight
+		total += u.Weight
+		if best == nil || u.currentWeight > best.currentWeight {
+			best = u
+		}
+	}
+
+	if best != nil {
+		best.currentWeight -= total
+	}
+	return best
+}
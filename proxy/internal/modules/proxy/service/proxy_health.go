@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type HealthCheck struct {
+	Path      string
+	Interval  time.Duration
+	Timeout   time.Duration
+	Threshold int
+}
+
+var defaultHealthCheck = HealthCheck{
+	Path:      "/",
+	Interval:  10 * time.Second,
+	Timeout:   2 * time.Second,
+	Threshold: 3,
+}
+
+// healthCheck probes every upstream of the pool at once and then on each
+// tick until the context is cancelled, ejecting the ones that keep failing
+// and restoring recovered ones.
+func (rp *ProxyReverse) healthCheck(ctx context.Context, pool *Pool) {
+	ticker := time.NewTicker(rp.health.Interval)
+	defer ticker.Stop()
+
+	for {
+		for _, u := range pool.upstreams {
+			go rp.probe(ctx, u)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (rp *ProxyReverse) probe(ctx context.Context, u *Upstream) {
+	ctx, cancel := context.WithTimeout(ctx, rp.health.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.URL.JoinPath(rp.health.Path).String(), nil)
+	if err != nil {
+		rp.markFailure(ctx, u, err)
+		return
+	}
+
+	resp, err := rp.client.Do(req)
+	if err != nil {
+		rp.markFailure(ctx, u, err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		rp.markFailure(ctx, u, fmt.Errorf("health check returned %s", resp.Status))
+		return
+	}
+
+	rp.markSuccess(ctx, u)
+}
+
+// markFailure records a failure of u and logs its ejection from the pool.
+func (rp *ProxyReverse) markFailure(ctx context.Context, u *Upstream, err error) {
+	if u.markFailure(rp.health.Threshold) {
+		rp.logger.WarnContext(ctx, "upstream ejected", "upstream", u.URL.Host, "error", err)
+	}
+}
+
+// markSuccess records a success of u and logs its return to the pool.
+func (rp *ProxyReverse) markSuccess(ctx context.Context, u *Upstream) {
+	if u.markSuccess() {
+		rp.logger.InfoContext(ctx, "upstream restored", "upstream", u.URL.Host)
+	}
+}
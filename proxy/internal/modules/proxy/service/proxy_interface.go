@@ -1,7 +1,15 @@
 package service
 
-import "net/http"
+import (
+	"net/http"
+	"proxy/internal/modules/proxy/entities"
+)
 
+//go:generate mockgen -source=./proxy_interface.go -destination=../controller/mock_service/mock_service.go
 type ProxyReverser interface {
 	ProxyReverse(next http.Handler) http.Handler
+	Reload() error
+	PurgeCache(prefix string) int
+	UpstreamHealth() map[string][]entities.UpstreamHealth
+	Close()
 }
@@ -0,0 +1,65 @@
+package service
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	upstreamLabels = []string{"pool", "upstream"}
+
+	upstreamRequestsDesc = prometheus.NewDesc("proxy_upstream_requests_total",
+		"Requests forwarded to the upstream.", upstreamLabels, nil)
+	upstreamErrorsDesc = prometheus.NewDesc("proxy_upstream_errors_total",
+		"Requests the upstream failed to answer.", upstreamLabels, nil)
+	upstreamActiveDesc = prometheus.NewDesc("proxy_upstream_active_connections",
+		"Requests currently being served by the upstream.", upstreamLabels, nil)
+	upstreamHealthyDesc = prometheus.NewDesc("proxy_upstream_healthy",
+		"Whether the upstream is in rotation (1) or ejected (0).", upstreamLabels, nil)
+)
+
+// WithMetrics registers the statistics of the upstreams of the current
+// routing table with reg. They are read at scrape time, so pools added by
+// a reload show up without registering anything again.
+func WithMetrics(reg prometheus.Registerer) ProxyReverseOption {
+	return func(rp *ProxyReverse) {
+		reg.MustRegister(upstreamCollector{rp})
+	}
+}
+
+type upstreamCollector struct {
+	rp *ProxyReverse
+}
+
+func (c upstreamCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- upstreamRequestsDesc
+	ch <- upstreamErrorsDesc
+	ch <- upstreamActiveDesc
+	ch <- upstreamHealthyDesc
+}
+
+func (c upstreamCollector) Collect(ch chan<- prometheus.Metric) {
+	table := c.rp.table.Load()
+	if table == nil {
+		return
+	}
+
+	for name, pool := range table.pools {
+		seen := make(map[string]bool)
+		for _, u := range pool.upstreams {
+			target := u.URL.Host
+			// the same target listed twice in a pool would be a duplicate series
+			if seen[target] {
+				continue
+			}
+			seen[target] = true
+
+			healthy := 0.0
+			if u.Healthy() {
+				healthy = 1
+			}
+
+			ch <- prometheus.MustNewConstMetric(upstreamRequestsDesc, prometheus.CounterValue, float64(u.requests.Load()), name, target)
+			ch <- prometheus.MustNewConstMetric(upstreamErrorsDesc, prometheus.CounterValue, float64(u.errors.Load()), name, target)
+			ch <- prometheus.MustNewConstMetric(upstreamActiveDesc, prometheus.GaugeValue, float64(u.ActiveConnections()), name, target)
+			ch <- prometheus.MustNewConstMetric(upstreamHealthyDesc, prometheus.GaugeValue, healthy, name, target)
+		}
+	}
+}
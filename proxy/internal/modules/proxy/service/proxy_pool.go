@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// DefaultPool is the name of the pool built from the PROXY_* settings.
+const DefaultPool = "default"
+
+// Pool is a named group of upstreams sharing a balancing strategy.
+type Pool struct {
+	upstreams []*Upstream
+	balancer  Balancer
+	cancel    context.CancelFunc
+}
+
+func NewPool(upstreams []*Upstream, balancer Balancer) *Pool {
+	if balancer == nil {
+		balancer = &RoundRobin{}
+	}
+	return &Pool{upstreams: upstreams, balancer: balancer}
+}
+
+func (p *Pool) Upstreams() []*Upstream {
+	return p.upstreams
+}
+
+// Next returns the upstream to forward the request to, or nil if every
+// upstream of the pool has been ejected.
+func (p *Pool) Next() *Upstream {
+	healthy := make([]*Upstream, 0, len(p.upstreams))
+	for _, u := range p.upstreams {
+		if u.Healthy() {
+			healthy = append(healthy, u)
+		}
+	}
+	return p.balancer.Next(healthy)
+}
+
+// ServeHTTP forwards the request to the next healthy upstream of the pool.
+func (p *Pool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	span := trace.SpanFromContext(r.Context())
+
+	upstream := p.Next()
+	if upstream == nil {
+		span.SetStatus(codes.Error, "no healthy upstream")
+		writeErrorPage(w, http.StatusServiceUnavailable)
+		return
+	}
+	span.SetAttributes(attribute.String("proxy.upstream", upstream.URL.Host))
+
+	upstream.requests.Add(1)
+	upstream.active.Add(1)
+	defer upstream.active.Add(-1)
+
+	upstream.proxy.ServeHTTP(w, r)
+}
+
+func (rp *ProxyReverse) startPool(pool *Pool) {
+	for _, u := range pool.upstreams {
+		// upstreams reused by a reload keep the proxy in use
+		if u.proxy == nil {
+			u.proxy = rp.newReverseProxy(u)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pool.cancel = cancel
+	go rp.healthCheck(ctx, pool)
+}
+
+func (p *Pool) stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+}
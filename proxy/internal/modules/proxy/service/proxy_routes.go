@@ -0,0 +1,196 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// HandlerInternal routes the request to the application router instead of an upstream.
+const HandlerInternal = "internal"
+
+// RoutingConfig is the declarative routing table loaded from a YAML or JSON file.
+type RoutingConfig struct {
+	Upstreams map[string]PoolConfig `json:"upstreams" yaml:"upstreams"`
+	Routes    []Route               `json:"routes" yaml:"routes"`
+}
+
+type PoolConfig struct {
+	Targets  []string `json:"targets" yaml:"targets"`
+	Strategy string   `json:"strategy,omitempty" yaml:"strategy,omitempty"`
+}
+
+// Route maps requests matching a path prefix, and optionally a host and a set
+// of methods, to an upstream pool or an internal handler.
+type Route struct {
+	Prefix      string   `json:"prefix" yaml:"prefix"`
+	Host        string   `json:"host,omitempty" yaml:"host,omitempty"`
+	Methods     []string `json:"methods,omitempty" yaml:"methods,omitempty"`
+	Upstream    string   `json:"upstream,omitempty" yaml:"upstream,omitempty"`
+	Handler     string   `json:"handler,omitempty" yaml:"handler,omitempty"`
+	StripPrefix bool     `json:"strip_prefix,omitempty" yaml:"strip_prefix,omitempty"`
+	Rewrite     string   `json:"rewrite,omitempty" yaml:"rewrite,omitempty"`
+}
+
+type routingTable struct {
+	routes []Route
+	pools  map[string]*Pool
+}
+
+var defaultRoutes = []Route{
+	{Prefix: "/api", Handler: HandlerInternal},
+	{Prefix: "/swagger", Handler: HandlerInternal},
+	{Prefix: "/openapi.json", Handler: HandlerInternal},
+	{Prefix: "/healthz", Handler: HandlerInternal},
+	{Prefix: "/readyz", Handler: HandlerInternal},
+	{Prefix: "/", Upstream: DefaultPool},
+}
+
+func ReadRoutingConfig(path string) (RoutingConfig, error) {
+	var config RoutingConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(data, &config)
+	} else {
+		err = yaml.UnmarshalStrict(data, &config)
+	}
+	if err != nil {
+		return config, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// Validate reports every problem with the config at once.
+func (c RoutingConfig) Validate() error {
+	var errs []error
+
+	for name, pool := range c.Upstreams {
+		if len(pool.Targets) == 0 {
+			errs = append(errs, fmt.Errorf("upstream %q: no targets", name))
+		}
+		if _, err := NewBalancer(pool.Strategy); err != nil {
+			errs = append(errs, fmt.Errorf("upstream %q: %w", name, err))
+		}
+	}
+
+	for i, route := range c.Routes {
+		if !strings.HasPrefix(route.Prefix, "/") {
+			errs = append(errs, fmt.Errorf("route %d: prefix %q must start with /", i, route.Prefix))
+		}
+
+		switch {
+		case route.Handler != "" && route.Upstream != "":
+			errs = append(errs, fmt.Errorf("route %d: handler and upstream are mutually exclusive", i))
+		case route.Handler != "" && route.Handler != HandlerInternal:
+			errs = append(errs, fmt.Errorf("route %d: unknown handler %q", i, route.Handler))
+		case route.Handler == "" && route.Upstream == "":
+			errs = append(errs, fmt.Errorf("route %d: either handler or upstream is required", i))
+		case route.Upstream != "" && route.Upstream != DefaultPool:
+			if _, ok := c.Upstreams[route.Upstream]; !ok {
+				errs = append(errs, fmt.Errorf("route %d: unknown upstream %q", i, route.Upstream))
+			}
+		}
+
+		if route.StripPrefix && route.Rewrite != "" {
+			errs = append(errs, fmt.Errorf("route %d: strip_prefix and rewrite are mutually exclusive", i))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func newRoutingTable(routes []Route, pools map[string]*Pool) *routingTable {
+	sorted := make([]Route, len(routes))
+	copy(sorted, routes)
+
+	// the most specific prefix wins, host-bound routes before catch-all ones
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if len(sorted[i].Prefix) != len(sorted[j].Prefix) {
+			return len(sorted[i].Prefix) > len(sorted[j].Prefix)
+		}
+		return sorted[i].Host != "" && sorted[j].Host == ""
+	})
+
+	// the methods are copied, the routes of the caller stay as they are
+	for i := range sorted {
+		methods := make([]string, len(sorted[i].Methods))
+		for j, method := range sorted[i].Methods {
+			methods[j] = strings.ToUpper(method)
+		}
+		sorted[i].Methods = methods
+	}
+
+	return &routingTable{routes: sorted, pools: pools}
+}
+
+func (t *routingTable) match(r *http.Request) *Route {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	for i := range t.routes {
+		route := &t.routes[i]
+
+		if !hasPathPrefix(r.URL.Path, route.Prefix) {
+			continue
+		}
+		if route.Host != "" && !strings.EqualFold(route.Host, host) {
+			continue
+		}
+		if len(route.Methods) > 0 && !contains(route.Methods, r.Method) {
+			continue
+		}
+
+		return route
+	}
+
+	return nil
+}
+
+// rewrite applies the route's prefix stripping or rewriting to the request path.
+func (route *Route) rewrite(r *http.Request) {
+	if !route.StripPrefix && route.Rewrite == "" {
+		return
+	}
+
+	rest := strings.TrimPrefix(r.URL.Path, strings.TrimSuffix(route.Prefix, "/"))
+	path := strings.TrimSuffix(route.Rewrite, "/") + rest
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	r.URL.Path = path
+	r.URL.RawPath = ""
+}
+
+// hasPathPrefix matches whole path segments, so /api does not match /apidocs.
+func hasPathPrefix(path, prefix string) bool {
+	if prefix == "/" {
+		return true
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
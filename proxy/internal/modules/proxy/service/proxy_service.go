@@ -1,50 +1,295 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"log/slog"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
-	"strings"
+	"proxy/internal/modules/proxy/entities"
+	"proxy/internal/utils/httpcache"
+	"proxy/internal/utils/logging"
+	"proxy/internal/utils/tracing"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+var ErrorNoRoutesFile = errors.New("no routes file configured")
+
 type ProxyReverse struct {
-	host string
-	port string
+	defaultPool   *Pool
+	health        HealthCheck
+	transport     http.RoundTripper
+	traceProvider trace.TracerProvider
+	tracer        trace.Tracer
+	client        *http.Client
+	cache         *httpcache.Cache
+	logger        *slog.Logger
+	routesPath    string
+	streamIdle    time.Duration
+
+	table atomic.Pointer[routingTable]
+	m     sync.Mutex // serializes reloads
+}
+
+type ProxyReverseOption func(*ProxyReverse)
+
+func WithBalancer(balancer Balancer) ProxyReverseOption {
+	return func(rp *ProxyReverse) {
+		rp.defaultPool.balancer = balancer
+	}
+}
+
+func WithHealthCheck(health HealthCheck) ProxyReverseOption {
+	return func(rp *ProxyReverse) {
+		if health.Path != "" {
+			rp.health.Path = health.Path
+		}
+		if health.Interval > 0 {
+			rp.health.Interval = health.Interval
+		}
+		if health.Timeout > 0 {
+			rp.health.Timeout = health.Timeout
+		}
+		if health.Threshold > 0 {
+			rp.health.Threshold = health.Threshold
+		}
+	}
+}
+
+// WithTransport replaces the tuned default transport used for upstreams.
+func WithTransport(transport http.RoundTripper) ProxyReverseOption {
+	return func(rp *ProxyReverse) {
+		rp.transport = transport
+	}
+}
+
+// WithTracerProvider records a span for every proxied request and
+// propagates the trace context to the upstreams.
+func WithTracerProvider(tp trace.TracerProvider) ProxyReverseOption {
+	return func(rp *ProxyReverse) {
+		rp.traceProvider = tp
+	}
+}
+
+// WithLogger logs failed upstream requests and upstreams being ejected
+// from or restored to their pool.
+func WithLogger(logger *slog.Logger) ProxyReverseOption {
+	return func(rp *ProxyReverse) {
+		rp.logger = logger
+	}
 }
 
-func NewProxyReverse(host, port string) *ProxyReverse {
-	return &ProxyReverse{
-		host: host,
-		port: port,
+// WithCache enables caching of upstream responses.
+func WithCache(cache *httpcache.Cache) ProxyReverseOption {
+	return func(rp *ProxyReverse) {
+		rp.cache = cache
 	}
 }
 
+// WithStreamIdleTimeout sets how long WebSocket and SSE connections may stay
+// silent before they are closed.
+func WithStreamIdleTimeout(idle time.Duration) ProxyReverseOption {
+	return func(rp *ProxyReverse) {
+		if idle > 0 {
+			rp.streamIdle = idle
+		}
+	}
+}
+
+// WithRoutesFile makes Reload read the routing table from the given file.
+func WithRoutesFile(path string) ProxyReverseOption {
+	return func(rp *ProxyReverse) {
+		rp.routesPath = path
+	}
+}
+
+func NewProxyReverse(upstreams []*Upstream, options ...ProxyReverseOption) *ProxyReverse {
+	rp := &ProxyReverse{
+		defaultPool: NewPool(upstreams, nil),
+		health:      defaultHealthCheck,
+		transport:   NewTransport(),
+		streamIdle:  defaultStreamIdleTimeout,
+		logger:      logging.Discard(),
+	}
+
+	for _, option := range options {
+		option(rp)
+	}
+
+	// health probes go through the bare transport, they are not worth tracing
+	rp.client = &http.Client{Transport: rp.transport, Timeout: rp.health.Timeout}
+	rp.transport = logging.NewTransport(rp.transport)
+	if rp.traceProvider != nil {
+		rp.tracer = rp.traceProvider.Tracer("proxy/internal/modules/proxy/service")
+		rp.transport = tracing.NewTransport(rp.traceProvider, rp.transport)
+	} else {
+		rp.tracer = noop.NewTracerProvider().Tracer("")
+	}
+
+	rp.startPool(rp.defaultPool)
+	rp.table.Store(newRoutingTable(defaultRoutes, map[string]*Pool{DefaultPool: rp.defaultPool}))
+
+	return rp
+}
+
+// Reload reads the routes file and atomically swaps the routing table.
+// Requests already in flight keep using the table they were matched against.
+func (rp *ProxyReverse) Reload() error {
+	if rp.routesPath == "" {
+		return ErrorNoRoutesFile
+	}
+
+	config, err := ReadRoutingConfig(rp.routesPath)
+	if err != nil {
+		return err
+	}
+
+	return rp.LoadRoutes(config)
+}
+
+func (rp *ProxyReverse) LoadRoutes(config RoutingConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	rp.m.Lock()
+	defer rp.m.Unlock()
+
+	// upstreams kept across reloads keep their health and active connections
+	current := make(map[string]*Upstream)
+	for name, pool := range rp.table.Load().pools {
+		for _, u := range pool.upstreams {
+			current[upstreamKey(name, u)] = u
+		}
+	}
+
+	pools := map[string]*Pool{DefaultPool: rp.defaultPool}
+	for name, poolConfig := range config.Upstreams {
+		upstreams := make([]*Upstream, 0, len(poolConfig.Targets))
+		for _, target := range poolConfig.Targets {
+			list, err := ParseUpstreams(target)
+			if err != nil {
+				return err
+			}
+			for _, u := range list {
+				if existing, ok := current[upstreamKey(name, u)]; ok {
+					delete(current, upstreamKey(name, u))
+					u = existing
+				}
+				upstreams = append(upstreams, u)
+			}
+		}
+
+		balancer, _ := NewBalancer(poolConfig.Strategy)
+		pools[name] = NewPool(upstreams, balancer)
+	}
+
+	for _, pool := range pools {
+		if pool != rp.defaultPool {
+			rp.startPool(pool)
+		}
+	}
+
+	old := rp.table.Swap(newRoutingTable(config.Routes, pools))
+	for _, pool := range old.pools {
+		if pool != rp.defaultPool {
+			pool.stop()
+		}
+	}
+
+	return nil
+}
+
+// upstreamKey identifies an upstream of a pool across reloads; a new weight
+// makes a new upstream.
+func upstreamKey(pool string, u *Upstream) string {
+	return fmt.Sprintf("%s %s %d", pool, u.URL, u.Weight)
+}
+
+// PurgeCache drops cached upstream responses under the path prefix.
+func (rp *ProxyReverse) PurgeCache(prefix string) int {
+	if rp.cache == nil {
+		return 0
+	}
+	return rp.cache.Purge(prefix)
+}
+
+// UpstreamHealth returns the upstreams of every pool of the current routing
+// table, keyed by pool name.
+func (rp *ProxyReverse) UpstreamHealth() map[string][]entities.UpstreamHealth {
+	pools := make(map[string][]entities.UpstreamHealth)
+	for name, pool := range rp.table.Load().pools {
+		for _, u := range pool.upstreams {
+			pools[name] = append(pools[name], entities.UpstreamHealth{
+				Upstream:          u.URL.Host,
+				Healthy:           u.Healthy(),
+				ActiveConnections: u.ActiveConnections(),
+			})
+		}
+	}
+	return pools
+}
+
+// Close stops the background health checks and drops idle upstream
+// connections.
+func (rp *ProxyReverse) Close() {
+	for _, pool := range rp.table.Load().pools {
+		pool.stop()
+	}
+	rp.client.CloseIdleConnections()
+}
+
 // localhost:1313/static -> hugo
 // localhost:1313/api -> api
 
 func (rp *ProxyReverse) ProxyReverse(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasPrefix(r.URL.Path, "/api") || strings.HasPrefix(r.URL.Path, "/swagger") {
-			next.ServeHTTP(w, r)
+		table := rp.table.Load()
+
+		route := table.match(r)
+		if route == nil {
+			http.NotFound(w, r)
 			return
 		}
-		link := fmt.Sprintf("http://%s:%s", rp.host, rp.port)
-		uri, _ := url.Parse(link)
 
-		if uri.Host == r.Host {
+		route.rewrite(r)
+
+		if route.Handler == HandlerInternal {
 			next.ServeHTTP(w, r)
 			return
 		}
-		r.Header.Set("Reverse-Proxy", "true")
 
-		proxy := httputil.ReverseProxy{Director: func(r *http.Request) {
-			r.URL.Scheme = uri.Scheme
-			r.URL.Host = uri.Host
-			r.URL.Path = uri.Path + r.URL.Path
-			r.Host = uri.Host
-		}}
+		pool := table.pools[route.Upstream]
+		for _, p := range table.pools {
+			for _, u := range p.upstreams {
+				if u.URL.Host == r.Host {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+		}
+
+		ctx, span := rp.tracer.Start(r.Context(), "proxy "+route.Upstream, trace.WithAttributes(
+			attribute.String("proxy.route", route.Prefix),
+			attribute.String("proxy.pool", route.Upstream),
+		))
+		defer span.End()
+		r = r.WithContext(ctx)
+
+		if isStream(r) {
+			pool.ServeHTTP(newStreamWriter(w, rp.streamIdle), r)
+			return
+		}
+
+		if rp.cache != nil {
+			rp.cache.Middleware(pool).ServeHTTP(w, r)
+			return
+		}
 
-		proxy.ServeHTTP(w, r)
+		pool.ServeHTTP(w, r)
 	})
 }
@@ -0,0 +1,448 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseUpstreams(t *testing.T) {
+	testCases := []struct {
+		name        string
+		input       string
+		wantHosts   []string
+		wantWeights []int
+		wantErr     bool
+	}{
+		{"single upstream", "hugo:1313", []string{"hugo:1313"}, []int{1}, false},
+		{"weighted upstreams", "hugo:1313=3, backup:1313", []string{"hugo:1313", "backup:1313"}, []int{3, 1}, false},
+		{"explicit scheme", "https://hugo:443=2", []string{"hugo:443"}, []int{2}, false},
+		{"invalid weight", "hugo:1313=x", nil, nil, true},
+		{"empty list", " , ", nil, nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			upstreams, err := ParseUpstreams(tc.input)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseUpstreams(%q) error = %v, wantErr %v", tc.input, err, tc.wantErr)
+			}
+
+			if len(upstreams) != len(tc.wantHosts) {
+				t.Fatalf("got %d upstreams, want %d", len(upstreams), len(tc.wantHosts))
+			}
+
+			for i, u := range upstreams {
+				if u.URL.Host != tc.wantHosts[i] || u.Weight != tc.wantWeights[i] {
+					t.Errorf("got upstream %s=%d, want %s=%d", u.URL.Host, u.Weight, tc.wantHosts[i], tc.wantWeights[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBalancers(t *testing.T) {
+	a, _ := NewUpstream("a:80", 3)
+	b, _ := NewUpstream("b:80", 1)
+	b.active.Add(5)
+
+	testCases := []struct {
+		name     string
+		balancer Balancer
+		want     string
+	}{
+		{"round-robin", &RoundRobin{}, "abab"},
+		{"least-connections", &LeastConnections{}, "aaaa"},
+		{"weighted", &Weighted{}, "aaba"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got string
+			for range tc.want {
+				got += tc.balancer.Next([]*Upstream{a, b}).URL.Hostname()
+			}
+
+			if got != tc.want {
+				t.Errorf("got sequence %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProxyReverse_Ejection(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("healthy"))
+	}))
+	defer healthy.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	upstreams, _ := ParseUpstreams(healthy.URL + "," + failing.URL)
+	reg := prometheus.NewRegistry()
+	rp := NewProxyReverse(upstreams,
+		WithHealthCheck(HealthCheck{Interval: 10 * time.Millisecond, Threshold: 1}),
+		WithMetrics(reg),
+	)
+	defer rp.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for upstreams[1].Healthy() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if upstreams[1].Healthy() {
+		t.Fatal("failing upstream was not ejected")
+	}
+
+	handler := rp.ProxyReverse(http.NotFoundHandler())
+	for i := 0; i < 4; i++ {
+		wr := httptest.NewRecorder()
+		handler.ServeHTTP(wr, httptest.NewRequest("GET", "/tasks/", nil))
+
+		body, _ := io.ReadAll(wr.Result().Body)
+		if wr.Code != http.StatusOK || string(body) != "healthy" {
+			t.Errorf("got %d %q, want 200 from the healthy upstream", wr.Code, body)
+		}
+	}
+
+	want := fmt.Sprintf(`
+# HELP proxy_upstream_healthy Whether the upstream is in rotation (1) or ejected (0).
+# TYPE proxy_upstream_healthy gauge
+proxy_upstream_healthy{pool="default",upstream="%[1]s"} 1
+proxy_upstream_healthy{pool="default",upstream="%[2]s"} 0
+# HELP proxy_upstream_requests_total Requests forwarded to the upstream.
+# TYPE proxy_upstream_requests_total counter
+proxy_upstream_requests_total{pool="default",upstream="%[1]s"} 4
+proxy_upstream_requests_total{pool="default",upstream="%[2]s"} 0
+`, upstreams[0].URL.Host, upstreams[1].URL.Host)
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "proxy_upstream_healthy", "proxy_upstream_requests_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProxyReverse_StartupProbe(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	upstreams, _ := ParseUpstreams(failing.URL)
+	// the first tick is an hour away, only the probe at startup runs
+	rp := NewProxyReverse(upstreams, WithHealthCheck(HealthCheck{Interval: time.Hour, Threshold: 3}))
+	defer rp.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for upstreams[0].Healthy() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if upstreams[0].Healthy() {
+		t.Error("got the failing upstream in rotation, want it ejected by the startup probe")
+	}
+}
+
+func TestProxyReverse_PassiveHealth(t *testing.T) {
+	testCases := []struct {
+		name         string
+		status       int
+		wantFailures int
+	}{
+		{"success resets failures", http.StatusNotFound, 0},
+		{"server error keeps failures", http.StatusBadGateway, 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+			}))
+			defer server.Close()
+
+			upstreams, _ := ParseUpstreams(server.URL)
+			rp := NewProxyReverse(upstreams, WithHealthCheck(HealthCheck{Path: "/health", Interval: time.Hour, Threshold: 3}))
+			defer rp.Close()
+
+			u := upstreams[0]
+			u.markSuccess()
+			u.markFailure(3)
+			u.markFailure(3)
+
+			wr := httptest.NewRecorder()
+			u.proxy.ServeHTTP(wr, httptest.NewRequest("GET", "/tasks/", nil))
+			if wr.Code != tc.status {
+				t.Fatalf("got status %d, want %d", wr.Code, tc.status)
+			}
+
+			u.m.Lock()
+			failures := u.failures
+			u.m.Unlock()
+			// the startup probe may have run too, with the same status
+			if tc.wantFailures == 0 && failures != 0 || tc.wantFailures > 0 && failures < tc.wantFailures {
+				t.Errorf("got %d failures, want %d", failures, tc.wantFailures)
+			}
+		})
+	}
+}
+
+func TestRoutingConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		config  RoutingConfig
+		wantErr bool
+	}{
+		{"default upstream", RoutingConfig{Routes: []Route{{Prefix: "/", Upstream: DefaultPool}}}, false},
+		{"declared upstream", RoutingConfig{
+			Upstreams: map[string]PoolConfig{"docs": {Targets: []string{"docs:80"}}},
+			Routes:    []Route{{Prefix: "/docs", Upstream: "docs", StripPrefix: true}},
+		}, false},
+		{"unknown upstream", RoutingConfig{Routes: []Route{{Prefix: "/", Upstream: "missing"}}}, true},
+		{"unknown handler", RoutingConfig{Routes: []Route{{Prefix: "/", Handler: "static"}}}, true},
+		{"relative prefix", RoutingConfig{Routes: []Route{{Prefix: "api", Handler: HandlerInternal}}}, true},
+		{"unknown strategy", RoutingConfig{Upstreams: map[string]PoolConfig{"docs": {Targets: []string{"docs:80"}, Strategy: "random"}}}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestProxyReverse_Routes(t *testing.T) {
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("upstream " + r.URL.Path))
+	}))
+	defer echo.Close()
+
+	upstreams, _ := ParseUpstreams(echo.URL)
+	rp := NewProxyReverse(upstreams)
+	defer rp.Close()
+
+	err := rp.LoadRoutes(RoutingConfig{
+		Upstreams: map[string]PoolConfig{"echo": {Targets: []string{echo.URL}}},
+		Routes: []Route{
+			{Prefix: "/api", Handler: HandlerInternal},
+			{Prefix: "/docs", Upstream: "echo", StripPrefix: true},
+			{Prefix: "/old", Upstream: "echo", Rewrite: "/new"},
+			{Prefix: "/admin", Host: "admin.local", Handler: HandlerInternal},
+			{Prefix: "/", Methods: []string{"get"}, Upstream: "echo"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("LoadRoutes() error = %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		method   string
+		host     string
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{"internal handler", "GET", "example.com", "/api/address", 200, "internal /api/address"},
+		{"strip prefix", "GET", "example.com", "/docs/intro", 200, "upstream /intro"},
+		{"rewrite prefix", "GET", "example.com", "/old/page", 200, "upstream /new/page"},
+		{"host match", "GET", "admin.local:8080", "/admin/users", 200, "internal /admin/users"},
+		{"host mismatch", "GET", "example.com", "/admin/users", 200, "upstream /admin/users"},
+		{"method mismatch", "POST", "example.com", "/tasks", 404, "404 page not found\n"},
+	}
+
+	internal := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal " + r.URL.Path))
+	})
+	handler := rp.ProxyReverse(internal)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			req.Host = tc.host
+			wr := httptest.NewRecorder()
+
+			handler.ServeHTTP(wr, req)
+
+			body, _ := io.ReadAll(wr.Result().Body)
+			if wr.Code != tc.wantCode || string(body) != tc.wantBody {
+				t.Errorf("got %d %q, want %d %q", wr.Code, body, tc.wantCode, tc.wantBody)
+			}
+		})
+	}
+}
+
+func TestProxyReverse_Reload(t *testing.T) {
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer echo.Close()
+
+	upstreams, _ := ParseUpstreams(echo.URL)
+	rp := NewProxyReverse(upstreams)
+	defer rp.Close()
+
+	config := RoutingConfig{
+		Upstreams: map[string]PoolConfig{"echo": {Targets: []string{echo.URL}, Strategy: StrategyLeastConnections}},
+		Routes:    []Route{{Prefix: "/", Methods: []string{"get"}, Upstream: "echo"}},
+	}
+	if err := rp.LoadRoutes(config); err != nil {
+		t.Fatalf("LoadRoutes() error = %v", err)
+	}
+	if config.Routes[0].Methods[0] != "get" {
+		t.Errorf("got config methods %v, want them unchanged", config.Routes[0].Methods)
+	}
+
+	before := rp.table.Load().pools["echo"].upstreams[0]
+	before.active.Add(1)
+	defer before.active.Add(-1)
+
+	if err := rp.LoadRoutes(config); err != nil {
+		t.Fatalf("LoadRoutes() error = %v", err)
+	}
+	after := rp.table.Load().pools["echo"].upstreams[0]
+	if after != before || after.active.Load() != 1 {
+		t.Errorf("got a new upstream with %d active, want the upstream kept across the reload", after.active.Load())
+	}
+
+	config.Upstreams["echo"] = PoolConfig{Targets: []string{echo.URL + "=2"}}
+	if err := rp.LoadRoutes(config); err != nil {
+		t.Fatalf("LoadRoutes() error = %v", err)
+	}
+	if rp.table.Load().pools["echo"].upstreams[0] == before {
+		t.Errorf("got the old upstream, want a new one for the new weight")
+	}
+}
+
+func TestReadRoutingConfig(t *testing.T) {
+	config, err := ReadRoutingConfig("../../../../routes.yaml")
+	if err != nil {
+		t.Fatalf("ReadRoutingConfig() error = %v", err)
+	}
+
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestProxyReverse_Forwarding(t *testing.T) {
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Forwarded-Host") + " " + r.Header.Get("X-Forwarded-Proto") + " " + r.Header.Get("Reverse-Proxy")))
+	}))
+	defer echo.Close()
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	testCases := []struct {
+		name     string
+		target   string
+		wantCode int
+		wantBody string
+	}{
+		{"forwarded headers", echo.URL, 200, "geo.local http true"},
+		{"upstream down", down.URL, 502, "502"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			upstreams, _ := ParseUpstreams(tc.target)
+			rp := NewProxyReverse(upstreams)
+			defer rp.Close()
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Host = "geo.local"
+			wr := httptest.NewRecorder()
+
+			rp.ProxyReverse(http.NotFoundHandler()).ServeHTTP(wr, req)
+
+			body, _ := io.ReadAll(wr.Result().Body)
+			if wr.Code != tc.wantCode || !strings.Contains(string(body), tc.wantBody) {
+				t.Errorf("got %d %q, want %d containing %q", wr.Code, body, tc.wantCode, tc.wantBody)
+			}
+		})
+	}
+}
+
+func TestProxyReverse_Streams(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") == "echo" {
+			w.Header().Set("Connection", "Upgrade")
+			w.Header().Set("Upgrade", "echo")
+			w.WriteHeader(http.StatusSwitchingProtocols)
+
+			conn, brw, _ := http.NewResponseController(w).Hijack()
+			defer conn.Close()
+			io.Copy(conn, brw)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			time.Sleep(100 * time.Millisecond)
+			w.Write([]byte("data: tick\n\n"))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer upstream.Close()
+
+	upstreams, _ := ParseUpstreams(upstream.URL)
+	rp := NewProxyReverse(upstreams, WithStreamIdleTimeout(time.Second))
+	defer rp.Close()
+
+	// stream lifetimes exceed the server timeouts on purpose
+	server := httptest.NewUnstartedServer(rp.ProxyReverse(http.NotFoundHandler()))
+	server.Config.ReadTimeout = 150 * time.Millisecond
+	server.Config.WriteTimeout = 150 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	t.Run("server-sent events", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", server.URL+"/events", nil)
+		req.Header.Set("Accept", "text/event-stream")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request error = %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil || strings.Count(string(body), "data: tick") != 3 {
+			t.Errorf("got %q, %v, want 3 events", body, err)
+		}
+	})
+
+	t.Run("upgraded connection", func(t *testing.T) {
+		conn, err := net.Dial("tcp", server.Listener.Addr().String())
+		if err != nil {
+			t.Fatalf("dial error = %v", err)
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("GET /livereload HTTP/1.1\r\nHost: geo.local\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n"))
+		br := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(br, nil)
+		if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+			t.Fatalf("got %v, %v, want 101", resp, err)
+		}
+
+		for i := 0; i < 3; i++ {
+			time.Sleep(100 * time.Millisecond)
+			conn.Write([]byte("ping\n"))
+			line, err := br.ReadString('\n')
+			if err != nil || line != "ping\n" {
+				t.Fatalf("got %q, %v, want echoed ping", line, err)
+			}
+		}
+	})
+}
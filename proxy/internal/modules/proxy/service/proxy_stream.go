@@ -0,0 +1,91 @@
+package service
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultStreamIdleTimeout = 5 * time.Minute
+
+// isStream reports whether the request opens a long-lived connection:
+// a protocol upgrade such as a WebSocket or a Server-Sent Events stream.
+func isStream(r *http.Request) bool {
+	if r.Header.Get("Upgrade") != "" && headerContains(r.Header, "Connection", "upgrade") {
+		return true
+	}
+	return headerContains(r.Header, "Accept", "text/event-stream")
+}
+
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			part, _, _ = strings.Cut(part, ";")
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// streamWriter replaces the server-wide read and write timeouts, which would
+// cut a stream after a few seconds, with an idle timeout that is pushed
+// forward on every write. Hijacked connections get the same treatment on
+// every read and write in either direction.
+type streamWriter struct {
+	http.ResponseWriter
+	rc   *http.ResponseController
+	idle time.Duration
+}
+
+func newStreamWriter(w http.ResponseWriter, idle time.Duration) *streamWriter {
+	sw := &streamWriter{ResponseWriter: w, rc: http.NewResponseController(w), idle: idle}
+	sw.extend()
+	return sw
+}
+
+func (sw *streamWriter) extend() {
+	deadline := time.Now().Add(sw.idle)
+	sw.rc.SetReadDeadline(deadline)
+	sw.rc.SetWriteDeadline(deadline)
+}
+
+func (sw *streamWriter) Write(b []byte) (int, error) {
+	sw.extend()
+	return sw.ResponseWriter.Write(b)
+}
+
+func (sw *streamWriter) Flush() {
+	sw.extend()
+	sw.rc.Flush()
+}
+
+func (sw *streamWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := sw.rc.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &idleConn{Conn: conn, idle: sw.idle}, brw, nil
+}
+
+func (sw *streamWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+type idleConn struct {
+	net.Conn
+	idle time.Duration
+}
+
+func (c *idleConn) Read(b []byte) (int, error) {
+	c.Conn.SetDeadline(time.Now().Add(c.idle))
+	return c.Conn.Read(b)
+}
+
+func (c *idleConn) Write(b []byte) (int, error) {
+	c.Conn.SetDeadline(time.Now().Add(c.idle))
+	return c.Conn.Write(b)
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"time"
+)
+
+// NewTransport returns the transport shared by all upstream proxies, keeping
+// a pool of idle connections per upstream instead of dialing per request.
+func NewTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   32,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// newReverseProxy builds the long-lived proxy for a single upstream.
+func (rp *ProxyReverse) newReverseProxy(u *Upstream) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(u.URL)
+			pr.SetXForwarded()
+			pr.Out.Header.Set("Reverse-Proxy", "true")
+		},
+		Transport: rp.transport,
+		ModifyResponse: func(resp *http.Response) error {
+			// 5xx fail the health probe, they must not restore the upstream
+			if resp.StatusCode < http.StatusInternalServerError {
+				rp.markSuccess(resp.Request.Context(), u)
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			// a client going away says nothing about the upstream health
+			if !errors.Is(err, context.Canceled) {
+				u.errors.Add(1)
+				rp.logger.WarnContext(r.Context(), "upstream request failed", "upstream", u.URL.Host, "error", err)
+				rp.markFailure(r.Context(), u, err)
+			}
+			writeErrorPage(w, http.StatusBadGateway)
+		},
+	}
+}
+
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Code}} {{.Status}} | Geoservice</title>
+<style>
+body { font-family: sans-serif; background: #f5f5f5; color: #333; display: flex; align-items: center; justify-content: center; height: 100vh; margin: 0; }
+main { text-align: center; }
+h1 { font-size: 4rem; margin: 0; color: #004ed0; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Code}}</h1>
+<p><strong>{{.Status}}</strong></p>
+<p>Geoservice could not reach the page you requested. Please try again in a moment.</p>
+</main>
+</body>
+</html>
+`))
+
+func writeErrorPage(w http.ResponseWriter, code int) {
+	w.Header().Set("Content-Type", "text/html;charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+
+	errorPage.Execute(w, struct {
+		Code   int
+		Status string
+	}{code, http.StatusText(code)})
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var ErrorNoUpstreams = errors.New("no upstreams configured")
+
+// Upstream is a single backend the proxy balances requests across.
+type Upstream struct {
+	URL    *url.URL
+	Weight int
+
+	proxy *httputil.ReverseProxy
+
+	active   atomic.Int64
+	healthy  atomic.Bool
+	requests atomic.Int64
+	errors   atomic.Int64
+
+	m        sync.Mutex
+	failures int
+	// checked is set by the first probe or response, until which a single
+	// failure is enough to eject the upstream
+	checked bool
+
+	// guarded by the Weighted balancer
+	currentWeight int
+}
+
+func NewUpstream(target string, weight int) (*Upstream, error) {
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+
+	uri, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if uri.Host == "" {
+		return nil, fmt.Errorf("upstream %q has no host", target)
+	}
+
+	if weight < 1 {
+		weight = 1
+	}
+
+	u := &Upstream{URL: uri, Weight: weight}
+	u.healthy.Store(true)
+
+	return u, nil
+}
+
+// ParseUpstreams parses a comma-separated list of targets in the form
+// host:port[=weight], e.g. "hugo:1313=3,backup:1313".
+func ParseUpstreams(list string) ([]*Upstream, error) {
+	var upstreams []*Upstream
+
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		target, weight := item, 1
+		if i := strings.LastIndex(item, "="); i > 0 {
+			w, err := strconv.Atoi(item[i+1:])
+			if err != nil {
+				return nil, fmt.Errorf("upstream %q: invalid weight: %w", item, err)
+			}
+			target, weight = item[:i], w
+		}
+
+		upstream, err := NewUpstream(target, weight)
+		if err != nil {
+			return nil, err
+		}
+		upstreams = append(upstreams, upstream)
+	}
+
+	if len(upstreams) == 0 {
+		return nil, ErrorNoUpstreams
+	}
+
+	return upstreams, nil
+}
+
+func (u *Upstream) Healthy() bool {
+	return u.healthy.Load()
+}
+
+func (u *Upstream) ActiveConnections() int64 {
+	return u.active.Load()
+}
+
+// markFailure records a failed probe or request and ejects the upstream
+// once the number of consecutive failures reaches the threshold, or at
+// once if it never answered yet. It reports whether this call ejected it.
+func (u *Upstream) markFailure(threshold int) (ejected bool) {
+	u.m.Lock()
+	defer u.m.Unlock()
+
+	u.failures++
+	if u.failures >= threshold || !u.checked {
+		u.checked = true
+		return u.healthy.Swap(false)
+	}
+	return false
+}
+
+// markSuccess resets the failure count and reports whether the upstream
+// was restored by this call.
+func (u *Upstream) markSuccess() (restored bool) {
+	u.m.Lock()
+	defer u.m.Unlock()
+
+	u.failures = 0
+	u.checked = true
+	return !u.healthy.Swap(true)
+}
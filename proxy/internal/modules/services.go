@@ -1,34 +1,204 @@
 package modules
 
 import (
-	"proxy/internal/modules/auth/entities"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"proxy/internal/config"
+	"proxy/internal/modules/auth/repository"
 	"proxy/internal/modules/auth/repository/dbrepo"
 	aservice "proxy/internal/modules/auth/service"
+	gentities "proxy/internal/modules/geo/entities"
 	gservice "proxy/internal/modules/geo/service"
+	hservice "proxy/internal/modules/health/service"
 	pservice "proxy/internal/modules/proxy/service"
+	"proxy/internal/utils/breaker"
+	"proxy/internal/utils/httpcache"
+	"proxy/internal/utils/logging"
+	"proxy/internal/utils/metrics"
+	"proxy/internal/utils/tracing"
+	"time"
 )
 
-type ServicesConfig struct {
-	Port      string
-	JwtAlg    string
-	JwtSecret string
-	ApiKey    string
-	SecretKey string
-	ProxyHost string
-	ProxyPort string
-}
-
 type Services struct {
-	Geo   gservice.GeoServicer
-	Auth  aservice.Authenticator
-	Proxy pservice.ProxyReverser
+	Geo     gservice.GeoServicer
+	Auth    aservice.Authenticator
+	Proxy   pservice.ProxyReverser
+	Health  hservice.HealthChecker
+	Metrics *prometheus.Registry
+	Tracing tracing.Provider
 }
 
-func NewServices(config ServicesConfig) *Services {
-	db := dbrepo.NewMapDBRepo(entities.User{Email: "admin@example.com", Password: "password"})
+func NewServices(cfg *config.Config, logger *slog.Logger) (*Services, error) {
+	reg := metrics.NewRegistry()
+
+	tp, err := tracing.NewProvider(context.Background(), cfg.Tracing.Exporter,
+		tracing.WithServiceName(cfg.Tracing.ServiceName),
+		tracing.WithOTLPEndpoint(cfg.Tracing.Endpoint, cfg.Tracing.Insecure),
+		tracing.WithSampleRatio(cfg.Tracing.SampleRatio),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// a single PROXY_HOST:PROXY_PORT target is kept for backward compatibility
+	upstreamList := cfg.Proxy.Upstreams
+	if upstreamList == "" {
+		upstreamList = net.JoinHostPort(cfg.Proxy.Host, cfg.Proxy.Port)
+	}
+
+	upstreams, err := pservice.ParseUpstreams(upstreamList)
+	if err != nil {
+		return nil, err
+	}
+
+	balancer, err := pservice.NewBalancer(cfg.Proxy.Strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	proxyOptions := []pservice.ProxyReverseOption{
+		pservice.WithBalancer(balancer),
+		pservice.WithHealthCheck(pservice.HealthCheck{Path: cfg.Proxy.HealthPath, Interval: cfg.Proxy.HealthInterval}),
+		pservice.WithRoutesFile(cfg.Proxy.Routes),
+		pservice.WithStreamIdleTimeout(cfg.Proxy.StreamIdleTimeout),
+		pservice.WithMetrics(reg),
+		pservice.WithTracerProvider(tp),
+		pservice.WithLogger(logger),
+	}
+
+	cache, err := newCache(cfg, reg)
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		proxyOptions = append(proxyOptions, pservice.WithCache(cache))
+	}
+
+	proxy := pservice.NewProxyReverse(upstreams, proxyOptions...)
+
+	if cfg.Proxy.Routes != "" {
+		if err := proxy.Reload(); err != nil {
+			proxy.Close()
+			return nil, err
+		}
+	}
+
+	db, err := NewDatabaseRepo(cfg)
+	if err != nil {
+		proxy.Close()
+		return nil, err
+	}
+	if cfg.Auth.UsersFile == "" {
+		logger.Warn("users are kept in memory and lost on restart, set AUTH_USERS_FILE to keep them and manage them with geoadmin")
+	}
+
+	authOptions := []aservice.UserAuthOption{
+		aservice.WithAdmins(cfg.Auth.AdminEmails...),
+		aservice.WithMetrics(reg),
+	}
+	if cfg.Auth.SessionCookies {
+		authOptions = append(authOptions, aservice.WithSessionCookies())
+		// Secure cookies would never come back to a server without HTTPS
+		if cfg.Server.TLS.CertFile == "" {
+			authOptions = append(authOptions, aservice.WithInsecureCookies())
+		}
+	}
+	if len(cfg.Server.TLS.ClientIdentities) > 0 {
+		authOptions = append(authOptions, aservice.WithClientIdentities(cfg.Server.TLS.ClientIdentities))
+	}
+
+	geo, err := newGeo(cfg, reg, tp, logger)
+	if err != nil {
+		proxy.Close()
+		return nil, err
+	}
+
+	health := hservice.NewHealthService(
+		hservice.WithTimeout(cfg.Health.Timeout),
+		hservice.WithCheck("users", true, 0, hservice.RepositoryProbe(db)),
+		hservice.WithCheck("geo", false, cfg.Health.GeoTTL, hservice.GeoProbe(geo)),
+		hservice.WithCheck("upstreams", true, 0, hservice.UpstreamsProbe(proxy)),
+	)
+
 	return &Services{
-		Proxy: pservice.NewProxyReverse(config.ProxyHost, config.ProxyPort),
-		Geo:   gservice.NewGeoService(config.ApiKey, config.SecretKey),
-		Auth:  aservice.NewUserAuth(config.JwtAlg, config.JwtSecret, db),
+		Proxy:   proxy,
+		Geo:     geo,
+		Auth:    aservice.NewUserAuth(cfg.Auth.JwtAlg, cfg.Auth.JwtSecret, db, authOptions...),
+		Health:  health,
+		Metrics: reg,
+		Tracing: tp,
+	}, nil
+}
+
+// NewDatabaseRepo opens the users file, or keeps the users in memory when
+// none is configured.
+func NewDatabaseRepo(cfg *config.Config) (repository.DatabaseRepo, error) {
+	if cfg.Auth.UsersFile == "" {
+		return dbrepo.NewMapDBRepo(), nil
 	}
+	return dbrepo.NewFileDBRepo(cfg.Auth.UsersFile)
+}
+
+// newGeo wraps the DaData provider with a circuit breaker, retries and fallbacks.
+func newGeo(cfg *config.Config, reg prometheus.Registerer, tp trace.TracerProvider, logger *slog.Logger) (*gservice.ResilientGeo, error) {
+	options := []gservice.ResilientGeoOption{
+		gservice.WithBreaker(breaker.NewBreaker(
+			breaker.WithFailureThreshold(cfg.Geo.BreakerThreshold),
+			breaker.WithOpenTimeout(cfg.Geo.BreakerTimeout),
+		)),
+		gservice.WithRetries(cfg.Geo.Retries, 100*time.Millisecond),
+		gservice.WithAttemptTimeout(cfg.Geo.Timeout),
+		gservice.WithMetrics(reg),
+		gservice.WithTracerProvider(tp),
+		gservice.WithLogger(logger),
+	}
+
+	if cfg.Geo.OfflineFile != "" {
+		data, err := os.ReadFile(cfg.Geo.OfflineFile)
+		if err != nil {
+			return nil, err
+		}
+
+		var addresses []*gentities.Address
+		if err := json.Unmarshal(data, &addresses); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.Geo.OfflineFile, err)
+		}
+		options = append(options, gservice.WithOfflineAddresses(addresses))
+	}
+
+	return gservice.NewResilientGeo(gservice.NewGeoService(cfg.Geo.ApiKey, cfg.Geo.SecretKey,
+		gservice.WithHTTPClient(&http.Client{Transport: tracing.NewTransport(tp, logging.NewTransport(http.DefaultTransport))}),
+	), options...), nil
+}
+
+// newCache builds the proxy response cache, or returns nil if caching is disabled.
+func newCache(cfg *config.Config, reg prometheus.Registerer) (*httpcache.Cache, error) {
+	var storage httpcache.Storage
+
+	switch cfg.Cache.Storage {
+	case "":
+		return nil, nil
+	case "memory":
+		storage = httpcache.NewMemoryStorage(cfg.Cache.MaxEntries)
+	case "disk":
+		disk, err := httpcache.NewDiskStorage(cfg.Cache.Dir)
+		if err != nil {
+			return nil, err
+		}
+		storage = disk
+	default:
+		return nil, fmt.Errorf("unknown cache storage %q", cfg.Cache.Storage)
+	}
+
+	return httpcache.NewCache(storage,
+		httpcache.WithDefaultTTL(cfg.Cache.DefaultTTL),
+		httpcache.WithMetrics(reg),
+	), nil
 }
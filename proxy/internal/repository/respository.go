@@ -1,10 +0,0 @@
-package repository
-
-import (
-	"proxy/internal/entities"
-)
-
-type DatabaseRepo interface {
-	GetUserByEmail(string) (entities.User, error)
-	InsertUser(entities.User) error
-}
@@ -0,0 +1,143 @@
+package apiversion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"proxy/internal/utils/readresponder"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HeaderVersion selects the version of unversioned /api routes and is
+// echoed on every versioned response.
+const HeaderVersion = "API-Version"
+
+var ErrorUnsupportedVersion = errors.New("unsupported API version")
+
+var ProblemUnsupportedVersion = readresponder.ProblemType{
+	Type:   "/problems/unsupported-version",
+	Title:  "Unsupported API version",
+	Status: http.StatusBadRequest,
+}
+
+// problems writes the negotiation errors, which happen before any route
+// could opt in to problem details.
+var problems = readresponder.NewReadRespond(readresponder.WithProblem(ErrorUnsupportedVersion, ProblemUnsupportedVersion))
+
+type versionKey struct{}
+
+// FromContext returns the version the request is served with. Requests
+// that did not go through Version, such as in controller tests, get 1.
+func FromContext(ctx context.Context) int {
+	if version, ok := ctx.Value(versionKey{}).(int); ok {
+		return version
+	}
+	return 1
+}
+
+// Deprecation announces that a version is going away, with the
+// Deprecation (RFC 9745), Sunset (RFC 8594) and successor-version Link
+// headers.
+type Deprecation struct {
+	Since  time.Time
+	Sunset time.Time
+	// Successor is the path prefix of the version that replaces it, e.g.
+	// /api/v2. The rest of the request path is appended to it.
+	Successor string
+}
+
+type VersionOption func(*versioned)
+
+type versioned struct {
+	version     int
+	prefixes    []string
+	deprecation *Deprecation
+}
+
+// WithDeprecation marks the version deprecated. prefixes are the path
+// prefixes the version is mounted at; the longest one matching the request
+// is replaced by the successor's in the Link header.
+func WithDeprecation(deprecation Deprecation, prefixes ...string) VersionOption {
+	return func(v *versioned) {
+		v.prefixes = append(v.prefixes, prefixes...)
+		sort.Slice(v.prefixes, func(i, j int) bool { return len(v.prefixes[i]) > len(v.prefixes[j]) })
+		v.deprecation = &deprecation
+	}
+}
+
+// Version serves the routes it wraps as the given version and announces it
+// in the response headers.
+func Version(version int, options ...VersionOption) func(http.Handler) http.Handler {
+	v := &versioned{version: version}
+	for _, option := range options {
+		option(v)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(HeaderVersion, strconv.Itoa(v.version))
+			if v.deprecation != nil {
+				v.deprecation.announce(w.Header(), v.prefixes, r.URL.Path)
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), versionKey{}, v.version)))
+		})
+	}
+}
+
+func (d *Deprecation) announce(header http.Header, prefixes []string, path string) {
+	if d.Since.IsZero() {
+		header.Set("Deprecation", "?1")
+	} else {
+		header.Set("Deprecation", fmt.Sprintf("@%d", d.Since.Unix()))
+	}
+	if !d.Sunset.IsZero() {
+		header.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if d.Successor != "" {
+		successor := d.Successor
+		for _, prefix := range prefixes {
+			if rest, ok := strings.CutPrefix(path, prefix); ok {
+				successor += rest
+				break
+			}
+		}
+		header.Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+	}
+}
+
+// Negotiate serves unversioned routes with the version in the API-Version
+// header, "2" or "v2", or with defaultVersion when it is missing. Unknown
+// versions are rejected with 400 and the supported versions in the
+// API-Version header.
+func Negotiate(defaultVersion int, versions map[int]http.Handler) http.Handler {
+	list := make([]string, 0, len(versions))
+	for version := range versions {
+		list = append(list, strconv.Itoa(version))
+	}
+	sort.Strings(list)
+	// rejected requests learn the versions they can ask for
+	supported := strings.Join(list, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", HeaderVersion)
+
+		version := defaultVersion
+		if header := r.Header.Get(HeaderVersion); header != "" {
+			requested, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(header)), "v"))
+			if _, ok := versions[requested]; err != nil || !ok {
+				w.Header().Set(HeaderVersion, supported)
+				readresponder.ProblemDetails(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					problems.WriteError(w, r, ErrorUnsupportedVersion)
+				})).ServeHTTP(w, r)
+				return
+			}
+			version = requested
+		}
+
+		versions[version].ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,68 @@
+package apiversion
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	since := time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, time.April, 1, 0, 0, 0, 0, time.UTC)
+
+	handler := func(version int, options ...VersionOption) http.Handler {
+		return Version(version, options...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+	v1 := handler(1, WithDeprecation(Deprecation{Since: since, Sunset: sunset, Successor: "/api/v2"}, "/api", "/api/v1"))
+	v2 := handler(2)
+	negotiated := Negotiate(1, map[int]http.Handler{1: v1, 2: v2})
+
+	testCases := []struct {
+		name        string
+		handler     http.Handler
+		path        string
+		header      string
+		wantStatus  int
+		wantVersion string
+		wantLink    string
+	}{
+		{"default version", negotiated, "/api/address/search", "", 200, "1", "</api/v2/address/search>; rel=\"successor-version\""},
+		{"header", negotiated, "/api/address/search", "2", 200, "2", ""},
+		{"prefixed header", negotiated, "/api/address/search", "v2", 200, "2", ""},
+		{"unknown version", negotiated, "/api/address/search", "3", 400, "1, 2", ""},
+		{"malformed version", negotiated, "/api/address/search", "latest", 400, "1, 2", ""},
+		{"versioned path", v1, "/api/v1/address/search", "", 200, "1", "</api/v2/address/search>; rel=\"successor-version\""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", tc.path, nil)
+			if tc.header != "" {
+				r.Header.Set(HeaderVersion, tc.header)
+			}
+			wr := httptest.NewRecorder()
+			tc.handler.ServeHTTP(wr, r)
+
+			if wr.Code != tc.wantStatus {
+				t.Errorf("got status code %d, want %d", wr.Code, tc.wantStatus)
+			}
+			if got := wr.Header().Get(HeaderVersion); got != tc.wantVersion {
+				t.Errorf("got version %q, want %q", got, tc.wantVersion)
+			}
+			if got := wr.Header().Get("Link"); got != tc.wantLink {
+				t.Errorf("got link %q, want %q", got, tc.wantLink)
+			}
+
+			deprecated := tc.wantVersion == "1"
+			if got := wr.Header().Get("Deprecation"); deprecated && got != "@1792368000" || !deprecated && got != "" {
+				t.Errorf("got deprecation %q, deprecated %v", got, deprecated)
+			}
+			if got := wr.Header().Get("Sunset"); deprecated && got != "Thu, 01 Apr 2027 00:00:00 GMT" || !deprecated && got != "" {
+				t.Errorf("got sunset %q, deprecated %v", got, deprecated)
+			}
+		})
+	}
+}
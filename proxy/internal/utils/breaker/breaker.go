@@ -0,0 +1,163 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+type State string
+
+const (
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "half-open"
+)
+
+var ErrorOpen = errors.New("circuit breaker is open")
+
+// Breaker is a circuit breaker. It opens after FailureThreshold consecutive
+// failures, rejects calls for OpenTimeout, then lets up to HalfOpenCalls
+// trial calls through and closes again once they all succeed.
+type Breaker struct {
+	failureThreshold int
+	openTimeout      time.Duration
+	halfOpenCalls    int
+
+	m         sync.Mutex
+	state     State
+	failures  int
+	successes int
+	inFlight  int
+	openedAt  time.Time
+	now       func() time.Time
+}
+
+type BreakerOption func(*Breaker)
+
+func WithFailureThreshold(threshold int) BreakerOption {
+	return func(b *Breaker) {
+		if threshold > 0 {
+			b.failureThreshold = threshold
+		}
+	}
+}
+
+func WithOpenTimeout(timeout time.Duration) BreakerOption {
+	return func(b *Breaker) {
+		if timeout > 0 {
+			b.openTimeout = timeout
+		}
+	}
+}
+
+func WithHalfOpenCalls(calls int) BreakerOption {
+	return func(b *Breaker) {
+		if calls > 0 {
+			b.halfOpenCalls = calls
+		}
+	}
+}
+
+func NewBreaker(options ...BreakerOption) *Breaker {
+	b := &Breaker{
+		failureThreshold: 5,
+		openTimeout:      30 * time.Second,
+		halfOpenCalls:    1,
+		state:            StateClosed,
+		now:              time.Now,
+	}
+
+	for _, option := range options {
+		option(b)
+	}
+	return b
+}
+
+// Status is a snapshot of the breaker for health reporting.
+type Status struct {
+	State    State     `json:"state"`
+	Failures int       `json:"failures"`
+	OpenedAt time.Time `json:"opened_at,omitempty"`
+}
+
+func (b *Breaker) Status() Status {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	b.advance()
+	status := Status{State: b.state, Failures: b.failures}
+	if b.state != StateClosed {
+		status.OpenedAt = b.openedAt
+	}
+	return status
+}
+
+// Execute runs fn unless the breaker is open. Errors for which isFailure
+// returns false, e.g. client errors, are passed through without tripping it.
+func (b *Breaker) Execute(fn func() error, isFailure func(error) bool) error {
+	if err := b.acquire(); err != nil {
+		return err
+	}
+
+	err := fn()
+	b.release(err != nil && isFailure(err))
+
+	return err
+}
+
+func (b *Breaker) acquire() error {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	b.advance()
+
+	switch b.state {
+	case StateOpen:
+		return ErrorOpen
+	case StateHalfOpen:
+		if b.inFlight >= b.halfOpenCalls {
+			return ErrorOpen
+		}
+	}
+
+	b.inFlight++
+	return nil
+}
+
+func (b *Breaker) release(failed bool) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	b.inFlight--
+
+	if failed {
+		b.failures++
+		if b.state == StateHalfOpen || b.failures >= b.failureThreshold {
+			b.state = StateOpen
+			b.openedAt = b.now()
+			b.successes = 0
+		}
+		return
+	}
+
+	switch b.state {
+	case StateHalfOpen:
+		b.successes++
+		if b.successes >= b.halfOpenCalls {
+			b.state = StateClosed
+			b.failures = 0
+			b.successes = 0
+		}
+	case StateClosed:
+		b.failures = 0
+	}
+}
+
+// advance moves an open breaker to half-open once the timeout has passed.
+func (b *Breaker) advance() {
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.openTimeout {
+		b.state = StateHalfOpen
+		b.successes = 0
+	}
+}
@@ -0,0 +1,62 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var errorUpstream = errors.New("upstream failed")
+
+func TestBreaker_Execute(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewBreaker(WithFailureThreshold(2), WithOpenTimeout(time.Minute))
+	b.now = func() time.Time { return now }
+
+	isFailure := func(err error) bool { return true }
+	fail := func() error { return errorUpstream }
+	succeed := func() error { return nil }
+
+	testCases := []struct {
+		name      string
+		advance   time.Duration
+		fn        func() error
+		wantErr   error
+		wantState State
+	}{
+		{"first failure", 0, fail, errorUpstream, StateClosed},
+		{"threshold reached", 0, fail, errorUpstream, StateOpen},
+		{"rejected while open", 0, succeed, ErrorOpen, StateOpen},
+		{"trial call fails", time.Minute, fail, errorUpstream, StateOpen},
+		{"still open", 30 * time.Second, succeed, ErrorOpen, StateOpen},
+		{"trial call succeeds", 30 * time.Second, succeed, nil, StateClosed},
+		{"closed again", 0, fail, errorUpstream, StateClosed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			now = now.Add(tc.advance)
+
+			err := b.Execute(tc.fn, isFailure)
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("Execute() error = %v, wantErr %v", err, tc.wantErr)
+			}
+
+			if state := b.Status().State; state != tc.wantState {
+				t.Errorf("got state %s, want %s", state, tc.wantState)
+			}
+		})
+	}
+}
+
+func TestBreaker_IgnoredErrors(t *testing.T) {
+	b := NewBreaker(WithFailureThreshold(1))
+
+	for i := 0; i < 3; i++ {
+		b.Execute(func() error { return errorUpstream }, func(error) bool { return false })
+	}
+
+	if state := b.Status().State; state != StateClosed {
+		t.Errorf("got state %s, want %s", state, StateClosed)
+	}
+}
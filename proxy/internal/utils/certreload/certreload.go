@@ -0,0 +1,128 @@
+package certreload
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+var ErrorNoClientCAs = errors.New("no certificates found in client CA file")
+
+// Reloader serves a TLS certificate, and optionally a client CA pool, read
+// from files and swapped in place whenever the files change on disk.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	cert      atomic.Pointer[tls.Certificate]
+	clientCAs atomic.Pointer[x509.CertPool]
+	modTime   time.Time
+}
+
+// NewReloader loads the certificate and key, and the client CA bundle if
+// caFile is not empty, failing fast on invalid files.
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+	rl := &Reloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+
+	if err := rl.load(); err != nil {
+		return nil, err
+	}
+	rl.modTime = rl.latestModTime()
+
+	return rl, nil
+}
+
+func (rl *Reloader) load() error {
+	cert, err := tls.LoadX509KeyPair(rl.certFile, rl.keyFile)
+	if err != nil {
+		return fmt.Errorf("loading certificate: %w", err)
+	}
+
+	if rl.caFile != "" {
+		pem, err := os.ReadFile(rl.caFile)
+		if err != nil {
+			return fmt.Errorf("loading client CAs: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return ErrorNoClientCAs
+		}
+		rl.clientCAs.Store(pool)
+	}
+
+	rl.cert.Store(&cert)
+	return nil
+}
+
+func (rl *Reloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, file := range []string{rl.certFile, rl.keyFile, rl.caFile} {
+		if file == "" {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// Watch polls the files every interval and reloads them on change until the
+// context is cancelled. A failed reload keeps serving the previous
+// certificate, so a half-written renewal does not take the server down.
+func (rl *Reloader) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			modTime := rl.latestModTime()
+			if !modTime.After(rl.modTime) {
+				continue
+			}
+
+			if err := rl.load(); err != nil {
+				if onError != nil {
+					onError(err)
+				}
+				continue
+			}
+			rl.modTime = modTime
+		}
+	}
+}
+
+// TLSConfig returns a config with HTTP/2 enabled that always presents the
+// current certificate. With a client CA file, client certificates are
+// requested and verified when presented, but not required.
+func (rl *Reloader) TLSConfig() *tls.Config {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return rl.cert.Load(), nil
+		},
+	}
+
+	if rl.caFile != "" {
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			current := config.Clone()
+			current.GetConfigForClient = nil
+			current.ClientCAs = rl.clientCAs.Load()
+			return current, nil
+		}
+	}
+
+	return config
+}
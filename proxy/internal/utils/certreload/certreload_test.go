@@ -0,0 +1,151 @@
+package certreload
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeCert(t *testing.T, dir, name string, modTime time.Time) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	os.Chtimes(certFile, modTime, modTime)
+	os.Chtimes(keyFile, modTime, modTime)
+
+	return certFile, keyFile
+}
+
+func servedName(t *testing.T, rl *Reloader) string {
+	t.Helper()
+
+	cert, err := rl.TLSConfig().GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestReloader_Watch(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Minute)
+	certFile, keyFile := writeCert(t, dir, "old.example.com", start)
+
+	rl, err := NewReloader(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := servedName(t, rl); got != "old.example.com" {
+		t.Errorf("got certificate %q, want %q", got, "old.example.com")
+	}
+
+	errs := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		rl.Watch(ctx, 10*time.Millisecond, func(err error) { errs <- err })
+		close(done)
+	}()
+
+	// a broken renewal keeps the previous certificate in place
+	os.WriteFile(keyFile, []byte("garbage"), 0o600)
+	os.Chtimes(keyFile, start.Add(time.Second), start.Add(time.Second))
+	select {
+	case <-errs:
+	case <-time.After(time.Second):
+		t.Fatal("broken key was not reported")
+	}
+	if got := servedName(t, rl); got != "old.example.com" {
+		t.Errorf("got certificate %q after failed reload, want %q", got, "old.example.com")
+	}
+
+	writeCert(t, dir, "new.example.com", start.Add(2*time.Second))
+	deadline := time.Now().Add(time.Second)
+	for servedName(t, rl) != "new.example.com" {
+		if time.Now().After(deadline) {
+			t.Fatal("renewed certificate was not picked up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestNewReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "example.com", time.Now())
+	badCA := filepath.Join(dir, "ca.pem")
+	os.WriteFile(badCA, []byte("not a certificate"), 0o600)
+
+	testCases := []struct {
+		name       string
+		certFile   string
+		keyFile    string
+		caFile     string
+		wantErr    bool
+		wantClient tls.ClientAuthType
+	}{
+		{"server certificate", certFile, keyFile, "", false, tls.NoClientCert},
+		{"client CAs", certFile, keyFile, certFile, false, tls.VerifyClientCertIfGiven},
+		{"missing key", certFile, filepath.Join(dir, "missing.pem"), "", true, 0},
+		{"invalid client CAs", certFile, keyFile, badCA, true, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rl, err := NewReloader(tc.certFile, tc.keyFile, tc.caFile)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			config := rl.TLSConfig()
+			if config.ClientAuth != tc.wantClient {
+				t.Errorf("got client auth %v, want %v", config.ClientAuth, tc.wantClient)
+			}
+			if config.NextProtos[0] != "h2" {
+				t.Errorf("got protocols %v, want h2 first", config.NextProtos)
+			}
+		})
+	}
+}
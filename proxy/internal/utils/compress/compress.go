@@ -0,0 +1,318 @@
+package compress
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	EncodingBrotli  = "br"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// preference breaks ties between encodings the client weighs equally.
+var preference = []string{EncodingBrotli, EncodingGzip, EncodingDeflate}
+
+var defaultContentTypes = []string{
+	"text/",
+	"application/json",
+	"application/problem+json",
+	"application/javascript",
+	"application/xml",
+	"application/x-ndjson",
+	"image/svg+xml",
+}
+
+// Compressor compresses responses with the best encoding accepted by the
+// client, skipping small bodies, binary content types and responses that are
+// already encoded, e.g. by a proxied upstream.
+type Compressor struct {
+	minSize      int
+	contentTypes []string
+	pools        map[string]*sync.Pool
+}
+
+type CompressorOption func(*Compressor)
+
+func WithMinSize(minSize int) CompressorOption {
+	return func(c *Compressor) {
+		if minSize > 0 {
+			c.minSize = minSize
+		}
+	}
+}
+
+// WithContentTypes replaces the allowlist; entries ending in "/" match a
+// whole type, e.g. "text/".
+func WithContentTypes(contentTypes ...string) CompressorOption {
+	return func(c *Compressor) {
+		c.contentTypes = contentTypes
+	}
+}
+
+func NewCompressor(options ...CompressorOption) *Compressor {
+	c := &Compressor{
+		minSize:      1024,
+		contentTypes: defaultContentTypes,
+		pools: map[string]*sync.Pool{
+			EncodingBrotli: {New: func() any {
+				return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+			}},
+			EncodingGzip: {New: func() any {
+				return gzip.NewWriter(nil)
+			}},
+			EncodingDeflate: {New: func() any {
+				w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+				return w
+			}},
+		},
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+func (c *Compressor) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := Negotiate(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" || r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, compressor: c, encoding: encoding}
+		defer cw.Close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// Negotiate picks the preferred supported encoding from an Accept-Encoding
+// header, or "" if the response should be sent as is.
+func Negotiate(header string) string {
+	weights := make(map[string]float64)
+	wildcard := -1.0
+
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		if name == "*" {
+			wildcard = q
+			continue
+		}
+		weights[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range preference {
+		q, ok := weights[encoding]
+		if !ok && wildcard >= 0 {
+			q, ok = wildcard, true
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+
+	return best
+}
+
+func (c *Compressor) allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "text/event-stream" {
+		return false
+	}
+
+	for _, allowed := range c.contentTypes {
+		if mediaType == allowed || (strings.HasSuffix(allowed, "/") && strings.HasPrefix(mediaType, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter buffers the first minSize bytes of the body to decide
+// whether compressing is worth it, then either streams through an encoder
+// or passes the body through untouched.
+type compressWriter struct {
+	http.ResponseWriter
+	compressor *Compressor
+	encoding   string
+
+	status  int
+	buf     bytes.Buffer
+	decided bool
+	encoder encoder
+	closed  bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	// informational responses precede the final one
+	if status < http.StatusOK {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	if cw.status != 0 {
+		return
+	}
+	cw.status = status
+
+	// bodiless responses go out right away
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		cw.decided = true
+		cw.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if !cw.decided {
+		if !cw.eligible() {
+			cw.decide(false)
+		} else if cw.buf.Len()+len(b) < cw.compressor.minSize {
+			return cw.buf.Write(b)
+		} else {
+			cw.decide(true)
+		}
+	}
+
+	if cw.encoder != nil {
+		return cw.encoder.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// eligible checks the response headers, which are final once the body starts.
+func (cw *compressWriter) eligible() bool {
+	header := cw.Header()
+
+	if header.Get("Content-Encoding") != "" || strings.Contains(header.Get("Cache-Control"), "no-transform") {
+		return false
+	}
+	if !cw.compressor.allowed(header.Get("Content-Type")) {
+		return false
+	}
+
+	if length, err := strconv.Atoi(header.Get("Content-Length")); err == nil && length < cw.compressor.minSize {
+		return false
+	}
+	return true
+}
+
+func (cw *compressWriter) decide(compress bool) {
+	cw.decided = true
+	header := cw.Header()
+
+	if compress {
+		header.Add("Vary", "Accept-Encoding")
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		// a strong ETag names the exact bytes of the upstream representation
+		if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			header.Set("ETag", "W/"+etag)
+		}
+
+		cw.encoder = cw.compressor.pools[cw.encoding].Get().(encoder)
+		cw.encoder.Reset(cw.ResponseWriter)
+	} else if cw.eligible() {
+		// small bodies of a compressible type still vary on Accept-Encoding
+		header.Add("Vary", "Accept-Encoding")
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if cw.buf.Len() > 0 {
+		if cw.encoder != nil {
+			cw.encoder.Write(cw.buf.Bytes())
+		} else {
+			cw.ResponseWriter.Write(cw.buf.Bytes())
+		}
+		cw.buf.Reset()
+	}
+}
+
+// Flush commits to compressing whatever has been buffered so far, since a
+// flushing handler is streaming and the final size is unknown.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.WriteHeader(http.StatusOK)
+		}
+		cw.decide(cw.eligible())
+	}
+
+	if cw.encoder != nil {
+		cw.encoder.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *compressWriter) Close() error {
+	if cw.closed {
+		return nil
+	}
+	cw.closed = true
+
+	if !cw.decided {
+		if cw.status == 0 {
+			// the handler wrote nothing at all
+			return nil
+		}
+		cw.decide(false)
+	}
+
+	if cw.encoder == nil {
+		return nil
+	}
+
+	err := cw.encoder.Close()
+	cw.encoder.Reset(nil)
+	cw.compressor.pools[cw.encoding].Put(cw.encoder)
+	cw.encoder = nil
+
+	return err
+}
+
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	cw.decided = true
+	cw.closed = true
+	return http.NewResponseController(cw.ResponseWriter).Hijack()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,151 @@
+package compress
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	testCases := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", EncodingGzip},
+		{"gzip, deflate, br", EncodingBrotli},
+		{"deflate;q=0.5, gzip;q=0.8", EncodingGzip},
+		{"br;q=0, gzip;q=0", ""},
+		{"*", EncodingBrotli},
+		{"identity, *;q=0", ""},
+		{"compress", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.header, func(t *testing.T) {
+			if got := Negotiate(tc.header); got != tc.want {
+				t.Errorf("Negotiate(%q) = %q, want %q", tc.header, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompressor_ETag(t *testing.T) {
+	large := strings.Repeat("геосервис ", 200)
+
+	testCases := []struct {
+		name           string
+		acceptEncoding string
+		etag           string
+		wantETag       string
+	}{
+		{"compressed strong etag", "gzip", `"v1"`, `W/"v1"`},
+		{"compressed weak etag", "br", `W/"v1"`, `W/"v1"`},
+		{"identity keeps strong etag", "", `"v1"`, `"v1"`},
+	}
+
+	compressor := NewCompressor(WithMinSize(256))
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := compressor.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				w.Header().Set("ETag", tc.etag)
+				w.Write([]byte(large))
+			}))
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			wr := httptest.NewRecorder()
+			handler.ServeHTTP(wr, req)
+
+			if got := wr.Header().Get("ETag"); got != tc.wantETag {
+				t.Errorf("got ETag %s, want %s", got, tc.wantETag)
+			}
+		})
+	}
+}
+
+func TestCompressor_Middleware(t *testing.T) {
+	large := strings.Repeat("геосервис ", 200)
+
+	testCases := []struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		upstreamEnc    string
+		body           string
+		wantEncoding   string
+	}{
+		{"gzip json", "gzip", "application/json", "", large, EncodingGzip},
+		{"brotli html", "br, gzip", "text/html; charset=utf-8", "", large, EncodingBrotli},
+		{"deflate", "deflate", "text/plain", "", large, EncodingDeflate},
+		{"below threshold", "gzip", "application/json", "", `{"error":false}`, ""},
+		{"binary content type", "gzip", "image/png", "", large, ""},
+		{"event stream", "gzip", "text/event-stream", "", large, ""},
+		{"no accept-encoding", "", "application/json", "", large, ""},
+		{"already compressed upstream", "gzip", "text/html", EncodingGzip, large, EncodingGzip},
+	}
+
+	compressor := NewCompressor(WithMinSize(256))
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := compressor.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				if tc.upstreamEnc != "" {
+					w.Header().Set("Content-Encoding", tc.upstreamEnc)
+				}
+				w.Write([]byte(tc.body[:len(tc.body)/2]))
+				w.Write([]byte(tc.body[len(tc.body)/2:]))
+			}))
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			wr := httptest.NewRecorder()
+
+			handler.ServeHTTP(wr, req)
+
+			if got := wr.Header().Get("Content-Encoding"); got != tc.wantEncoding {
+				t.Fatalf("got Content-Encoding %q, want %q", got, tc.wantEncoding)
+			}
+
+			// responses encoded upstream are passed through byte for byte
+			body := wr.Body.Bytes()
+			if tc.upstreamEnc == "" {
+				body = decode(t, tc.wantEncoding, body)
+			}
+			if string(body) != tc.body {
+				t.Errorf("got body of %d bytes, want %d", len(body), len(tc.body))
+			}
+		})
+	}
+}
+
+func decode(t *testing.T, encoding string, body []byte) []byte {
+	var r io.Reader = bytes.NewReader(body)
+
+	switch encoding {
+	case EncodingGzip:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatalf("gzip.NewReader() error = %v", err)
+		}
+		r = gr
+	case EncodingDeflate:
+		r = flate.NewReader(r)
+	case EncodingBrotli:
+		r = brotli.NewReader(r)
+	}
+
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decoding %s error = %v", encoding, err)
+	}
+	return decoded
+}
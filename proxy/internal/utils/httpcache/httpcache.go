@@ -0,0 +1,389 @@
+package httpcache
+
+import (
+	"bytes"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderCache = "X-Cache"
+
+	StatusHit         = "HIT"
+	StatusMiss        = "MISS"
+	StatusRevalidated = "REVALIDATED"
+	StatusBypass      = "BYPASS"
+)
+
+// traceHeaders describe the trace of one request, even when an upstream
+// sends them.
+var traceHeaders = []string{"Traceparent", "Tracestate", "Traceresponse"}
+
+// Cache is a shared HTTP cache for upstream responses honoring Cache-Control,
+// Expires, ETag and Last-Modified.
+type Cache struct {
+	storage     Storage
+	defaultTTL  time.Duration
+	maxBodySize int
+	now         func() time.Time
+	results     *prometheus.CounterVec
+}
+
+type CacheOption func(*Cache)
+
+// WithDefaultTTL sets the freshness lifetime for responses carrying neither
+// explicit freshness information nor validators. Zero disables storing them.
+func WithDefaultTTL(ttl time.Duration) CacheOption {
+	return func(c *Cache) {
+		c.defaultTTL = ttl
+	}
+}
+
+func WithMaxBodySize(maxBodySize int) CacheOption {
+	return func(c *Cache) {
+		c.maxBodySize = maxBodySize
+	}
+}
+
+func NewCache(storage Storage, options ...CacheOption) *Cache {
+	c := &Cache{
+		storage:     storage,
+		maxBodySize: 10 << 20,
+		now:         time.Now,
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Purge removes every entry whose path starts with prefix and reports how
+// many were removed. An empty prefix purges the whole cache.
+func (c *Cache) Purge(prefix string) int {
+	purged := 0
+	for _, key := range c.storage.Keys() {
+		if strings.HasPrefix(keyPath(key), prefix) {
+			c.storage.Delete(key)
+			purged++
+		}
+	}
+	return purged
+}
+
+func (c *Cache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !cacheableRequest(r) {
+			w.Header().Set(HeaderCache, StatusBypass)
+			c.observe(StatusBypass)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.Host + r.URL.RequestURI()
+		reqDirectives := parseCacheControl(r.Header.Get("Cache-Control"))
+
+		entry, ok := c.storage.Get(key)
+		if ok && !entry.matchesVary(r) {
+			entry, ok = nil, false
+		}
+
+		maxAge, limited := reqDirectives.seconds("max-age")
+		fresh := ok && c.now().Before(entry.Expires) && !reqDirectives.has("no-cache") &&
+			(!limited || c.now().Sub(entry.Stored) <= maxAge)
+		if fresh {
+			c.observe(StatusHit)
+			c.serve(w, r, entry, StatusHit)
+			return
+		}
+
+		// revalidate the stale entry unless the client brought its own validators
+		revalidating := ok && entry.hasValidators() &&
+			r.Header.Get("If-None-Match") == "" && r.Header.Get("If-Modified-Since") == ""
+		upstreamReq := r
+		if revalidating {
+			upstreamReq = r.Clone(r.Context())
+			if etag := entry.Header.Get("ETag"); etag != "" {
+				upstreamReq.Header.Set("If-None-Match", etag)
+			}
+			if modified := entry.Header.Get("Last-Modified"); modified != "" {
+				upstreamReq.Header.Set("If-Modified-Since", modified)
+			}
+		}
+
+		capture := &captureWriter{
+			ResponseWriter:  w,
+			maxBodySize:     c.maxBodySize,
+			holdNotModified: revalidating,
+		}
+		// headers set before the upstream answers belong to this request,
+		// e.g. X-Request-ID, and are not replayed to others
+		for name := range w.Header() {
+			capture.perRequest = append(capture.perRequest, name)
+		}
+		next.ServeHTTP(capture, upstreamReq)
+
+		if capture.held {
+			entry = c.refresh(entry, capture.Header())
+			c.storage.Set(key, entry)
+			c.observe(StatusRevalidated)
+			c.serve(w, r, entry, StatusRevalidated)
+			return
+		}
+		c.observe(StatusMiss)
+
+		if r.Method == http.MethodGet && !capture.overflow {
+			c.store(key, r, capture)
+		}
+	})
+}
+
+// refresh returns a copy of the entry updated with the headers of a 304
+// response; the stored entry itself may be concurrently served.
+func (c *Cache) refresh(entry *Entry, header http.Header) *Entry {
+	updated := *entry
+	updated.Header = entry.Header.Clone()
+
+	for _, name := range []string{"Cache-Control", "Expires", "Date", "ETag", "Last-Modified"} {
+		if value := header.Get(name); value != "" {
+			updated.Header.Set(name, value)
+		}
+	}
+
+	updated.Stored = c.now()
+	updated.Expires = updated.Stored.Add(c.freshness(updated.Header))
+	return &updated
+}
+
+func (c *Cache) serve(w http.ResponseWriter, r *http.Request, entry *Entry, status string) {
+	header := w.Header()
+	for key, values := range entry.Header {
+		header[key] = values
+	}
+	header.Set(HeaderCache, status)
+	header.Set("Age", strconv.Itoa(int(c.now().Sub(entry.Stored).Seconds())))
+
+	if etag := entry.Header.Get("ETag"); etag != "" && r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(entry.Status)
+	if r.Method != http.MethodHead {
+		w.Write(entry.Body)
+	}
+}
+
+func (c *Cache) store(key string, r *http.Request, capture *captureWriter) {
+	switch capture.status {
+	case http.StatusOK, http.StatusMovedPermanently, http.StatusNotFound:
+	default:
+		return
+	}
+
+	// the snapshot leaves out what the middlewares around the cache add
+	// once the body is written, e.g. the Content-Encoding of the compressor
+	header := capture.header
+	for _, name := range append(capture.perRequest, traceHeaders...) {
+		header.Del(name)
+	}
+	directives := parseCacheControl(header.Get("Cache-Control"))
+
+	// a shared cache must not store private or per-user responses
+	if directives.has("no-store") || directives.has("private") || header.Get("Set-Cookie") != "" {
+		return
+	}
+
+	vary := make(map[string]string)
+	for _, field := range header.Values("Vary") {
+		for _, name := range strings.Split(field, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return
+			}
+			if name != "" {
+				vary[name] = r.Header.Get(name)
+			}
+		}
+	}
+
+	header.Del(HeaderCache)
+	entry := &Entry{
+		Status: capture.status,
+		Header: header,
+		Body:   capture.body.Bytes(),
+		Vary:   vary,
+		Stored: c.now(),
+	}
+
+	ttl := c.freshness(header)
+	if ttl <= 0 && !entry.hasValidators() {
+		return
+	}
+	entry.Expires = entry.Stored.Add(ttl)
+
+	c.storage.Set(key, entry)
+}
+
+// freshness computes the freshness lifetime of a response.
+func (c *Cache) freshness(header http.Header) time.Duration {
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	if directives.has("no-cache") {
+		return 0
+	}
+	if age, ok := directives.seconds("s-maxage"); ok {
+		return age
+	}
+	if age, ok := directives.seconds("max-age"); ok {
+		return age
+	}
+
+	if expires := header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		return t.Sub(c.now())
+	}
+
+	// heuristic freshness of 10% of the time since last modification
+	if modified, err := http.ParseTime(header.Get("Last-Modified")); err == nil {
+		ttl := c.now().Sub(modified) / 10
+		if ttl > 24*time.Hour {
+			ttl = 24 * time.Hour
+		}
+		return ttl
+	}
+
+	return c.defaultTTL
+}
+
+func (e *Entry) hasValidators() bool {
+	return e.Header.Get("ETag") != "" || e.Header.Get("Last-Modified") != ""
+}
+
+func (e *Entry) matchesVary(r *http.Request) bool {
+	for name, value := range e.Vary {
+		if r.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+func cacheableRequest(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if r.Header.Get("Authorization") != "" || r.Header.Get("Upgrade") != "" {
+		return false
+	}
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		return false
+	}
+	return !parseCacheControl(r.Header.Get("Cache-Control")).has("no-store")
+}
+
+func keyPath(key string) string {
+	if i := strings.Index(key, "/"); i >= 0 {
+		return key[i:]
+	}
+	return key
+}
+
+type cacheControl map[string]string
+
+func parseCacheControl(value string) cacheControl {
+	directives := make(cacheControl)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(part, "=")
+		directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+	}
+	return directives
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+func (cc cacheControl) seconds(name string) (time.Duration, bool) {
+	value, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// captureWriter passes the response through to the client while keeping a
+// copy of the body for storing. When revalidating it holds back a 304 so
+// the stored entry can be served in its place.
+type captureWriter struct {
+	http.ResponseWriter
+	status          int
+	header          http.Header
+	perRequest      []string
+	body            bytes.Buffer
+	maxBodySize     int
+	overflow        bool
+	holdNotModified bool
+	held            bool
+	wroteHeader     bool
+}
+
+func (cw *captureWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = status
+	cw.header = cw.ResponseWriter.Header().Clone()
+
+	if cw.holdNotModified && status == http.StatusNotModified {
+		cw.held = true
+		return
+	}
+	cw.ResponseWriter.Header().Set(HeaderCache, StatusMiss)
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.held {
+		return len(b), nil
+	}
+
+	if !cw.overflow {
+		if cw.body.Len()+len(b) > cw.maxBodySize {
+			cw.overflow = true
+			cw.body.Reset()
+		} else {
+			cw.body.Write(b)
+		}
+	}
+
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *captureWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok && !cw.held {
+		f.Flush()
+	}
+}
+
+func (cw *captureWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
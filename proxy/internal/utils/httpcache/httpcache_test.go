@@ -0,0 +1,188 @@
+package httpcache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type upstream struct {
+	calls  int
+	header http.Header
+	body   string
+}
+
+func (u *upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.calls++
+	for key, values := range u.header {
+		w.Header()[key] = values
+	}
+
+	if etag := u.header.Get("ETag"); etag != "" && r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Write([]byte(u.body))
+}
+
+func TestCache_Middleware(t *testing.T) {
+	testCases := []struct {
+		name      string
+		header    http.Header
+		request   http.Header
+		advance   time.Duration
+		wantCalls int
+		wantCache string
+	}{
+		{"fresh max-age", http.Header{"Cache-Control": {"max-age=60"}}, nil, 30 * time.Second, 1, StatusHit},
+		{"stale max-age", http.Header{"Cache-Control": {"max-age=60"}}, nil, 90 * time.Second, 2, StatusMiss},
+		{"revalidated etag", http.Header{"Cache-Control": {"no-cache"}, "Etag": {`"v1"`}}, nil, 0, 2, StatusRevalidated},
+		{"no-store", http.Header{"Cache-Control": {"no-store"}}, nil, 0, 2, StatusMiss},
+		{"private", http.Header{"Cache-Control": {"private, max-age=60"}}, nil, 0, 2, StatusMiss},
+		{"set-cookie", http.Header{"Cache-Control": {"max-age=60"}, "Set-Cookie": {"a=b"}}, nil, 0, 2, StatusMiss},
+		{"request no-cache", http.Header{"Cache-Control": {"max-age=60"}}, http.Header{"Cache-Control": {"no-cache"}}, 0, 2, StatusMiss},
+		{"authorization", http.Header{"Cache-Control": {"max-age=60"}}, http.Header{"Authorization": {"Bearer token"}}, 0, 2, StatusBypass},
+		{"no freshness info", http.Header{}, nil, 0, 2, StatusMiss},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			cache := NewCache(NewMemoryStorage(10))
+			cache.now = func() time.Time { return now }
+
+			up := &upstream{header: tc.header, body: "page"}
+			handler := cache.Middleware(up)
+
+			var wr *httptest.ResponseRecorder
+			for i := 0; i < 2; i++ {
+				req := httptest.NewRequest("GET", "/tasks/", nil)
+				for key, values := range tc.request {
+					req.Header[key] = values
+				}
+				wr = httptest.NewRecorder()
+				handler.ServeHTTP(wr, req)
+				now = now.Add(tc.advance)
+			}
+
+			body, _ := io.ReadAll(wr.Result().Body)
+			if up.calls != tc.wantCalls {
+				t.Errorf("got %d upstream calls, want %d", up.calls, tc.wantCalls)
+			}
+			if got := wr.Header().Get(HeaderCache); got != tc.wantCache {
+				t.Errorf("got %s %s, want %s", HeaderCache, got, tc.wantCache)
+			}
+			if wr.Code != http.StatusOK || string(body) != "page" {
+				t.Errorf("got %d %q, want 200 %q", wr.Code, body, "page")
+			}
+		})
+	}
+}
+
+func TestCache_PerRequestHeaders(t *testing.T) {
+	up := &upstream{header: http.Header{
+		"Cache-Control": {"max-age=60"},
+		"Traceparent":   {"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+	}, body: "page"}
+	handler := NewCache(NewMemoryStorage(10)).Middleware(up)
+
+	for _, requestID := range []string{"first", "second"} {
+		req := httptest.NewRequest("GET", "/tasks/", nil)
+		wr := httptest.NewRecorder()
+		wr.Header().Set("X-Request-Id", requestID)
+		handler.ServeHTTP(wr, req)
+
+		if ids := wr.Header().Values("X-Request-Id"); len(ids) != 1 || ids[0] != requestID {
+			t.Errorf("got request IDs %v, want [%s]", ids, requestID)
+		}
+	}
+
+	if up.calls != 1 {
+		t.Fatalf("got %d upstream calls, want 1", up.calls)
+	}
+	wr := httptest.NewRecorder()
+	handler.ServeHTTP(wr, httptest.NewRequest("GET", "/tasks/", nil))
+	if got := wr.Header().Get("Traceparent"); got != "" {
+		t.Errorf("got cached Traceparent %q, want none", got)
+	}
+}
+
+func TestCache_Metrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	cache := NewCache(NewMemoryStorage(10), WithMetrics(reg))
+	handler := cache.Middleware(&upstream{header: http.Header{"Cache-Control": {"max-age=60"}}})
+
+	for i := 0; i < 3; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/tasks/", nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/tasks/", nil))
+
+	want := map[string]float64{StatusMiss: 1, StatusHit: 2, StatusBypass: 1, StatusRevalidated: 0}
+	for result, count := range want {
+		if got := testutil.ToFloat64(cache.results.WithLabelValues(result)); got != count {
+			t.Errorf("got %v %s requests, want %v", got, result, count)
+		}
+	}
+}
+
+func TestCache_Purge(t *testing.T) {
+	cache := NewCache(NewMemoryStorage(10))
+	handler := cache.Middleware(&upstream{header: http.Header{"Cache-Control": {"max-age=60"}}})
+
+	for _, path := range []string{"/tasks/", "/tasks/graph/", "/address/search/"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	if purged := cache.Purge("/tasks"); purged != 2 {
+		t.Errorf("Purge(/tasks) = %d, want 2", purged)
+	}
+	if purged := cache.Purge(""); purged != 1 {
+		t.Errorf("Purge() = %d, want 1", purged)
+	}
+}
+
+func TestStorage(t *testing.T) {
+	disk, err := NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDiskStorage() error = %v", err)
+	}
+
+	testCases := []struct {
+		name    string
+		storage Storage
+	}{
+		{"memory", NewMemoryStorage(1)},
+		{"disk", disk},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			entry := &Entry{Status: 200, Header: http.Header{"Etag": {`"v1"`}}, Body: []byte("page")}
+			if err := tc.storage.Set("host/a", entry); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+
+			got, ok := tc.storage.Get("host/a")
+			if !ok || string(got.Body) != "page" || got.Header.Get("ETag") != `"v1"` {
+				t.Errorf("Get() = %+v, %v, want stored entry", got, ok)
+			}
+
+			tc.storage.Delete("host/a")
+			if _, ok := tc.storage.Get("host/a"); ok {
+				t.Error("Get() after Delete() found the entry")
+			}
+		})
+	}
+
+	memory := NewMemoryStorage(1)
+	memory.Set("host/a", &Entry{})
+	memory.Set("host/b", &Entry{})
+	if _, ok := memory.Get("host/a"); ok {
+		t.Error("memory storage did not evict the least recently used entry")
+	}
+}
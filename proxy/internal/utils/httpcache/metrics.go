@@ -0,0 +1,21 @@
+package httpcache
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// WithMetrics registers the count of requests by cache result (the X-Cache
+// values) with reg, from which the hit ratio is derived.
+func WithMetrics(reg prometheus.Registerer) CacheOption {
+	return func(c *Cache) {
+		c.results = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_cache_requests_total",
+			Help: "Requests that went through the proxy cache by result.",
+		}, []string{"result"})
+		reg.MustRegister(c.results)
+	}
+}
+
+func (c *Cache) observe(result string) {
+	if c.results != nil {
+		c.results.WithLabelValues(result).Inc()
+	}
+}
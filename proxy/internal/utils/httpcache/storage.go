@@ -0,0 +1,226 @@
+package httpcache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Entry is a stored upstream response.
+type Entry struct {
+	Status  int
+	Header  http.Header
+	Body    []byte
+	Vary    map[string]string
+	Stored  time.Time
+	Expires time.Time
+}
+
+type Storage interface {
+	Get(key string) (*Entry, bool)
+	Set(key string, entry *Entry) error
+	Delete(key string)
+	Keys() []string
+}
+
+// MemoryStorage keeps entries in memory, evicting the least recently used
+// one once maxEntries is reached.
+type MemoryStorage struct {
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List
+	m          sync.Mutex
+}
+
+type memoryItem struct {
+	key   string
+	entry *Entry
+}
+
+func NewMemoryStorage(maxEntries int) *MemoryStorage {
+	return &MemoryStorage{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+func (s *MemoryStorage) Get(key string) (*Entry, bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	s.lru.MoveToFront(el)
+	return el.Value.(*memoryItem).entry, true
+}
+
+func (s *MemoryStorage) Set(key string, entry *Entry) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		el.Value.(*memoryItem).entry = entry
+		s.lru.MoveToFront(el)
+		return nil
+	}
+
+	s.entries[key] = s.lru.PushFront(&memoryItem{key: key, entry: entry})
+
+	if s.maxEntries > 0 && s.lru.Len() > s.maxEntries {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*memoryItem).key)
+	}
+
+	return nil
+}
+
+func (s *MemoryStorage) Delete(key string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		s.lru.Remove(el)
+		delete(s.entries, key)
+	}
+}
+
+func (s *MemoryStorage) Keys() []string {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	keys := make([]string, 0, len(s.entries))
+	for key := range s.entries {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// DiskStorage keeps one gob-encoded file per entry in dir, so the cache
+// survives restarts. An in-memory index maps keys to files.
+type DiskStorage struct {
+	dir   string
+	index map[string]string
+	m     sync.RWMutex
+}
+
+type diskItem struct {
+	Key   string
+	Entry *Entry
+}
+
+func NewDiskStorage(dir string) (*DiskStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &DiskStorage{dir: dir, index: make(map[string]string)}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.cache"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		item, err := readItem(file)
+		if err != nil {
+			// unreadable leftovers from an older format or a crash mid-write
+			os.Remove(file)
+			continue
+		}
+		s.index[item.Key] = file
+	}
+
+	return s, nil
+}
+
+func (s *DiskStorage) Get(key string) (*Entry, bool) {
+	s.m.RLock()
+	file, ok := s.index[key]
+	s.m.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	item, err := readItem(file)
+	if err != nil {
+		return nil, false
+	}
+	return item.Entry, true
+}
+
+func (s *DiskStorage) Set(key string, entry *Entry) error {
+	sum := sha256.Sum256([]byte(key))
+	file := filepath.Join(s.dir, hex.EncodeToString(sum[:])+".cache")
+
+	tmp, err := os.CreateTemp(s.dir, "*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(diskItem{Key: key, Entry: entry}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	// rename is atomic, so readers never see a partially written entry
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return err
+	}
+
+	s.m.Lock()
+	s.index[key] = file
+	s.m.Unlock()
+
+	return nil
+}
+
+func (s *DiskStorage) Delete(key string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if file, ok := s.index[key]; ok {
+		os.Remove(file)
+		delete(s.index, key)
+	}
+}
+
+func (s *DiskStorage) Keys() []string {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	keys := make([]string, 0, len(s.index))
+	for key := range s.index {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func readItem(file string) (*diskItem, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var item diskItem
+	if err := gob.NewDecoder(f).Decode(&item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
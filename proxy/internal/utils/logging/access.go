@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type entryKey struct{}
+
+// entry collects the attributes handlers add to the access log line of the
+// request they serve.
+type entry struct {
+	attrs []slog.Attr
+}
+
+// AddAttrs adds attributes, such as the authenticated user, to the access
+// log line of the request. It is a no-op outside of AccessLog.
+func AddAttrs(ctx context.Context, attrs ...slog.Attr) {
+	if e, ok := ctx.Value(entryKey{}).(*entry); ok {
+		e.attrs = append(e.attrs, attrs...)
+	}
+}
+
+// AccessLog logs one line per request once it is served, at error level
+// for server errors.
+func AccessLog(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started := time.Now()
+			e := &entry{}
+			ctx := context.WithValue(r.Context(), entryKey{}, e)
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+			}
+			// the pattern is only known once the router has matched the request
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				attrs = append(attrs, slog.String("route", rctx.RoutePattern()))
+			}
+			attrs = append(attrs,
+				slog.Int("status", status),
+				slog.Duration("latency", time.Since(started)),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.String("remote", r.RemoteAddr),
+			)
+			attrs = append(attrs, e.attrs...)
+
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(ctx, level, "request", attrs...)
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	testCases := []struct {
+		name     string
+		incoming string
+		wantKept bool
+	}{
+		{"generated", "", false},
+		{"kept", "req-42.a:b_c", true},
+		{"malformed replaced", "bad id\r\nX-Injected: 1", false},
+		{"too long replaced", strings.Repeat("a", 129), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var upstreamID string
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				upstreamID = r.Header.Get(HeaderRequestID)
+			}))
+			defer upstream.Close()
+
+			client := &http.Client{Transport: NewTransport(http.DefaultTransport)}
+
+			var ctxID string
+			handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctxID = RequestIDFromContext(r.Context())
+				req, _ := http.NewRequestWithContext(r.Context(), "GET", upstream.URL, nil)
+				resp, err := client.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+			}))
+
+			req := httptest.NewRequest("GET", "/", nil)
+			if tc.incoming != "" {
+				req.Header.Set(HeaderRequestID, tc.incoming)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get(HeaderRequestID)
+			if got == "" || got != ctxID || got != upstreamID {
+				t.Fatalf("got response id %q, context id %q, upstream id %q, want the same non-empty id", got, ctxID, upstreamID)
+			}
+			if kept := got == tc.incoming; kept != tc.wantKept {
+				t.Errorf("got id %q, want incoming kept %v", got, tc.wantKept)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := NewLogger(&out, FormatJSON, "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := chi.NewRouter()
+	r.Use(RequestID)
+	r.Use(AccessLog(logger))
+	r.Get("/api/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		AddAttrs(r.Context(), slog.String("user", "admin@example.com"))
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("hello"))
+	})
+
+	req := httptest.NewRequest("GET", "/api/users/42", nil)
+	req.Header.Set(HeaderRequestID, "req-1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	var line map[string]any
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("got %q, want a single JSON line: %v", out.String(), err)
+	}
+
+	want := map[string]any{
+		"level":      "INFO",
+		"msg":        "request",
+		"method":     "GET",
+		"path":       "/api/users/42",
+		"route":      "/api/users/{id}",
+		"status":     float64(http.StatusTeapot),
+		"bytes":      float64(5),
+		"user":       "admin@example.com",
+		"request_id": "req-1",
+	}
+	for key, value := range want {
+		if line[key] != value {
+			t.Errorf("got %s = %v, want %v", key, line[key], value)
+		}
+	}
+	if _, ok := line["latency"]; !ok {
+		t.Errorf("got %v, want latency", line)
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	testCases := []struct {
+		name    string
+		format  string
+		level   string
+		wantErr bool
+		wantOut string
+	}{
+		{"text", FormatText, "info", false, "level=WARN msg=hello"},
+		{"json", FormatJSON, "debug", false, `"msg":"hello"`},
+		{"filtered", FormatText, "error", false, ""},
+		{"unknown format", "xml", "info", true, ""},
+		{"unknown level", FormatJSON, "loud", true, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger, err := NewLogger(&out, tc.format, tc.level)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			logger.Warn("hello")
+			if got := out.String(); !strings.Contains(got, tc.wantOut) || (tc.wantOut == "") != (got == "") {
+				t.Errorf("got %q, want %q", got, tc.wantOut)
+			}
+		})
+	}
+}
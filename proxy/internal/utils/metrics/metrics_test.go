@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPMetrics_Middleware(t *testing.T) {
+	reg := NewRegistry()
+	m := NewHTTPMetrics(reg)
+
+	r := chi.NewRouter()
+	r.Use(m.Middleware)
+	// stands in for the proxy middleware answering unrouted paths
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/tasks") {
+				w.Write([]byte("page"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	r.Get("/api/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	r.Handle("/metrics", Handler(reg))
+
+	for _, path := range []string{"/api/users/1", "/api/users/2", "/tasks/", "/tasks/graph/"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	testCases := []struct {
+		route  string
+		status string
+		want   float64
+	}{
+		{"/api/users/{id}", "404", 2},
+		{RouteProxy, "200", 2},
+	}
+
+	for _, tc := range testCases {
+		if got := testutil.ToFloat64(m.requests.WithLabelValues("GET", tc.route, tc.status)); got != tc.want {
+			t.Errorf("got %v requests for %s %s, want %v", got, tc.route, tc.status, tc.want)
+		}
+	}
+
+	wr := httptest.NewRecorder()
+	r.ServeHTTP(wr, httptest.NewRequest("GET", "/metrics", nil))
+	for _, name := range []string{"http_request_duration_seconds_bucket", "go_goroutines"} {
+		if !strings.Contains(wr.Body.String(), name) {
+			t.Errorf("metrics output lacks %s", name)
+		}
+	}
+}
@@ -0,0 +1,401 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"proxy/internal/utils/readresponder"
+	"strings"
+)
+
+const (
+	Version = "3.1.0"
+	// Dialect is the JSON Schema dialect of the schemas in the document.
+	Dialect = "https://spec.openapis.org/oas/3.1/dialect/base"
+)
+
+// problemSchema is the definition of problem details responses, which are
+// served as application/problem+json whatever the operation produces.
+const problemSchema = "readresponder.Problem"
+
+const contentTypeText = "text/plain"
+
+// Document is an OpenAPI 3.1 description of the API.
+type Document struct {
+	OpenAPI           string              `json:"openapi"`
+	Info              Info                `json:"info"`
+	JSONSchemaDialect string              `json:"jsonSchemaDialect"`
+	Servers           []Server            `json:"servers,omitempty"`
+	Paths             map[string]PathItem `json:"paths"`
+	Components        Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+// PathItem maps the lower case methods of a path to their operations.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+type Parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Schema      Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema Schema `json:"schema,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Name        string `json:"name,omitempty"`
+	In          string `json:"in,omitempty"`
+	Scheme      string `json:"scheme,omitempty"`
+}
+
+// Schema is a JSON Schema 2020-12 schema.
+type Schema map[string]any
+
+// swagger is the subset of Swagger 2.0 that swag generates.
+type swagger struct {
+	Swagger             string                          `json:"swagger"`
+	Info                Info                            `json:"info"`
+	Host                string                          `json:"host"`
+	BasePath            string                          `json:"basePath"`
+	Schemes             []string                        `json:"schemes"`
+	Consumes            []string                        `json:"consumes"`
+	Produces            []string                        `json:"produces"`
+	Paths               map[string]map[string]swaggerOp `json:"paths"`
+	Definitions         map[string]Schema               `json:"definitions"`
+	SecurityDefinitions map[string]swaggerSecurity      `json:"securityDefinitions"`
+}
+
+type swaggerOp struct {
+	Tags        []string                   `json:"tags"`
+	Summary     string                     `json:"summary"`
+	Description string                     `json:"description"`
+	Consumes    []string                   `json:"consumes"`
+	Produces    []string                   `json:"produces"`
+	Parameters  []map[string]any           `json:"parameters"`
+	Responses   map[string]swaggerResponse `json:"responses"`
+	Security    []map[string][]string      `json:"security"`
+	Deprecated  bool                       `json:"deprecated"`
+}
+
+type swaggerResponse struct {
+	Description string `json:"description"`
+	Schema      Schema `json:"schema"`
+}
+
+type swaggerSecurity struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Name        string `json:"name"`
+	In          string `json:"in"`
+}
+
+// Convert translates the Swagger 2.0 document generated by swag into
+// OpenAPI 3.1. The base path is prepended to the paths, so that servers
+// only hold the origin. The document has no servers unless the Swagger
+// one names a host; Handler fills them in per request.
+func Convert(doc []byte) (*Document, error) {
+	var src swagger
+	if err := json.Unmarshal(doc, &src); err != nil {
+		return nil, fmt.Errorf("parse swagger: %w", err)
+	}
+	if src.Swagger != "2.0" {
+		return nil, fmt.Errorf("unsupported swagger version %q", src.Swagger)
+	}
+
+	dst := &Document{
+		OpenAPI:           Version,
+		Info:              src.Info,
+		JSONSchemaDialect: Dialect,
+		Paths:             make(map[string]PathItem, len(src.Paths)),
+		Components: Components{
+			Schemas:         make(map[string]Schema, len(src.Definitions)),
+			SecuritySchemes: make(map[string]SecurityScheme, len(src.SecurityDefinitions)),
+		},
+	}
+
+	basePath := strings.TrimSuffix(src.BasePath, "/")
+	if src.Host != "" {
+		scheme := "http"
+		if len(src.Schemes) > 0 {
+			scheme = src.Schemes[0]
+		}
+		dst.Servers = []Server{{URL: scheme + "://" + src.Host}}
+	}
+
+	for name, definition := range src.Definitions {
+		dst.Components.Schemas[name] = convertSchema(definition)
+	}
+
+	for name, security := range src.SecurityDefinitions {
+		scheme, err := convertSecurity(security)
+		if err != nil {
+			return nil, fmt.Errorf("security definition %s: %w", name, err)
+		}
+		dst.Components.SecuritySchemes[name] = scheme
+	}
+
+	for path, methods := range src.Paths {
+		item := make(PathItem, len(methods))
+		for method, op := range methods {
+			converted, err := convertOperation(op, orDefault(op.Consumes, src.Consumes), orDefault(op.Produces, src.Produces))
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+			item[strings.ToLower(method)] = converted
+		}
+		dst.Paths[basePath+path] = item
+	}
+
+	return dst, nil
+}
+
+func convertOperation(src swaggerOp, consumes, produces []string) (*Operation, error) {
+	op := &Operation{
+		Tags:        src.Tags,
+		Summary:     src.Summary,
+		Description: src.Description,
+		Responses:   make(map[string]Response, len(src.Responses)),
+		Security:    src.Security,
+		Deprecated:  src.Deprecated,
+	}
+
+	for _, param := range src.Parameters {
+		name, _ := param["name"].(string)
+		in, _ := param["in"].(string)
+		description, _ := param["description"].(string)
+		required, _ := param["required"].(bool)
+
+		switch in {
+		case "body":
+			schema, _ := param["schema"].(map[string]any)
+			op.RequestBody = &RequestBody{
+				Description: description,
+				Required:    required,
+				Content:     content(convertSchema(schema), consumes),
+			}
+		case "query", "header", "path":
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:        name,
+				In:          in,
+				Description: description,
+				Required:    required || in == "path",
+				Schema:      parameterSchema(param),
+			})
+		default:
+			return nil, fmt.Errorf("parameter %s: unsupported location %q", name, in)
+		}
+	}
+
+	for status, response := range src.Responses {
+		converted := Response{Description: response.Description}
+		if response.Schema != nil {
+			schema := convertSchema(response.Schema)
+			switch {
+			case schema["$ref"] == schemaRef(problemSchema):
+				converted.Content = content(schema, []string{readresponder.ContentTypeProblem})
+			case len(schema) == 1 && schema["type"] == "string":
+				// {string} responses are the plain text of http.Error
+				converted.Content = content(schema, []string{contentTypeText})
+			default:
+				converted.Content = content(schema, produces)
+			}
+		}
+		op.Responses[status] = converted
+	}
+
+	return op, nil
+}
+
+func convertSecurity(src swaggerSecurity) (SecurityScheme, error) {
+	switch src.Type {
+	case "apiKey":
+		return SecurityScheme{Type: "apiKey", Description: src.Description, Name: src.Name, In: src.In}, nil
+	case "basic":
+		return SecurityScheme{Type: "http", Description: src.Description, Scheme: "basic"}, nil
+	default:
+		return SecurityScheme{}, fmt.Errorf("unsupported type %q", src.Type)
+	}
+}
+
+// content offers schema in every media type, JSON when none is listed.
+func content(schema Schema, mediaTypes []string) map[string]MediaType {
+	if len(mediaTypes) == 0 {
+		mediaTypes = []string{readresponder.ContentTypeJSON}
+	}
+	content := make(map[string]MediaType, len(mediaTypes))
+	for _, mediaType := range mediaTypes {
+		content[mediaType] = MediaType{Schema: schema}
+	}
+	return content
+}
+
+// parameterSchema moves the schema keywords that Swagger 2.0 keeps on
+// non-body parameters into a schema.
+func parameterSchema(param map[string]any) Schema {
+	schema := Schema{}
+	for _, keyword := range []string{"type", "format", "items", "default", "enum", "minimum", "maximum",
+		"exclusiveMinimum", "exclusiveMaximum", "minLength", "maxLength", "pattern", "minItems", "maxItems"} {
+		if value, ok := param[keyword]; ok {
+			schema[keyword] = value
+		}
+	}
+	return convertSchema(schema)
+}
+
+// convertSchema rewrites a Swagger 2.0 schema into JSON Schema 2020-12:
+// references point to the components, x-nullable becomes a null type,
+// exclusive bounds become numbers and examples are listed.
+func convertSchema(src Schema) Schema {
+	if src == nil {
+		return nil
+	}
+
+	dst := make(Schema, len(src))
+	for keyword, value := range src {
+		switch keyword {
+		case "$ref":
+			ref, _ := value.(string)
+			dst[keyword] = strings.Replace(ref, "#/definitions/", "#/components/schemas/", 1)
+		case "example":
+			dst["examples"] = []any{value}
+		case "x-nullable", "exclusiveMinimum", "exclusiveMaximum":
+			// handled with the keywords they modify
+		case "properties", "patternProperties", "definitions":
+			schemas, _ := value.(map[string]any)
+			converted := make(map[string]any, len(schemas))
+			for name, schema := range schemas {
+				converted[name] = convertSchemaValue(schema)
+			}
+			dst[keyword] = converted
+		case "items", "additionalProperties", "not":
+			dst[keyword] = convertSchemaValue(value)
+		case "allOf", "anyOf", "oneOf":
+			schemas, _ := value.([]any)
+			converted := make([]any, 0, len(schemas))
+			for _, schema := range schemas {
+				converted = append(converted, convertSchemaValue(schema))
+			}
+			dst[keyword] = converted
+		default:
+			dst[keyword] = value
+		}
+	}
+
+	if nullable, _ := src["x-nullable"].(bool); nullable {
+		if typ, ok := src["type"].(string); ok {
+			dst["type"] = []any{typ, "null"}
+		}
+	}
+	for keyword, bound := range map[string]string{"exclusiveMinimum": "minimum", "exclusiveMaximum": "maximum"} {
+		if exclusive, _ := src[keyword].(bool); exclusive {
+			if value, ok := src[bound]; ok {
+				dst[keyword] = value
+				delete(dst, bound)
+			}
+		}
+	}
+	return dst
+}
+
+// convertSchemaValue converts a schema that may also be a boolean, as in
+// additionalProperties.
+func convertSchemaValue(value any) any {
+	if schema, ok := value.(map[string]any); ok {
+		return convertSchema(schema)
+	}
+	return value
+}
+
+func schemaRef(name string) string {
+	return "#/components/schemas/" + name
+}
+
+func orDefault(values, defaults []string) []string {
+	if len(values) > 0 {
+		return values
+	}
+	return defaults
+}
+
+// Handler serves the document as JSON. Its server is serverURL when set,
+// and otherwise the origin the request was sent to, as seen through
+// reverse proxies setting the X-Forwarded-Proto and X-Forwarded-Host
+// headers.
+func Handler(doc *Document, serverURL string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served := *doc
+		url := strings.TrimSuffix(serverURL, "/")
+		if url == "" {
+			url = origin(r)
+		}
+		served.Servers = []Server{{URL: url}}
+
+		w.Header().Set("Content-Type", readresponder.ContentTypeJSON+"; charset=utf-8")
+		w.Header().Add("Vary", "X-Forwarded-Proto, X-Forwarded-Host")
+		json.NewEncoder(w).Encode(served)
+	})
+}
+
+func origin(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := forwarded(r, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+
+	host := r.Host
+	if forwardedHost := forwarded(r, "X-Forwarded-Host"); forwardedHost != "" {
+		host = forwardedHost
+	}
+	return scheme + "://" + host
+}
+
+// forwarded returns the value set by the proxy closest to the client.
+func forwarded(r *http.Request, header string) string {
+	value, _, _ := strings.Cut(r.Header.Get(header), ",")
+	return strings.TrimSpace(value)
+}
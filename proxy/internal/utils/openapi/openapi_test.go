@@ -0,0 +1,172 @@
+package openapi
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http/httptest"
+	"proxy/docs"
+	"reflect"
+	"testing"
+)
+
+const swaggerDoc = `{
+	"swagger": "2.0",
+	"info": {"title": "Test API", "version": "1.0.0"},
+	"basePath": "/v1",
+	"paths": {
+		"/users": {
+			"post": {
+				"consumes": ["application/json", "application/msgpack"],
+				"produces": ["application/json"],
+				"parameters": [
+					{"name": "user", "in": "body", "required": true, "schema": {"$ref": "#/definitions/User"}},
+					{"name": "page", "in": "query", "type": "integer", "minimum": 1, "default": 1}
+				],
+				"responses": {
+					"201": {"description": "Created", "schema": {"$ref": "#/definitions/User"}},
+					"400": {"description": "Bad Request", "schema": {"$ref": "#/definitions/readresponder.Problem"}},
+					"403": {"description": "Forbidden", "schema": {"type": "string"}}
+				}
+			}
+		}
+	},
+	"definitions": {
+		"User": {
+			"type": "object",
+			"required": ["email"],
+			"properties": {
+				"email": {"type": "string", "example": "user@example.com"},
+				"nickname": {"type": "string", "x-nullable": true},
+				"age": {"type": "integer", "minimum": 0, "exclusiveMinimum": true}
+			}
+		},
+		"readresponder.Problem": {"type": "object"}
+	},
+	"securityDefinitions": {
+		"ApiKeyAuth": {"type": "apiKey", "name": "Authorization", "in": "header"}
+	}
+}`
+
+func TestConvert(t *testing.T) {
+	doc, err := Convert([]byte(swaggerDoc))
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	if doc.OpenAPI != Version {
+		t.Errorf("got openapi %q, want %q", doc.OpenAPI, Version)
+	}
+	if len(doc.Servers) != 0 {
+		t.Errorf("got servers %v, want none without a host", doc.Servers)
+	}
+
+	op := doc.Paths["/v1/users"]["post"]
+	if op == nil {
+		t.Fatalf("got paths %v, want POST /v1/users", doc.Paths)
+	}
+
+	user := Schema{"$ref": "#/components/schemas/User"}
+	wantBody := &RequestBody{Required: true, Content: map[string]MediaType{
+		"application/json":    {Schema: user},
+		"application/msgpack": {Schema: user},
+	}}
+	if !reflect.DeepEqual(op.RequestBody, wantBody) {
+		t.Errorf("got request body %v, want %v", op.RequestBody, wantBody)
+	}
+
+	wantParams := []Parameter{{Name: "page", In: "query", Schema: Schema{"type": "integer", "minimum": 1.0, "default": 1.0}}}
+	if !reflect.DeepEqual(op.Parameters, wantParams) {
+		t.Errorf("got parameters %v, want %v", op.Parameters, wantParams)
+	}
+
+	for status, want := range map[string]string{"201": "application/json", "400": "application/problem+json", "403": "text/plain"} {
+		content := op.Responses[status].Content
+		if _, ok := content[want]; !ok || len(content) != 1 {
+			t.Errorf("got %s content %v, want %s", status, content, want)
+		}
+	}
+
+	properties := doc.Components.Schemas["User"]["properties"].(map[string]any)
+	wantProperties := map[string]any{
+		"email":    Schema{"type": "string", "examples": []any{"user@example.com"}},
+		"nickname": Schema{"type": []any{"string", "null"}},
+		"age":      Schema{"type": "integer", "exclusiveMinimum": 0.0},
+	}
+	if !reflect.DeepEqual(properties, wantProperties) {
+		t.Errorf("got properties %v, want %v", properties, wantProperties)
+	}
+
+	if scheme := doc.Components.SecuritySchemes["ApiKeyAuth"]; scheme != (SecurityScheme{Type: "apiKey", Name: "Authorization", In: "header"}) {
+		t.Errorf("got security scheme %v", scheme)
+	}
+}
+
+func TestConvert_Errors(t *testing.T) {
+	testCases := []struct {
+		name string
+		doc  string
+	}{
+		{"not json", `swagger: "2.0"`},
+		{"openapi 3", `{"openapi": "3.0.0"}`},
+		{"form parameter", `{"swagger": "2.0", "paths": {"/upload": {"post": {"parameters": [{"name": "file", "in": "formData"}]}}}}`},
+		{"oauth2", `{"swagger": "2.0", "securityDefinitions": {"OAuth": {"type": "oauth2"}}}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Convert([]byte(tc.doc)); err == nil {
+				t.Error("got nil error, want an error")
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	doc, err := Convert([]byte(docs.SwaggerInfo.ReadDoc()))
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	testCases := []struct {
+		name      string
+		serverURL string
+		tls       bool
+		headers   map[string]string
+		want      string
+	}{
+		{"request", "", false, nil, "http://geo.local:8080"},
+		{"tls", "", true, nil, "https://geo.local:8080"},
+		{"forwarded", "", false, map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "geo.example.com, proxy.internal"}, "https://geo.example.com"},
+		{"unknown forwarded proto", "", false, map[string]string{"X-Forwarded-Proto": "gopher"}, "http://geo.local:8080"},
+		{"configured", "https://api.example.com/", false, map[string]string{"X-Forwarded-Host": "geo.example.com"}, "https://api.example.com"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://geo.local:8080/openapi.json", nil)
+			if tc.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+			wr := httptest.NewRecorder()
+			Handler(doc, tc.serverURL).ServeHTTP(wr, r)
+
+			var got Document
+			if err := json.NewDecoder(wr.Body).Decode(&got); err != nil {
+				t.Fatalf("decode spec: %v", err)
+			}
+			if len(got.Servers) != 1 || got.Servers[0].URL != tc.want {
+				t.Errorf("got servers %v, want %s", got.Servers, tc.want)
+			}
+			if got.OpenAPI != Version || len(got.Paths) == 0 {
+				t.Errorf("got openapi %q with %d paths, want the converted spec", got.OpenAPI, len(got.Paths))
+			}
+		})
+	}
+
+	if len(doc.Servers) != 0 {
+		t.Errorf("got servers %v on the shared document, want it untouched", doc.Servers)
+	}
+}
@@ -0,0 +1,400 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"proxy/internal/utils/readresponder"
+	"strconv"
+	"strings"
+)
+
+// maxBody is the size of the bodies validated. Larger ones are passed
+// through unchecked.
+const maxBody = 1 << 20
+
+// documentURL locates the document when compiling its schemas.
+const documentURL = "openapi.json"
+
+var ErrorSpecViolation = errors.New("request does not match the API specification")
+
+var ProblemSpecViolation = readresponder.ProblemType{
+	Type:   "/problems/spec-violation",
+	Title:  "Request does not match the API specification",
+	Status: http.StatusBadRequest,
+}
+
+// problems writes the rejected requests, whatever the route they match.
+var problems = readresponder.NewReadRespond(readresponder.WithProblem(ErrorSpecViolation, ProblemSpecViolation))
+
+var printer = message.NewPrinter(language.English)
+
+type ValidatorOption func(*Validator)
+
+// WithRejectInvalid rejects requests that do not match the document with
+// a 400 problem instead of only logging them.
+func WithRejectInvalid() ValidatorOption {
+	return func(v *Validator) {
+		v.reject = true
+	}
+}
+
+// Validator checks the requests and responses of the documented
+// operations against the document. It is meant for development: it
+// buffers bodies and compiles every schema, and only JSON bodies are
+// checked.
+type Validator struct {
+	operations map[string]*operation
+	logger     *slog.Logger
+	reject     bool
+}
+
+type operation struct {
+	parameters []parameter
+	body       *body
+	responses  map[string]map[string]*jsonschema.Schema
+}
+
+type parameter struct {
+	name     string
+	in       string
+	required bool
+	schema   *jsonschema.Schema
+}
+
+type body struct {
+	required bool
+	content  map[string]*jsonschema.Schema
+}
+
+// NewValidator compiles the schemas of every operation of doc.
+func NewValidator(doc *Document, logger *slog.Logger, options ...ValidatorOption) (*Validator, error) {
+	// the schemas are compiled from the JSON document for $ref to resolve
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	value, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.DefaultDraft(jsonschema.Draft2020)
+	if err := compiler.AddResource(documentURL, value); err != nil {
+		return nil, err
+	}
+	compile := func(schema Schema, pointer ...string) (*jsonschema.Schema, error) {
+		if schema == nil {
+			return nil, nil
+		}
+		for i, token := range pointer {
+			pointer[i] = strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+		}
+		return compiler.Compile(documentURL + "#/" + strings.Join(pointer, "/"))
+	}
+
+	v := &Validator{operations: make(map[string]*operation), logger: logger}
+	for _, option := range options {
+		option(v)
+	}
+
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			compiled := &operation{responses: make(map[string]map[string]*jsonschema.Schema, len(op.Responses))}
+
+			for i, param := range op.Parameters {
+				schema, err := compile(param.Schema, "paths", path, method, "parameters", strconv.Itoa(i), "schema")
+				if err != nil {
+					return nil, err
+				}
+				compiled.parameters = append(compiled.parameters, parameter{name: param.Name, in: param.In, required: param.Required, schema: schema})
+			}
+
+			if op.RequestBody != nil {
+				compiled.body = &body{required: op.RequestBody.Required, content: make(map[string]*jsonschema.Schema)}
+				for mediaType, content := range op.RequestBody.Content {
+					schema, err := compile(content.Schema, "paths", path, method, "requestBody", "content", mediaType, "schema")
+					if err != nil {
+						return nil, err
+					}
+					compiled.body.content[mediaType] = schema
+				}
+			}
+
+			for status, response := range op.Responses {
+				content := make(map[string]*jsonschema.Schema, len(response.Content))
+				for mediaType, media := range response.Content {
+					schema, err := compile(media.Schema, "paths", path, method, "responses", status, "content", mediaType, "schema")
+					if err != nil {
+						return nil, err
+					}
+					content[mediaType] = schema
+				}
+				compiled.responses[status] = content
+			}
+
+			v.operations[strings.ToUpper(method)+" "+path] = compiled
+		}
+	}
+
+	return v, nil
+}
+
+// Middleware logs the requests and responses of documented operations that
+// do not match the document, and rejects such requests with
+// WithRejectInvalid. Other routes are served untouched.
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, ok := v.operations[r.Method+" "+r.URL.Path]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if fields := op.validateRequest(r); len(fields) > 0 {
+			v.logger.WarnContext(r.Context(), "request does not match the API specification",
+				"method", r.Method, "path", r.URL.Path, "error", fields)
+			if v.reject {
+				readresponder.ProblemDetails(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					problems.WriteError(w, r, fmt.Errorf("%w: %w", ErrorSpecViolation, fields))
+				})).ServeHTTP(w, r)
+				return
+			}
+		}
+
+		rec := &recorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		if fields := op.validateResponse(rec); len(fields) > 0 {
+			v.logger.WarnContext(r.Context(), "response does not match the API specification",
+				"method", r.Method, "path", r.URL.Path, "status", rec.status, "error", fields)
+		}
+	})
+}
+
+// validateRequest reports the parameters by name and the body fields by
+// path, as in points[0].lat.
+func (op *operation) validateRequest(r *http.Request) readresponder.FieldErrors {
+	var fields readresponder.FieldErrors
+	for _, param := range op.parameters {
+		fields = append(fields, param.validate(r)...)
+	}
+	if op.body != nil {
+		fields = append(fields, op.body.validate(r)...)
+	}
+	return fields
+}
+
+func (p parameter) validate(r *http.Request) readresponder.FieldErrors {
+	var value string
+	var present bool
+	switch p.in {
+	case "query":
+		var values []string
+		values, present = r.URL.Query()[p.name]
+		if present {
+			value = values[0]
+		}
+	case "header":
+		value = r.Header.Get(p.name)
+		present = value != ""
+	default:
+		return nil
+	}
+
+	if !present {
+		if p.required {
+			return readresponder.FieldErrors{{Field: p.name, Message: "is required"}}
+		}
+		return nil
+	}
+	if p.schema == nil {
+		return nil
+	}
+
+	instance, ok := parameterValue(value, p.schema)
+	if !ok {
+		return readresponder.FieldErrors{{Field: p.name, Message: "must be " + strings.Join(p.schema.Types.ToStrings(), " or ")}}
+	}
+	return schemaErrors(p.name, p.schema.Validate(instance))
+}
+
+// parameterValue converts a parameter to the type of its schema.
+func parameterValue(value string, schema *jsonschema.Schema) (any, bool) {
+	if schema.Types == nil {
+		return value, true
+	}
+	for _, typ := range schema.Types.ToStrings() {
+		switch typ {
+		case "integer", "number":
+			if _, err := strconv.ParseFloat(value, 64); err == nil {
+				return json.Number(value), true
+			}
+		case "boolean":
+			if b, err := strconv.ParseBool(value); err == nil {
+				return b, true
+			}
+		case "string":
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+func (b *body) validate(r *http.Request) readresponder.FieldErrors {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+	// the handler reads the body as if it had not been validated
+	r.Body = readCloser{io.MultiReader(bytes.NewReader(data), r.Body), r.Body}
+	if err != nil || len(data) > maxBody {
+		return nil
+	}
+
+	if len(data) == 0 {
+		if b.required {
+			return readresponder.FieldErrors{{Field: "body", Message: "is required"}}
+		}
+		return nil
+	}
+	return validateBody(b.content, r.Header.Get("Content-Type"), data)
+}
+
+func (op *operation) validateResponse(rec *recorder) readresponder.FieldErrors {
+	content, ok := op.responses[strconv.Itoa(rec.status)]
+	if !ok {
+		content, ok = op.responses[strconv.Itoa(rec.status/100)+"XX"]
+	}
+	if !ok {
+		content, ok = op.responses["default"]
+	}
+	if !ok {
+		return readresponder.FieldErrors{{Field: "status", Message: fmt.Sprintf("%d is not documented", rec.status)}}
+	}
+
+	if len(rec.body) == 0 && !rec.truncated {
+		return nil
+	}
+	if rec.truncated {
+		// too large to check, but its content type still has to be documented
+		return validateBody(content, rec.Header().Get("Content-Type"), nil)
+	}
+	return validateBody(content, rec.Header().Get("Content-Type"), rec.body)
+}
+
+// validateBody checks that the content type of a body is documented and
+// that JSON bodies match their schema. Other formats are not checked.
+func validateBody(content map[string]*jsonschema.Schema, contentType string, data []byte) readresponder.FieldErrors {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	schema, ok := content[mediaType]
+	if !ok {
+		return readresponder.FieldErrors{{Field: "Content-Type", Message: fmt.Sprintf("%q is not documented", mediaType)}}
+	}
+	if schema == nil || data == nil || !isJSON(mediaType) {
+		return nil
+	}
+
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return readresponder.FieldErrors{{Field: "body", Message: "is not valid JSON"}}
+	}
+	return schemaErrors("body", schema.Validate(instance))
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == readresponder.ContentTypeJSON || strings.HasSuffix(mediaType, "+json")
+}
+
+// schemaErrors lists the keywords that failed, on the field they failed
+// at. root names the field when the whole value failed.
+func schemaErrors(root string, err error) readresponder.FieldErrors {
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		if err != nil {
+			return readresponder.FieldErrors{{Field: root, Message: err.Error()}}
+		}
+		return nil
+	}
+
+	var fields readresponder.FieldErrors
+	var collect func(*jsonschema.ValidationError)
+	collect = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			fields = append(fields, readresponder.FieldError{Field: fieldPath(root, e.InstanceLocation), Message: e.ErrorKind.LocalizedString(printer)})
+		}
+		for _, cause := range e.Causes {
+			collect(cause)
+		}
+	}
+	collect(validationErr)
+	return fields
+}
+
+// fieldPath joins the tokens of a JSON pointer as in points[0].lat.
+func fieldPath(root string, tokens []string) string {
+	if len(tokens) == 0 {
+		return root
+	}
+
+	var path strings.Builder
+	for _, token := range tokens {
+		if _, err := strconv.Atoi(token); err == nil {
+			path.WriteString("[" + token + "]")
+			continue
+		}
+		if path.Len() > 0 {
+			path.WriteByte('.')
+		}
+		path.WriteString(token)
+	}
+	return path.String()
+}
+
+// recorder keeps a copy of the response body for validation.
+type recorder struct {
+	http.ResponseWriter
+	status    int
+	body      []byte
+	truncated bool
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	if !rec.truncated {
+		if len(rec.body)+len(p) > maxBody {
+			rec.body, rec.truncated = nil, true
+		} else {
+			rec.body = append(rec.body, p...)
+		}
+	}
+	return rec.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController flush streamed responses.
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
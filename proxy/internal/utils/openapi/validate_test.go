@@ -0,0 +1,127 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"proxy/docs"
+	"proxy/internal/utils/readresponder"
+	"strings"
+	"testing"
+)
+
+func newTestValidator(t *testing.T, logs *bytes.Buffer, options ...ValidatorOption) *Validator {
+	t.Helper()
+	doc, err := Convert([]byte(docs.SwaggerInfo.ReadDoc()))
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+	// every schema of the generated docs compiles
+	v, err := NewValidator(doc, slog.New(slog.NewTextHandler(logs, nil)), options...)
+	if err != nil {
+		t.Fatalf("NewValidator() error = %v", err)
+	}
+	return v
+}
+
+func TestValidator_Middleware(t *testing.T) {
+	page := `{"data":[{"city":"Moscow","street":"Lenina"}],"meta":{"page":1,"per_page":20,"total":1,"total_pages":1}}`
+
+	testCases := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		status      int
+		respType    string
+		resp        string
+		wantLog     string
+	}{
+		{"valid", "/api/v2/address/search?page=2", "application/json", `{"query":"Lenina"}`, 200, "application/json", page, ""},
+		{"problem", "/api/v2/address/search", "application/json", `{"query":"Lenina"}`, 503, "application/problem+json", `{"type":"about:blank","title":"Unavailable","status":503}`, ""},
+		{"plain text error", "/api/v2/address/search", "application/json", `{"query":"Lenina"}`, 403, "text/plain; charset=utf-8", "no token found\n", ""},
+		{"other format", "/api/v2/address/search", "application/msgpack", "\x81\xa5query\xa6Lenina", 200, "text/csv", "city,street\n", ""},
+		{"undocumented route", "/api/v2/nope", "application/json", `{`, 404, "text/plain", "404 page not found\n", ""},
+		{"invalid body", "/api/v2/address/search", "application/json", `{"query":5}`, 200, "application/json", page, "query: got number, want string"},
+		{"missing body", "/api/v2/address/search", "application/json", "", 200, "application/json", page, "body: is required"},
+		{"invalid query", "/api/v2/address/search?per_page=500&page=first", "application/json", `{"query":"Lenina"}`, 200, "application/json", page, "page: must be integer; per_page: maximum: got 500, want 100"},
+		{"undocumented request type", "/api/v2/address/search", "application/xml", "<query/>", 415, "application/problem+json", `{"type":"about:blank","title":"Unsupported","status":415}`, `Content-Type: \"application/xml\" is not documented`},
+		{"invalid response", "/api/v2/address/search", "application/json", `{"query":"Lenina"}`, 200, "application/json", `{"data":[{"city":1}]}`, "data[0].city: got number, want string"},
+		{"undocumented status", "/api/v2/address/search", "application/json", `{"query":"Lenina"}`, 409, "application/problem+json", `{}`, "status: 409 is not documented"},
+		{"undocumented response type", "/api/v2/address/search", "application/json", `{"query":"Lenina"}`, 200, "application/xml", "<data/>", `Content-Type: \"application/xml\" is not documented`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			v := newTestValidator(t, &logs)
+
+			var received string
+			handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				received = string(body)
+				w.Header().Set("Content-Type", tc.respType)
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.resp))
+			}))
+
+			r := httptest.NewRequest("POST", tc.path, strings.NewReader(tc.body))
+			r.Header.Set("Content-Type", tc.contentType)
+			wr := httptest.NewRecorder()
+			handler.ServeHTTP(wr, r)
+
+			if received != tc.body {
+				t.Errorf("got request body %q in the handler, want %q", received, tc.body)
+			}
+			if wr.Code != tc.status || wr.Body.String() != tc.resp {
+				t.Errorf("got response %d %q, want %d %q", wr.Code, wr.Body, tc.status, tc.resp)
+			}
+			if tc.wantLog == "" && logs.Len() > 0 {
+				t.Errorf("got logs %q, want none", logs.String())
+			}
+			if tc.wantLog != "" && !strings.Contains(logs.String(), tc.wantLog) {
+				t.Errorf("got logs %q, want them to contain %q", logs.String(), tc.wantLog)
+			}
+		})
+	}
+}
+
+func TestValidator_RejectInvalid(t *testing.T) {
+	var logs bytes.Buffer
+	v := newTestValidator(t, &logs, WithRejectInvalid())
+
+	called := false
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	r := httptest.NewRequest("POST", "/api/v2/login", strings.NewReader(`{"email":"user@example.com"}`))
+	r.Header.Set("Content-Type", "application/json")
+	wr := httptest.NewRecorder()
+	handler.ServeHTTP(wr, r)
+
+	if called {
+		t.Error("got the handler called, want the request rejected")
+	}
+	if wr.Code != http.StatusBadRequest {
+		t.Errorf("got status code %d, want %d", wr.Code, http.StatusBadRequest)
+	}
+	if got := wr.Header().Get("Content-Type"); got != readresponder.ContentTypeProblem {
+		t.Errorf("got content type %q, want %q", got, readresponder.ContentTypeProblem)
+	}
+
+	var problem readresponder.Problem
+	if err := json.NewDecoder(wr.Body).Decode(&problem); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	if problem.Type != ProblemSpecViolation.Type {
+		t.Errorf("got problem type %q, want %q", problem.Type, ProblemSpecViolation.Type)
+	}
+	want := []readresponder.FieldError{{Field: "body", Message: "missing property 'password'"}}
+	if len(problem.Errors) != 1 || problem.Errors[0] != want[0] {
+		t.Errorf("got errors %v, want %v", problem.Errors, want)
+	}
+}
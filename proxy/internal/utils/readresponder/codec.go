@@ -0,0 +1,268 @@
+package readresponder
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	ContentTypeJSON    = "application/json"
+	ContentTypeMsgpack = "application/msgpack"
+	ContentTypeCBOR    = "application/cbor"
+	ContentTypeCSV     = "text/csv"
+)
+
+// Codec encodes responses in one media type. Struct fields are named by
+// their json tags in every format.
+type Codec interface {
+	ContentType() string
+	Encode(w io.Writer, v any) error
+}
+
+// Decoder is implemented by codecs that can also read request bodies.
+// Codecs without it are offered for responses only.
+type Decoder interface {
+	Decode(r io.Reader, v any) error
+}
+
+// WithCodec registers a codec for its content type, replacing the default
+// one. Aliases are other media types served by the same codec, such as
+// application/x-msgpack.
+func WithCodec(codec Codec, aliases ...string) ReadRespondOption {
+	return func(rr *ReadRespond) {
+		for _, mediaType := range append([]string{codec.ContentType()}, aliases...) {
+			rr.codecs[mediaType] = codec
+		}
+	}
+}
+
+func defaultCodecs() map[string]Codec {
+	msgpackCodec := MsgpackCodec{}
+	return map[string]Codec{
+		ContentTypeJSON:           JSONCodec{},
+		ContentTypeMsgpack:        msgpackCodec,
+		"application/x-msgpack":   msgpackCodec,
+		"application/vnd.msgpack": msgpackCodec,
+		ContentTypeCBOR:           CBORCodec{},
+		ContentTypeCSV:            CSVCodec{},
+	}
+}
+
+// requestCodec picks the decoder for the Content-Type of the request.
+// A missing Content-Type is taken as JSON, and so are +json media types.
+func (rr *ReadRespond) requestCodec(r *http.Request) (Codec, error) {
+	header := r.Header.Get("Content-Type")
+	if header == "" {
+		return rr.codecs[ContentTypeJSON], nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err == nil && strings.HasSuffix(mediaType, "+json") {
+		mediaType = ContentTypeJSON
+	}
+	if codec, ok := rr.codecs[mediaType]; ok && err == nil {
+		if _, ok := codec.(Decoder); ok {
+			return codec, nil
+		}
+	}
+	return nil, &ContentTypeError{ContentType: header, Supported: rr.decodable()}
+}
+
+// responseCodec picks the codec with the highest quality in the Accept
+// header. Exact media types win over wildcards of the same quality, and
+// wildcards and a missing header select JSON.
+func (rr *ReadRespond) responseCodec(r *http.Request) (Codec, error) {
+	header := r.Header.Get("Accept")
+	if header == "" {
+		return rr.codecs[ContentTypeJSON], nil
+	}
+
+	var best Codec
+	var bestRange acceptRange
+	for _, accepted := range parseAccept(header) {
+		if accepted.quality < bestRange.quality ||
+			accepted.quality == bestRange.quality && (accepted.wildcard() || !bestRange.wildcard()) {
+			continue
+		}
+		if codec := rr.acceptedCodec(accepted.mediaType); codec != nil {
+			best, bestRange = codec, accepted
+		}
+	}
+
+	if best == nil {
+		return nil, &NotAcceptableError{Accept: header, Supported: rr.encodable()}
+	}
+	return best, nil
+}
+
+func (rr *ReadRespond) acceptedCodec(mediaType string) Codec {
+	switch {
+	case mediaType == "*/*", mediaType == "application/*":
+		return rr.codecs[ContentTypeJSON]
+	case strings.HasSuffix(mediaType, "/*"):
+		for _, contentType := range rr.encodable() {
+			if strings.HasPrefix(contentType, strings.TrimSuffix(mediaType, "*")) {
+				return rr.codecs[contentType]
+			}
+		}
+		return nil
+	default:
+		return rr.codecs[mediaType]
+	}
+}
+
+func (rr *ReadRespond) encodable() []string {
+	return rr.contentTypes(func(Codec) bool { return true })
+}
+
+func (rr *ReadRespond) decodable() []string {
+	return rr.contentTypes(func(codec Codec) bool {
+		_, ok := codec.(Decoder)
+		return ok
+	})
+}
+
+// contentTypes lists the primary content types of the registered codecs,
+// leaving out the aliases.
+func (rr *ReadRespond) contentTypes(keep func(Codec) bool) []string {
+	var types []string
+	for mediaType, codec := range rr.codecs {
+		if codec.ContentType() == mediaType && keep(codec) {
+			types = append(types, mediaType)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+type acceptRange struct {
+	mediaType string
+	quality   float64
+}
+
+func (a acceptRange) wildcard() bool {
+	return strings.HasSuffix(a.mediaType, "/*")
+}
+
+// parseAccept reads the media ranges of an Accept header in order. Ranges
+// with invalid or zero quality are dropped.
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil || quality <= 0 || quality > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, quality: quality})
+	}
+	return ranges
+}
+
+// JSONCodec is the default codec. It rejects unknown fields and trailing
+// data.
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string { return ContentTypeJSON }
+
+func (JSONCodec) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (JSONCodec) Decode(r io.Reader, v any) error {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		return decodeError(err)
+	}
+
+	if err := decoder.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var tooLarge *BodyTooLargeError
+		if errors.As(decodeError(err), &tooLarge) {
+			return tooLarge
+		}
+		return ErrorMultipleObjects
+	}
+	return nil
+}
+
+// MsgpackCodec encodes MessagePack.
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) ContentType() string { return ContentTypeMsgpack }
+
+func (MsgpackCodec) Encode(w io.Writer, v any) error {
+	encoder := msgpack.NewEncoder(w)
+	encoder.SetCustomStructTag("json")
+	encoder.SetOmitEmpty(true)
+	return encoder.Encode(v)
+}
+
+func (MsgpackCodec) Decode(r io.Reader, v any) error {
+	decoder := msgpack.NewDecoder(r)
+	decoder.SetCustomStructTag("json")
+	decoder.DisallowUnknownFields(true)
+
+	if err := decoder.Decode(v); err != nil {
+		// msgpack has no type for unknown fields either
+		if field, ok := strings.CutPrefix(err.Error(), "msgpack: unknown field "); ok {
+			return &UnknownFieldError{Field: strings.Trim(field, `"`)}
+		}
+		return binaryDecodeError("MessagePack", err)
+	}
+	return nil
+}
+
+// CBORCodec encodes CBOR (RFC 8949).
+type CBORCodec struct{}
+
+var (
+	cborEncMode, _ = cbor.EncOptions{}.EncMode()
+	cborDecMode, _ = cbor.DecOptions{ExtraReturnErrors: cbor.ExtraDecErrorUnknownField}.DecMode()
+)
+
+func (CBORCodec) ContentType() string { return ContentTypeCBOR }
+
+func (CBORCodec) Encode(w io.Writer, v any) error {
+	return cborEncMode.NewEncoder(w).Encode(v)
+}
+
+func (CBORCodec) Decode(r io.Reader, v any) error {
+	if err := cborDecMode.NewDecoder(r).Decode(v); err != nil {
+		return binaryDecodeError("CBOR", err)
+	}
+	return nil
+}
+
+// binaryDecodeError translates the errors of the binary decoders, which
+// report neither offsets nor typed field errors.
+func binaryDecodeError(format string, err error) error {
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return &BodyTooLargeError{Limit: maxBytesErr.Limit}
+	case errors.Is(err, io.EOF):
+		return ErrorEmptyBody
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorTruncatedBody
+	default:
+		return &MalformedError{Format: format, Err: err}
+	}
+}
@@ -0,0 +1,172 @@
+package readresponder
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type address struct {
+	City   string `json:"city"`
+	Street string `json:"street"`
+	Lat    string `json:"lat" binding:"required"`
+}
+
+func TestReadRespond_Read(t *testing.T) {
+	want := address{City: "Москва", Street: "Подкопаевский переулок", Lat: "55.753214"}
+
+	jsonBody, _ := json.Marshal(want)
+	msgpackBody, _ := msgpack.Marshal(map[string]string{"city": want.City, "street": want.Street, "lat": want.Lat})
+	cborBody, _ := cbor.Marshal(want)
+	unknownBody, _ := msgpack.Marshal(map[string]string{"lat": want.Lat, "zip": "101000"})
+
+	testCases := []struct {
+		name        string
+		contentType string
+		body        []byte
+		wantErr     error
+	}{
+		{"json", "application/json", jsonBody, nil},
+		{"json suffix", "application/vnd.api+json", jsonBody, nil},
+		{"no content type", "", jsonBody, nil},
+		{"msgpack", "application/msgpack", msgpackBody, nil},
+		{"msgpack alias", "application/x-msgpack", msgpackBody, nil},
+		{"cbor", "application/cbor", cborBody, nil},
+		{"msgpack unknown field", "application/msgpack", unknownBody, &UnknownFieldError{Field: "zip"}},
+		{"cbor garbage", "application/cbor", []byte{0xff, 0xff}, &MalformedError{}},
+		{"csv", "text/csv", []byte("city,street,lat\n"), &ContentTypeError{}},
+		{"xml", "application/xml", []byte("<address/>"), &ContentTypeError{}},
+	}
+
+	rr := NewReadRespond()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/address/search", bytes.NewReader(tc.body))
+			if tc.contentType != "" {
+				r.Header.Set("Content-Type", tc.contentType)
+			}
+
+			var got address
+			err := rr.Read(httptest.NewRecorder(), r, &got)
+
+			if tc.wantErr != nil {
+				if reflect.TypeOf(err) != reflect.TypeOf(tc.wantErr) {
+					t.Fatalf("got error %v, want %T", err, tc.wantErr)
+				}
+				if unknown, ok := tc.wantErr.(*UnknownFieldError); ok && !reflect.DeepEqual(err, unknown) {
+					t.Errorf("got error %v, want %v", err, unknown)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got error %v, want nil", err)
+			}
+			if got != want {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadRespond_Write(t *testing.T) {
+	list := JSONResponse{Message: "search completed", Data: []*address{
+		{City: "Москва", Street: "Подкопаевский переулок", Lat: "55.753214"},
+		{City: "Москва", Street: "улица Солянка, \"Дом\"", Lat: "55.752"},
+	}}
+
+	testCases := []struct {
+		name        string
+		accept      string
+		data        any
+		status      int
+		contentType string
+	}{
+		{"no accept", "", list, 200, "application/json;charset=utf-8"},
+		{"wildcard", "*/*", list, 200, "application/json;charset=utf-8"},
+		{"exact over wildcard", "*/*, application/msgpack", list, 200, "application/msgpack"},
+		{"quality", "application/json;q=0.5, application/cbor", list, 200, "application/cbor"},
+		{"type wildcard", "text/*", list, 200, "text/csv;charset=utf-8"},
+		{"csv", "text/csv", list, 200, "text/csv;charset=utf-8"},
+		{"csv single object", "text/csv", JSONResponse{Message: "user registered"}, 406, ContentTypeProblem},
+		{"unsupported", "application/xml", list, 406, ContentTypeProblem},
+		{"refused", "application/json;q=0", list, 406, ContentTypeProblem},
+	}
+
+	rr := NewReadRespond()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := ProblemDetails(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				rr.Write(w, r, http.StatusOK, tc.data)
+			}))
+
+			r := httptest.NewRequest("POST", "/api/address/search", nil)
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+			wr := httptest.NewRecorder()
+			handler.ServeHTTP(wr, r)
+
+			if wr.Code != tc.status {
+				t.Errorf("got status code %d, want %d", wr.Code, tc.status)
+			}
+			if got := wr.Header().Get("Content-Type"); got != tc.contentType {
+				t.Errorf("got content type %q, want %q", got, tc.contentType)
+			}
+			if got := wr.Header().Get("Vary"); got != "Accept" {
+				t.Errorf("got Vary %q, want Accept", got)
+			}
+		})
+	}
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	want := JSONResponse{Message: "search completed", Data: []any{map[string]any{"city": "Москва"}}}
+
+	for _, codec := range []Codec{JSONCodec{}, MsgpackCodec{}, CBORCodec{}} {
+		t.Run(codec.ContentType(), func(t *testing.T) {
+			var body bytes.Buffer
+			if err := codec.Encode(&body, want); err != nil {
+				t.Fatal(err)
+			}
+
+			var got struct {
+				Error   bool   `json:"error"`
+				Message string `json:"message"`
+				Data    []struct {
+					City string `json:"city"`
+				} `json:"data"`
+			}
+			if err := codec.(Decoder).Decode(&body, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Message != want.Message || len(got.Data) != 1 || got.Data[0].City != "Москва" {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestCSVCodec_Encode(t *testing.T) {
+	resp := JSONResponse{Data: []*address{
+		{City: "Москва", Street: "улица Солянка, \"Дом\"", Lat: "55.752"},
+		nil,
+	}}
+
+	var body strings.Builder
+	if err := (CSVCodec{}).Encode(&body, resp); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "city,street,lat\nМосква,\"улица Солянка, \"\"Дом\"\"\",55.752\n"
+	if body.String() != want {
+		t.Errorf("got %q, want %q", body.String(), want)
+	}
+}
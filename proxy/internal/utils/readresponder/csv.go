@@ -0,0 +1,122 @@
+package readresponder
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+var ErrorNotTabular = errors.New("response is not a list and cannot be encoded as CSV")
+
+// CSVCodec encodes list responses as CSV with a header row. The list is
+// taken from the data of a JSONResponse or Envelope; its items must be
+// structs, and their json tags name the columns. Fields that are not
+// scalars are written as JSON. CSV bodies are not accepted in requests.
+type CSVCodec struct{}
+
+func (CSVCodec) ContentType() string { return ContentTypeCSV }
+
+func (CSVCodec) Encode(w io.Writer, v any) error {
+	if resp, ok := v.(interface{ payload() any }); ok {
+		v = resp.payload()
+	}
+
+	list := reflect.ValueOf(v)
+	if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+		return ErrorNotTabular
+	}
+
+	itemType := list.Type().Elem()
+	for itemType.Kind() == reflect.Pointer {
+		itemType = itemType.Elem()
+	}
+	if itemType.Kind() != reflect.Struct {
+		return ErrorNotTabular
+	}
+
+	columns := csvColumns(itemType)
+	writer := csv.NewWriter(w)
+
+	header := make([]string, 0, len(columns))
+	for _, column := range columns {
+		header = append(header, column.name)
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for i := 0; i < list.Len(); i++ {
+		item := list.Index(i)
+		for item.Kind() == reflect.Pointer && !item.IsNil() {
+			item = item.Elem()
+		}
+		if item.Kind() != reflect.Struct {
+			continue
+		}
+
+		record := make([]string, 0, len(columns))
+		for _, column := range columns {
+			value, err := csvValue(item.Field(column.index))
+			if err != nil {
+				return err
+			}
+			record = append(record, value)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+type csvColumn struct {
+	name  string
+	index int
+}
+
+// csvColumns lists the exported fields of t in order, named as in JSON.
+func csvColumns(t reflect.Type) []csvColumn {
+	columns := make([]csvColumn, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		columns = append(columns, csvColumn{name: name, index: i})
+	}
+	return columns
+}
+
+func csvValue(v reflect.Value) (string, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface()), nil
+	default:
+		b, err := json.Marshal(v.Interface())
+		return string(b), err
+	}
+}
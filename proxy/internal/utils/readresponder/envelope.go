@@ -0,0 +1,93 @@
+package readresponder
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+)
+
+const (
+	DefaultPerPage = 20
+	MaxPerPage     = 100
+	// MaxPage keeps the offset of any page within an int.
+	MaxPage = math.MaxInt / MaxPerPage
+)
+
+// Envelope is the response body of API v2. Errors are not enveloped, they
+// are problem details. Handlers document the type of Data as in
+// readresponder.Envelope{data=entities.Session}.
+type Envelope struct {
+	Data any   `json:"data"`
+	Meta *Meta `json:"meta,omitempty"`
+}
+
+// Meta describes the page of a list response.
+type Meta struct {
+	Page       int `json:"page" example:"1"`
+	PerPage    int `json:"per_page" example:"20"`
+	Total      int `json:"total" example:"42"`
+	TotalPages int `json:"total_pages" example:"3"`
+}
+
+func (e Envelope) payload() any {
+	return e.Data
+}
+
+// Page is a page of a list requested with the page and per_page query
+// parameters.
+type Page struct {
+	Number int
+	Size   int
+}
+
+// ReadPage reads the page and per_page query parameters. Missing ones
+// default to the first page of DefaultPerPage items.
+func ReadPage(r *http.Request) (Page, error) {
+	page := Page{Number: 1, Size: DefaultPerPage}
+	query := r.URL.Query()
+
+	var fields FieldErrors
+	if value := query.Get("page"); value != "" {
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 1 {
+			fields = append(fields, FieldError{Field: "page", Message: "must be a positive number"})
+		} else if number > MaxPage {
+			fields = append(fields, FieldError{Field: "page", Message: "must be at most " + strconv.Itoa(MaxPage)})
+		}
+		page.Number = number
+	}
+	if value := query.Get("per_page"); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 1 || size > MaxPerPage {
+			fields = append(fields, FieldError{Field: "per_page", Message: "must be between 1 and " + strconv.Itoa(MaxPerPage)})
+		}
+		page.Size = size
+	}
+
+	if len(fields) > 0 {
+		return Page{}, fields
+	}
+	return page, nil
+}
+
+// Paginate cuts page out of items. A page past the end has no data but
+// still reports the total.
+func Paginate[T any](items []T, page Page) Envelope {
+	total := len(items)
+	// pages past the end are not multiplied out, their offset may overflow
+	start := total
+	if page.Number-1 <= total/page.Size {
+		start = min((page.Number-1)*page.Size, total)
+	}
+	end := min(start+page.Size, total)
+
+	return Envelope{
+		Data: append(make([]T, 0, end-start), items[start:end]...),
+		Meta: &Meta{
+			Page:       page.Number,
+			PerPage:    page.Size,
+			Total:      total,
+			TotalPages: (total + page.Size - 1) / page.Size,
+		},
+	}
+}
@@ -0,0 +1,64 @@
+package readresponder
+
+import (
+	"math"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestReadPage(t *testing.T) {
+	testCases := []struct {
+		name    string
+		query   string
+		want    Page
+		wantErr string
+	}{
+		{"defaults", "", Page{Number: 1, Size: DefaultPerPage}, ""},
+		{"page and size", "?page=3&per_page=5", Page{Number: 3, Size: 5}, ""},
+		{"zero page", "?page=0", Page{}, "page: must be a positive number"},
+		{"page over limit", "?page=" + strconv.Itoa(MaxPage+1) + "&per_page=100", Page{}, "page: must be at most " + strconv.Itoa(MaxPage)},
+		{"last page in range", "?page=" + strconv.Itoa(MaxPage) + "&per_page=100", Page{Number: MaxPage, Size: 100}, ""},
+		{"size over limit", "?per_page=101", Page{}, "per_page: must be between 1 and 100"},
+		{"both invalid", "?page=x&per_page=0", Page{}, "page: must be a positive number; per_page: must be between 1 and 100"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ReadPage(httptest.NewRequest("POST", "/api/v2/address/search"+tc.query, nil))
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Errorf("got error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Errorf("got %+v, %v, want %+v", got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+
+	testCases := []struct {
+		name string
+		page Page
+		want Envelope
+	}{
+		{"first page", Page{Number: 1, Size: 2}, Envelope{Data: []int{1, 2}, Meta: &Meta{Page: 1, PerPage: 2, Total: 5, TotalPages: 3}}},
+		{"last page", Page{Number: 3, Size: 2}, Envelope{Data: []int{5}, Meta: &Meta{Page: 3, PerPage: 2, Total: 5, TotalPages: 3}}},
+		{"past the end", Page{Number: 4, Size: 2}, Envelope{Data: []int{}, Meta: &Meta{Page: 4, PerPage: 2, Total: 5, TotalPages: 3}}},
+		{"offset overflow", Page{Number: math.MaxInt, Size: 100}, Envelope{Data: []int{}, Meta: &Meta{Page: math.MaxInt, PerPage: 100, Total: 5, TotalPages: 1}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Paginate(items, tc.page); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v %+v, want %+v %+v", got, got.Meta, tc.want, tc.want.Meta)
+			}
+		})
+	}
+}
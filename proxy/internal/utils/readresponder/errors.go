@@ -0,0 +1,168 @@
+package readresponder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrorEmptyBody       = errors.New("body must not be empty")
+	ErrorTruncatedBody   = errors.New("body ends before the JSON value is complete")
+	ErrorMultipleObjects = errors.New("body must contain a single JSON object")
+)
+
+// SyntaxError reports malformed JSON at a byte offset of the body.
+type SyntaxError struct {
+	Offset int64
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("body contains malformed JSON at byte %d", e.Offset)
+}
+
+// FieldTypeError reports a field holding a value of the wrong JSON type.
+type FieldTypeError struct {
+	Field    string
+	Expected string
+}
+
+func (e *FieldTypeError) Error() string {
+	return fmt.Sprintf("field %q must be %s", e.Field, e.Expected)
+}
+
+// UnknownFieldError reports a field the endpoint does not accept.
+type UnknownFieldError struct {
+	Field string
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("body contains unknown field %q", e.Field)
+}
+
+// BodyTooLargeError reports a body over the WithMaxBytes limit.
+type BodyTooLargeError struct {
+	Limit int64
+}
+
+func (e *BodyTooLargeError) Error() string {
+	return fmt.Sprintf("body must not be larger than %d bytes", e.Limit)
+}
+
+// ContentTypeError reports a body in a format the endpoint cannot read.
+type ContentTypeError struct {
+	ContentType string
+	Supported   []string
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("content type %q is not supported, use %s", e.ContentType, strings.Join(e.Supported, ", "))
+}
+
+// NotAcceptableError reports an Accept header no codec can satisfy.
+type NotAcceptableError struct {
+	Accept    string
+	Supported []string
+}
+
+func (e *NotAcceptableError) Error() string {
+	return fmt.Sprintf("cannot respond with %q, accept %s", e.Accept, strings.Join(e.Supported, ", "))
+}
+
+// MalformedError reports a body that is not valid in a binary format.
+type MalformedError struct {
+	Format string
+	Err    error
+}
+
+func (e *MalformedError) Error() string {
+	return fmt.Sprintf("body is not valid %s: %v", e.Format, e.Err)
+}
+
+func (e *MalformedError) Unwrap() error {
+	return e.Err
+}
+
+// checkContentType accepts JSON media types, including +json ones such as
+// application/merge-patch+json. A missing Content-Type is taken as JSON.
+func checkContentType(r *http.Request) error {
+	header := r.Header.Get("Content-Type")
+	if header == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return &ContentTypeError{ContentType: header, Supported: []string{ContentTypeJSON}}
+	}
+	return nil
+}
+
+// decodeError translates the errors of encoding/json and MaxBytesReader
+// into the typed errors above.
+func decodeError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		return &SyntaxError{Offset: syntaxErr.Offset}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorTruncatedBody
+	case errors.Is(err, io.EOF):
+		return ErrorEmptyBody
+	case errors.As(err, &typeErr):
+		return &FieldTypeError{Field: typeErr.Field, Expected: jsonType(typeErr)}
+	case errors.As(err, &maxBytesErr):
+		return &BodyTooLargeError{Limit: maxBytesErr.Limit}
+	}
+
+	// encoding/json has no type for unknown fields
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return &UnknownFieldError{Field: strings.Trim(field, `"`)}
+	}
+	return err
+}
+
+// jsonType names the expected type the way a client sees it.
+func jsonType(err *json.UnmarshalTypeError) string {
+	switch err.Type.Kind().String() {
+	case "string":
+		return "a string"
+	case "bool":
+		return "a boolean"
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "float32", "float64":
+		return "a number"
+	case "slice", "array":
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+// errorStatus is the status code of an error written without an explicit one.
+func errorStatus(err error) int {
+	var tooLarge *BodyTooLargeError
+	var contentType *ContentTypeError
+	var notAcceptable *NotAcceptableError
+
+	switch {
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.As(err, &contentType):
+		return http.StatusUnsupportedMediaType
+	case errors.As(err, &notAcceptable):
+		return http.StatusNotAcceptable
+	default:
+		return http.StatusBadRequest
+	}
+}
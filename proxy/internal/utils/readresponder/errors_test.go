@@ -0,0 +1,107 @@
+package readresponder
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadRespond_ReadJSONErrors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		body        string
+		contentType string
+		want        error
+		status      int
+	}{
+		{
+			name:   "syntax error",
+			body:   `{"email": "a@b.c",}`,
+			want:   &SyntaxError{Offset: 19},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "truncated",
+			body:   `{"email": "a@b.c"`,
+			want:   ErrorTruncatedBody,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "wrong type",
+			body:   `{"email": ["a@b.c"]}`,
+			want:   &FieldTypeError{Field: "email", Expected: "a string"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown field",
+			body:   `{"email": "a", "admin": 1}`,
+			want:   &UnknownFieldError{Field: "admin"},
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "empty body",
+			body:   ``,
+			want:   ErrorEmptyBody,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "multiple objects",
+			body:   `{"email": "a@b.c"}{}`,
+			want:   ErrorMultipleObjects,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "too large",
+			body:   `{"email": "` + strings.Repeat("a", 64) + `"}`,
+			want:   &BodyTooLargeError{Limit: 32},
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "form content type",
+			body:        `email=a@b.c`,
+			contentType: "application/x-www-form-urlencoded",
+			want:        &ContentTypeError{ContentType: "application/x-www-form-urlencoded", Supported: []string{"application/json"}},
+			status:      http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "json suffix",
+			body:        `{"email": "a@b.c"}`,
+			contentType: "application/merge-patch+json; charset=utf-8",
+		},
+	}
+
+	rr := NewReadRespond(WithMaxBytes(32))
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/login", strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				r.Header.Set("Content-Type", tc.contentType)
+			}
+
+			var data struct {
+				Email string `json:"email"`
+			}
+			wr := httptest.NewRecorder()
+			err := rr.ReadJSON(wr, r, &data)
+
+			if tc.want == nil {
+				if err != nil {
+					t.Errorf("got error %v, want nil", err)
+				}
+				return
+			}
+			if !reflect.DeepEqual(err, tc.want) && !errors.Is(err, tc.want) {
+				t.Errorf("got error %#v, want %#v", err, tc.want)
+			}
+
+			rr.WriteJSONError(wr, err)
+			if wr.Code != tc.status {
+				t.Errorf("got status code %d, want %d", wr.Code, tc.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package readresponder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"proxy/internal/utils/logging"
+	"strings"
+)
+
+const ContentTypeProblem = "application/problem+json"
+
+// Problem is an RFC 9457 problem details response.
+type Problem struct {
+	Type     string       `json:"type" example:"/problems/user-exists"`
+	Title    string       `json:"title" example:"User already exists"`
+	Status   int          `json:"status" example:"409"`
+	Detail   string       `json:"detail,omitempty" example:"user with this email already exists"`
+	Instance string       `json:"instance,omitempty" example:"/api/register"`
+	Errors   []FieldError `json:"errors,omitempty"`
+	// RequestID is an extension member to match the response with the logs.
+	RequestID string `json:"request_id,omitempty" example:"4bf92f3577b34da6a3ce929d0e0e4736"`
+}
+
+// ProblemType describes a class of errors. Type is a URI reference
+// identifying it; "about:blank" is used for errors without a type.
+type ProblemType struct {
+	Type   string
+	Title  string
+	Status int
+}
+
+// FieldError is a problem with a single field of the request.
+type FieldError struct {
+	Field   string `json:"field" example:"email"`
+	Message string `json:"message" example:"is required"`
+}
+
+// FieldErrors lists every invalid field of a request. Returned as an
+// error, it fills the errors member of the problem.
+type FieldErrors []FieldError
+
+func (fe FieldErrors) Error() string {
+	messages := make([]string, 0, len(fe))
+	for _, e := range fe {
+		messages = append(messages, e.Field+": "+e.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+var (
+	ProblemMalformedBody = ProblemType{Type: "/problems/malformed-body", Title: "Malformed request body", Status: http.StatusBadRequest}
+	ProblemValidation    = ProblemType{Type: "/problems/validation-failed", Title: "Validation failed", Status: http.StatusUnprocessableEntity}
+	ProblemBodyTooLarge  = ProblemType{Type: "/problems/body-too-large", Title: "Request body too large", Status: http.StatusRequestEntityTooLarge}
+	ProblemMediaType     = ProblemType{Type: "/problems/unsupported-media-type", Title: "Unsupported media type", Status: http.StatusUnsupportedMediaType}
+	ProblemNotAcceptable = ProblemType{Type: "/problems/not-acceptable", Title: "Not acceptable", Status: http.StatusNotAcceptable}
+)
+
+// WithProblem maps a sentinel error, matched with errors.Is, to a problem
+// type. It applies to problem responses only; legacy responses keep
+// their status.
+func WithProblem(err error, problem ProblemType) ReadRespondOption {
+	return func(rr *ReadRespond) {
+		rr.problems = append(rr.problems, registeredProblem{err: err, problem: problem})
+	}
+}
+
+type registeredProblem struct {
+	err     error
+	problem ProblemType
+}
+
+type problemKey struct{}
+
+// ProblemDetails opts the routes it wraps into application/problem+json
+// error responses.
+func ProblemDetails(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), problemKey{}, true)))
+	})
+}
+
+func wantsProblem(r *http.Request) bool {
+	enabled, _ := r.Context().Value(problemKey{}).(bool)
+	return enabled
+}
+
+// WriteError writes err as a problem if the route opted in with
+// ProblemDetails, and as a JSONResponse otherwise.
+func (rr *ReadRespond) WriteError(w http.ResponseWriter, r *http.Request, err error, status ...int) error {
+	if !wantsProblem(r) {
+		return rr.WriteJSONError(w, err, status...)
+	}
+
+	problem := rr.problem(err, status...)
+	problem.Instance = r.URL.Path
+	problem.RequestID = logging.RequestIDFromContext(r.Context())
+
+	w.Header().Set("Content-Type", ContentTypeProblem)
+	w.WriteHeader(problem.Status)
+	return json.NewEncoder(w).Encode(problem)
+}
+
+// problem builds the problem for err. Registered errors take their type and
+// status; request decoding errors are reported without the decoder
+// internals, and the details of unexpected server errors are withheld.
+func (rr *ReadRespond) problem(err error, status ...int) Problem {
+	for _, registered := range rr.problems {
+		if errors.Is(err, registered.err) {
+			// the sentinel may wrap internals, such as a provider error
+			return newProblem(registered.problem, registered.err.Error(), err)
+		}
+	}
+
+	if problemType, fields, ok := decodeProblem(err); ok {
+		return newProblem(problemType, err.Error(), fields)
+	}
+
+	var fields FieldErrors
+	if errors.As(err, &fields) {
+		return newProblem(ProblemValidation, "the request has invalid fields", err)
+	}
+
+	statusCode := http.StatusBadRequest
+	if len(status) > 0 {
+		statusCode = status[0]
+	}
+
+	detail := err.Error()
+	if statusCode >= http.StatusInternalServerError {
+		detail = ""
+	}
+	return newProblem(ProblemType{Status: statusCode}, detail, err)
+}
+
+func newProblem(problemType ProblemType, detail string, err error) Problem {
+	problem := Problem{
+		Type:   problemType.Type,
+		Title:  problemType.Title,
+		Status: problemType.Status,
+		Detail: detail,
+	}
+	if problem.Type == "" {
+		problem.Type = "about:blank"
+	}
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+
+	var fields FieldErrors
+	if errors.As(err, &fields) {
+		problem.Errors = fields
+	}
+	return problem
+}
+
+// decodeProblem recognizes the errors of ReadJSON. Field level errors
+// also fill the errors member.
+func decodeProblem(err error) (ProblemType, FieldErrors, bool) {
+	var syntaxErr *SyntaxError
+	var typeErr *FieldTypeError
+	var unknownErr *UnknownFieldError
+	var tooLargeErr *BodyTooLargeError
+	var contentTypeErr *ContentTypeError
+	var notAcceptableErr *NotAcceptableError
+	var malformedErr *MalformedError
+
+	switch {
+	case errors.As(err, &typeErr):
+		return ProblemMalformedBody, FieldErrors{{Field: typeErr.Field, Message: "must be " + typeErr.Expected}}, true
+	case errors.As(err, &unknownErr):
+		return ProblemMalformedBody, FieldErrors{{Field: unknownErr.Field, Message: "is not allowed"}}, true
+	case errors.As(err, &syntaxErr), errors.As(err, &malformedErr), errors.Is(err, ErrorEmptyBody), errors.Is(err, ErrorTruncatedBody),
+		errors.Is(err, ErrorMultipleObjects):
+		return ProblemMalformedBody, nil, true
+	case errors.As(err, &tooLargeErr):
+		return ProblemBodyTooLarge, nil, true
+	case errors.As(err, &contentTypeErr):
+		return ProblemMediaType, nil, true
+	case errors.As(err, &notAcceptableErr):
+		return ProblemNotAcceptable, nil, true
+	}
+	return ProblemType{}, nil, false
+}
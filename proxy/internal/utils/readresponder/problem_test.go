@@ -0,0 +1,123 @@
+package readresponder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var errorTaken = errors.New("email is taken")
+
+func TestReadRespond_WriteError(t *testing.T) {
+	testCases := []struct {
+		name   string
+		body   string
+		err    error
+		status []int
+		want   Problem
+	}{
+		{
+			name: "registered",
+			err:  fmt.Errorf("insert: %w", errorTaken),
+			want: Problem{Type: "/problems/taken", Title: "Taken", Status: 409, Detail: "email is taken"},
+		},
+		{
+			name: "unknown field",
+			body: `{"email":"a@b.c","id":1}`,
+			want: Problem{Type: "/problems/malformed-body", Title: "Malformed request body", Status: 400,
+				Detail: `body contains unknown field "id"`,
+				Errors: []FieldError{{Field: "id", Message: "is not allowed"}}},
+		},
+		{
+			name: "wrong type",
+			body: `{"email":1}`,
+			want: Problem{Type: "/problems/malformed-body", Title: "Malformed request body", Status: 400,
+				Detail: `field "email" must be a string`,
+				Errors: []FieldError{{Field: "email", Message: "must be a string"}}},
+		},
+		{
+			name: "syntax error",
+			body: `{"email":}`,
+			want: Problem{Type: "/problems/malformed-body", Title: "Malformed request body", Status: 400,
+				Detail: "body contains malformed JSON at byte 10"},
+		},
+		{
+			name: "too large",
+			body: `{"email":"` + strings.Repeat("a", 64) + `"}`,
+			want: Problem{Type: "/problems/body-too-large", Title: "Request body too large", Status: 413,
+				Detail: "body must not be larger than 32 bytes"},
+		},
+		{
+			name: "field errors",
+			err:  FieldErrors{{Field: "email", Message: "is required"}},
+			want: Problem{Type: "/problems/validation-failed", Title: "Validation failed", Status: 422,
+				Detail: "the request has invalid fields",
+				Errors: []FieldError{{Field: "email", Message: "is required"}}},
+		},
+		{
+			name:   "server error withheld",
+			err:    errors.New("dial tcp 10.0.0.1:443: connection refused"),
+			status: []int{http.StatusBadGateway},
+			want:   Problem{Type: "about:blank", Title: "Bad Gateway", Status: 502},
+		},
+	}
+
+	rr := NewReadRespond(
+		WithProblem(errorTaken, ProblemType{Type: "/problems/taken", Title: "Taken", Status: http.StatusConflict}),
+		WithMaxBytes(32),
+	)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := ProblemDetails(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				err := tc.err
+				if tc.body != "" {
+					var data struct {
+						Email string `json:"email"`
+					}
+					err = rr.ReadJSON(w, r, &data)
+				}
+				rr.WriteError(w, r, err, tc.status...)
+			}))
+
+			wr := httptest.NewRecorder()
+			handler.ServeHTTP(wr, httptest.NewRequest("POST", "/api/users", strings.NewReader(tc.body)))
+
+			if got := wr.Header().Get("Content-Type"); got != ContentTypeProblem {
+				t.Errorf("got content type %q, want %q", got, ContentTypeProblem)
+			}
+			if wr.Code != tc.want.Status {
+				t.Errorf("got status code %d, want %d", wr.Code, tc.want.Status)
+			}
+
+			var got Problem
+			if err := json.NewDecoder(wr.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			tc.want.Instance = "/api/users"
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReadRespond_WriteErrorLegacy(t *testing.T) {
+	rr := NewReadRespond(WithProblem(errorTaken, ProblemType{Type: "/problems/taken", Status: http.StatusConflict}))
+
+	wr := httptest.NewRecorder()
+	rr.WriteError(wr, httptest.NewRequest("POST", "/api/users", nil), errorTaken)
+
+	var got JSONResponse
+	if err := json.NewDecoder(wr.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if wr.Code != http.StatusBadRequest || !got.Error || got.Message != errorTaken.Error() {
+		t.Errorf("got %d %+v, want the legacy 400 response for routes that did not opt in", wr.Code, got)
+	}
+}
@@ -1,16 +1,21 @@
 package readresponder
 
 import (
-	"encoding/json"
+	"bytes"
 	"errors"
-	"io"
 	"net/http"
+	"slices"
+	"time"
 )
 
 type ReadResponder interface {
+	Read(w http.ResponseWriter, r *http.Request, data any) error
+	Write(w http.ResponseWriter, r *http.Request, status int, data any, headers ...http.Header) error
 	ReadJSON(w http.ResponseWriter, r *http.Request, data any) error
 	WriteJSON(w http.ResponseWriter, status int, data any, headers ...http.Header) error
 	WriteJSONError(w http.ResponseWriter, err error, status ...int) error
+	WriteError(w http.ResponseWriter, r *http.Request, err error, status ...int) error
+	Stream(w http.ResponseWriter, r *http.Request, status int, headers ...http.Header) *Stream
 }
 
 type JSONResponse struct {
@@ -19,8 +24,16 @@ type JSONResponse struct {
 	Data    any    `json:"data,omitempty"`
 }
 
+func (resp JSONResponse) payload() any {
+	return resp.Data
+}
+
 type ReadRespond struct {
 	maxBytes int
+	problems []registeredProblem
+	codecs   map[string]Codec
+
+	streamWriteTimeout time.Duration
 }
 
 type ReadRespondOption func(*ReadRespond)
@@ -32,7 +45,7 @@ func WithMaxBytes(maxBytes int) ReadRespondOption {
 }
 
 func NewReadRespond(options ...ReadRespondOption) *ReadRespond {
-	rr := &ReadRespond{}
+	rr := &ReadRespond{codecs: defaultCodecs(), streamWriteTimeout: defaultStreamWriteTimeout}
 
 	for _, option := range options {
 		option(rr)
@@ -40,25 +53,86 @@ func NewReadRespond(options ...ReadRespondOption) *ReadRespond {
 	return rr
 }
 
+// ReadJSON decodes a single JSON object into data and validates it against
+// its binding tags. Decoding errors are returned as the typed errors of
+// this package, which WriteError maps to 400, 413 or 415.
 func (rr *ReadRespond) ReadJSON(w http.ResponseWriter, r *http.Request, data any) error {
-	if rr.maxBytes > 0 {
-		r.Body = http.MaxBytesReader(w, r.Body, int64(rr.maxBytes))
+	defer r.Body.Close()
+
+	if err := checkContentType(r); err != nil {
+		return err
 	}
+	return rr.decode(w, r, JSONCodec{}, data)
+}
 
-	decoder := json.NewDecoder(r.Body)
+// Read is ReadJSON for every codec that can decode requests, selected by
+// the Content-Type of the request.
+func (rr *ReadRespond) Read(w http.ResponseWriter, r *http.Request, data any) error {
 	defer r.Body.Close()
 
-	decoder.DisallowUnknownFields()
+	codec, err := rr.requestCodec(r)
+	if err != nil {
+		return err
+	}
+	return rr.decode(w, r, codec, data)
+}
+
+func (rr *ReadRespond) decode(w http.ResponseWriter, r *http.Request, codec Codec, data any) error {
+	if rr.maxBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, int64(rr.maxBytes))
+	}
 
-	if err := decoder.Decode(data); err != nil {
+	if err := codec.(Decoder).Decode(r.Body, data); err != nil {
 		return err
 	}
+	return Validate(data)
+}
+
+// Write encodes data with the codec selected by the Accept header of the
+// request. When no codec is acceptable, or data cannot be encoded in the
+// selected format, such as CSV for a single object, it writes a 406 error
+// instead.
+func (rr *ReadRespond) Write(w http.ResponseWriter, r *http.Request, status int, data any, headers ...http.Header) error {
+	w.Header().Add("Vary", "Accept")
+
+	codec, err := rr.responseCodec(r)
+	if err != nil {
+		return rr.WriteError(w, r, err)
+	}
 
-	if err := decoder.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
-		return errors.New("body must contain a single JSON object")
+	// encode first, the status cannot be changed once written
+	var body bytes.Buffer
+	if err := codec.Encode(&body, data); err != nil {
+		if errors.Is(err, ErrorNotTabular) {
+			supported := slices.DeleteFunc(rr.encodable(), func(contentType string) bool {
+				return contentType == codec.ContentType()
+			})
+			return rr.WriteError(w, r, &NotAcceptableError{Accept: r.Header.Get("Accept"), Supported: supported})
+		}
+		return rr.WriteError(w, r, err, http.StatusInternalServerError)
+	}
+
+	if len(headers) > 0 {
+		for key, value := range headers[0] {
+			w.Header()[key] = value
+		}
 	}
 
-	return nil
+	w.Header().Set("Content-Type", contentType(codec))
+	w.WriteHeader(status)
+
+	_, err = body.WriteTo(w)
+	return err
+}
+
+// contentType adds the charset to the text formats.
+func contentType(codec Codec) string {
+	switch codec.ContentType() {
+	case ContentTypeJSON, ContentTypeCSV:
+		return codec.ContentType() + ";charset=utf-8"
+	default:
+		return codec.ContentType()
+	}
 }
 
 func (rr *ReadRespond) WriteJSON(w http.ResponseWriter, status int, data any, headers ...http.Header) error {
@@ -71,12 +145,12 @@ func (rr *ReadRespond) WriteJSON(w http.ResponseWriter, status int, data any, he
 	w.Header().Set("Content-Type", "application/json;charset=utf-8")
 	w.WriteHeader(status)
 
-	return json.NewEncoder(w).Encode(data)
+	return JSONCodec{}.Encode(w, data)
 }
 
 func (rr *ReadRespond) WriteJSONError(w http.ResponseWriter, err error, status ...int) error {
 
-	statusCode := http.StatusBadRequest
+	statusCode := errorStatus(err)
 	if len(status) > 0 {
 		statusCode = status[0]
 	}
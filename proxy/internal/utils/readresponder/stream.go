@@ -0,0 +1,153 @@
+package readresponder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const ContentTypeNDJSON = "application/x-ndjson"
+
+// Trailers sent after the last item of a stream. A stream that ends
+// without TrailerStreamError is complete; clients that cannot read
+// trailers can compare TrailerStreamCount with the items they got.
+const (
+	TrailerStreamError = "X-Stream-Error"
+	TrailerStreamCount = "X-Stream-Count"
+)
+
+const defaultStreamWriteTimeout = 10 * time.Second
+
+var ErrorStreamClosed = errors.New("stream is closed")
+
+// WithStreamWriteTimeout bounds the time a stream waits for the client to
+// take a single item. The server write timeout is lifted for streams, so
+// this is what cuts off a client that stopped reading.
+func WithStreamWriteTimeout(timeout time.Duration) ReadRespondOption {
+	return func(rr *ReadRespond) {
+		rr.streamWriteTimeout = timeout
+	}
+}
+
+// Stream is an NDJSON response that is flushed item by item. Send blocks
+// until the item is handed to the connection, so a producer never runs
+// ahead of a slow client by more than the socket buffers.
+type Stream struct {
+	rr      *ReadRespond
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	ctx     context.Context
+	encoder *json.Encoder
+	timeout time.Duration
+	count   int
+	err     error
+	closed  bool
+}
+
+// Stream writes the status and headers of an NDJSON response and returns
+// the stream to send its items to. Errors found before the first item
+// should be written with WriteError instead, while the status can still
+// tell them apart.
+func (rr *ReadRespond) Stream(w http.ResponseWriter, r *http.Request, status int, headers ...http.Header) *Stream {
+	if len(headers) > 0 {
+		for key, value := range headers[0] {
+			w.Header()[key] = value
+		}
+	}
+
+	w.Header().Set("Content-Type", ContentTypeNDJSON)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Add("Trailer", TrailerStreamError)
+	w.Header().Add("Trailer", TrailerStreamCount)
+
+	s := &Stream{
+		rr:      rr,
+		w:       w,
+		rc:      http.NewResponseController(w),
+		ctx:     r.Context(),
+		encoder: json.NewEncoder(w),
+		timeout: rr.streamWriteTimeout,
+	}
+
+	s.Extend()
+	w.WriteHeader(status)
+	s.err = s.flush()
+	return s
+}
+
+// Send writes item as a line of JSON and flushes it. It returns the
+// context error once the client is gone, and after any failed write
+// every later Send returns the same error.
+func (s *Stream) Send(item any) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.closed {
+		return ErrorStreamClosed
+	}
+	if err := s.ctx.Err(); err != nil {
+		s.err = err
+		return err
+	}
+
+	s.Extend()
+	if err := s.encoder.Encode(item); err != nil {
+		s.err = err
+		return err
+	}
+	if err := s.flush(); err != nil {
+		s.err = err
+		return err
+	}
+	s.count++
+	return nil
+}
+
+// Count is the number of items sent.
+func (s *Stream) Count() int {
+	return s.count
+}
+
+// Close ends the stream and reports err, if any, in the trailers. The
+// trailer carries the same detail a problem response would: registered
+// errors keep their message and unexpected ones are withheld.
+func (s *Stream) Close(err error) error {
+	if s.closed {
+		return ErrorStreamClosed
+	}
+	s.closed = true
+
+	if err != nil {
+		problem := s.rr.problem(err, http.StatusInternalServerError)
+		message := problem.Detail
+		if message == "" {
+			message = problem.Title
+		}
+		s.w.Header().Set(TrailerStreamError, message)
+	}
+	s.w.Header().Set(TrailerStreamCount, strconv.Itoa(s.count))
+	return s.err
+}
+
+// flush sends the buffered items. Writers that cannot flush still get a
+// valid, if buffered, response.
+func (s *Stream) flush() error {
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// Extend pushes the write deadline forward for the next item. Producers
+// call it before slow work between items, as HTTP/2 resets a stream whose
+// deadline passes even while nothing is written. Writers without
+// deadlines, such as test recorders, are left as they are.
+func (s *Stream) Extend() {
+	if s.timeout > 0 {
+		s.rc.SetWriteDeadline(time.Now().Add(s.timeout))
+	}
+}
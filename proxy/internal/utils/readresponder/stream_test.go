@@ -0,0 +1,144 @@
+package readresponder
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var errorProvider = errors.New("provider is unavailable")
+
+func TestReadRespond_Stream(t *testing.T) {
+	testCases := []struct {
+		name      string
+		items     int
+		err       error
+		wantError string
+	}{
+		{"complete", 3, nil, ""},
+		{"empty", 0, nil, ""},
+		{"registered error", 2, fmt.Errorf("geocode: %w", errorProvider), "provider is unavailable"},
+		{"server error withheld", 1, errors.New("dial tcp 10.0.0.1:443: connection refused"), "Internal Server Error"},
+	}
+
+	rr := NewReadRespond(WithProblem(errorProvider, ProblemType{Status: http.StatusServiceUnavailable}))
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				stream := rr.Stream(w, r, http.StatusOK)
+				for i := 0; i < tc.items; i++ {
+					if err := stream.Send(map[string]int{"n": i}); err != nil {
+						t.Errorf("got error %v, want nil", err)
+					}
+				}
+				stream.Close(tc.err)
+			}))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if got := resp.Header.Get("Content-Type"); got != ContentTypeNDJSON {
+				t.Errorf("got content type %q, want %q", got, ContentTypeNDJSON)
+			}
+
+			lines := 0
+			decoder := json.NewDecoder(resp.Body)
+			for {
+				var item map[string]int
+				if err := decoder.Decode(&item); errors.Is(err, io.EOF) {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				if item["n"] != lines {
+					t.Errorf("got item %v, want n %d", item, lines)
+				}
+				lines++
+			}
+
+			if lines != tc.items {
+				t.Errorf("got %d lines, want %d", lines, tc.items)
+			}
+			if got := resp.Trailer.Get(TrailerStreamCount); got != fmt.Sprint(tc.items) {
+				t.Errorf("got count trailer %q, want %d", got, tc.items)
+			}
+			if got := resp.Trailer.Get(TrailerStreamError); got != tc.wantError {
+				t.Errorf("got error trailer %q, want %q", got, tc.wantError)
+			}
+		})
+	}
+}
+
+func TestStream_Flush(t *testing.T) {
+	next := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stream := NewReadRespond().Stream(w, r, http.StatusOK)
+		stream.Send("first")
+		<-next
+		stream.Send("second")
+		stream.Close(nil)
+	}))
+	defer server.Close()
+	defer close(next)
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// the first item arrives while the handler still waits for the second
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "\"first\"\n" {
+		t.Errorf("got line %q, want %q", line, "\"first\"\n")
+	}
+}
+
+func TestStream_ClientGone(t *testing.T) {
+	done := make(chan error, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stream := NewReadRespond(WithStreamWriteTimeout(time.Second)).Stream(w, r, http.StatusOK)
+
+		var err error
+		for err == nil {
+			err = stream.Send("next")
+			time.Sleep(time.Millisecond)
+		}
+		done <- err
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bufio.NewReader(resp.Body).ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("got nil error, want the stream to fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream kept sending after the client disconnected")
+	}
+}
@@ -0,0 +1,100 @@
+package readresponder
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"reflect"
+	"strings"
+)
+
+// validate checks the binding tags of request entities. The tags use the
+// go-playground/validator syntax, which swag also reads to document the
+// rules, e.g. `binding:"required,min=5,max=32"` becomes a required field
+// with minLength and maxLength in the spec.
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.SetTagName("binding")
+
+	// report fields by their JSON names, as the client sent them
+	v.RegisterTagNameFunc(func(sf reflect.StructField) string {
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return sf.Name
+		}
+		return name
+	})
+	return v
+}
+
+// Validate checks data against its binding tags and returns every
+// violation at once as FieldErrors. Values other than structs and
+// pointers to structs are not validated.
+func Validate(data any) error {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	err := validate.Struct(data)
+
+	var violations validator.ValidationErrors
+	if !errors.As(err, &violations) {
+		return err
+	}
+
+	fields := make(FieldErrors, 0, len(violations))
+	for _, violation := range violations {
+		fields = append(fields, FieldError{Field: fieldPath(violation), Message: message(violation)})
+	}
+	return fields
+}
+
+// fieldPath drops the struct name from the namespace: User.email -> email.
+func fieldPath(fe validator.FieldError) string {
+	_, path, ok := strings.Cut(fe.Namespace(), ".")
+	if !ok {
+		return fe.Field()
+	}
+	return path
+}
+
+func message(fe validator.FieldError) string {
+	unit := ""
+	if fe.Kind() == reflect.String {
+		unit = " characters"
+	} else if fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map {
+		unit = " items"
+	}
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return fmt.Sprintf("must be at least %s%s", fe.Param(), unit)
+	case "max", "lte":
+		return fmt.Sprintf("must be at most %s%s", fe.Param(), unit)
+	case "len":
+		return fmt.Sprintf("must be exactly %s%s", fe.Param(), unit)
+	case "email":
+		return "must be a valid email address"
+	case "latitude":
+		return "must be a latitude between -90 and 90"
+	case "longitude":
+		return "must be a longitude between -180 and 180"
+	case "startswith":
+		return fmt.Sprintf("must start with %q", fe.Param())
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fe.Param()), ", ")
+	default:
+		return fmt.Sprintf("failed the %s rule", fe.Tag())
+	}
+}
@@ -0,0 +1,71 @@
+package readresponder
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type point struct {
+	Lat string `json:"lat" binding:"required,latitude"`
+}
+
+type place struct {
+	Name   string   `json:"name" binding:"required,min=2,max=5"`
+	Kind   string   `json:"kind" binding:"omitempty,oneof=city village"`
+	Tags   []string `json:"tags" binding:"max=2"`
+	Point  point    `json:"point"`
+	Secret string   `json:"-" binding:"required"`
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name string
+		body string
+		want FieldErrors
+	}{
+		{
+			name: "valid",
+			body: `{"name":"Tver","kind":"city","point":{"lat":"56.85"}}`,
+		},
+		{
+			name: "every violation",
+			body: `{"name":"a","kind":"town","tags":["a","b","c"],"point":{"lat":"91"}}`,
+			want: FieldErrors{
+				{Field: "name", Message: "must be at least 2 characters"},
+				{Field: "kind", Message: "must be one of: city, village"},
+				{Field: "tags", Message: "must be at most 2 items"},
+				{Field: "point.lat", Message: "must be a latitude between -90 and 90"},
+			},
+		},
+		{
+			name: "missing",
+			body: `{}`,
+			want: FieldErrors{
+				{Field: "name", Message: "is required"},
+				{Field: "point.lat", Message: "is required"},
+			},
+		},
+	}
+
+	rr := NewReadRespond()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := place{Secret: "set by the server"}
+			err := rr.ReadJSON(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(tc.body)), &data)
+
+			if tc.want == nil {
+				if err != nil {
+					t.Errorf("got error %v, want nil", err)
+				}
+				return
+			}
+
+			if got, ok := err.(FieldErrors); !ok || !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %#v, want %#v", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"io"
+	"net/http"
+	"os"
+)
+
+const (
+	ExporterNone   = ""
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+// Propagator carries the W3C trace context and baggage across services.
+var Propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Provider is a tracer provider that has to be shut down to flush the
+// spans not exported yet.
+type Provider interface {
+	trace.TracerProvider
+	Shutdown(ctx context.Context) error
+}
+
+type providerConfig struct {
+	serviceName  string
+	endpoint     string
+	insecure     bool
+	sampleRatio  float64
+	stdoutWriter io.Writer
+}
+
+type ProviderOption func(*providerConfig)
+
+func WithServiceName(name string) ProviderOption {
+	return func(c *providerConfig) {
+		c.serviceName = name
+	}
+}
+
+// WithOTLPEndpoint sets the host:port of the OTLP/HTTP collector.
+func WithOTLPEndpoint(endpoint string, insecure bool) ProviderOption {
+	return func(c *providerConfig) {
+		c.endpoint = endpoint
+		c.insecure = insecure
+	}
+}
+
+// WithSampleRatio sets the share of new traces recorded; traces started
+// upstream keep the sampling decision of their parent.
+func WithSampleRatio(ratio float64) ProviderOption {
+	return func(c *providerConfig) {
+		c.sampleRatio = ratio
+	}
+}
+
+// WithStdoutWriter redirects the stdout exporter, mostly for tests.
+func WithStdoutWriter(w io.Writer) ProviderOption {
+	return func(c *providerConfig) {
+		c.stdoutWriter = w
+	}
+}
+
+// NewProvider builds a tracer provider exporting spans with the given
+// exporter. ExporterNone returns a provider that records nothing.
+func NewProvider(ctx context.Context, exporter string, options ...ProviderOption) (Provider, error) {
+	c := &providerConfig{
+		serviceName:  "geoservice",
+		sampleRatio:  1,
+		stdoutWriter: os.Stdout,
+	}
+	for _, option := range options {
+		option(c)
+	}
+
+	var spanExporter sdktrace.SpanExporter
+	var err error
+
+	switch exporter {
+	case ExporterNone:
+		return noopProvider{noop.NewTracerProvider()}, nil
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(c.stdoutWriter), stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(c.endpoint)}
+		if c.insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		spanExporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.sampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(c.serviceName))),
+	), nil
+}
+
+type noopProvider struct {
+	noop.TracerProvider
+}
+
+func (noopProvider) Shutdown(context.Context) error {
+	return nil
+}
+
+// Middleware starts a server span for every request, continuing the trace
+// of the caller. The span is named after the chi route pattern once the
+// request has been routed, so the middleware must wrap the router.
+func Middleware(tp trace.TracerProvider) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		routed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				span := trace.SpanFromContext(r.Context())
+				span.SetName(r.Method + " " + rctx.RoutePattern())
+				span.SetAttributes(semconv.HTTPRoute(rctx.RoutePattern()))
+			}
+		})
+
+		return otelhttp.NewHandler(routed, "",
+			otelhttp.WithTracerProvider(tp),
+			otelhttp.WithPropagators(Propagator),
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return r.Method
+			}),
+		)
+	}
+}
+
+// NewTransport wraps base with client spans and injects the trace context
+// into the outgoing requests.
+func NewTransport(tp trace.TracerProvider, base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base,
+		otelhttp.WithTracerProvider(tp),
+		otelhttp.WithPropagators(Propagator),
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Host
+		}),
+	)
+}
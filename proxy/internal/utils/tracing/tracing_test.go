@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"github.com/go-chi/chi/v5"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	var upstreamHeader string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHeader = r.Header.Get("Traceparent")
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: NewTransport(tp, http.DefaultTransport)}
+
+	r := chi.NewRouter()
+	r.Use(Middleware(tp))
+	r.Get("/api/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), "GET", upstream.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", "/api/users/42", nil)
+	req.Header.Set("Traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want server and client span", len(spans))
+	}
+
+	names := map[string]bool{}
+	for _, span := range spans {
+		names[span.Name()] = true
+		if got := span.SpanContext().TraceID().String(); got != traceID {
+			t.Errorf("span %q has trace id %s, want the caller's %s", span.Name(), got, traceID)
+		}
+	}
+	for _, want := range []string{"GET /api/users/{id}", "GET " + strings.TrimPrefix(upstream.URL, "http://")} {
+		if !names[want] {
+			t.Errorf("got spans %v, want %q", names, want)
+		}
+	}
+
+	if !strings.Contains(upstreamHeader, traceID) {
+		t.Errorf("got upstream traceparent %q, want trace id %s", upstreamHeader, traceID)
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	testCases := []struct {
+		name     string
+		exporter string
+		wantErr  bool
+		wantOut  bool
+	}{
+		{"disabled", ExporterNone, false, false},
+		{"stdout", ExporterStdout, false, true},
+		{"unknown", "zipkin", true, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			tp, err := NewProvider(context.Background(), tc.exporter, WithStdoutWriter(&out))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			_, span := tp.Tracer("test").Start(context.Background(), "lookup")
+			span.End()
+			if err := tp.Shutdown(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := strings.Contains(out.String(), `"Name": "lookup"`); got != tc.wantOut {
+				t.Errorf("got span exported %v, want %v:\n%s", got, tc.wantOut, out.String())
+			}
+		})
+	}
+}